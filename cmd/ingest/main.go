@@ -0,0 +1,330 @@
+// ingest 命令行工具：将本地 Markdown、纯文本和 HTML 文档导入知识库
+//
+// 用法:
+//
+//	ingest [-config config.yml] [-server 服务地址] add [-kb 知识库] [-url-prefix 链接前缀] <文件或目录>...
+//	ingest [-config config.yml] [-server 服务地址] list [-kb 知识库]
+//	ingest [-config config.yml] [-server 服务地址] get <文档ID>
+//	ingest [-config config.yml] [-server 服务地址] delete <文档ID>
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/database"
+	"knowledge-maker/internal/filelock"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/resilience"
+	"knowledge-maker/internal/segment"
+	"knowledge-maker/internal/service"
+)
+
+// supportedExts 支持导入的文件扩展名
+var supportedExts = map[string]bool{
+	".md": true, ".markdown": true, ".txt": true, ".html": true, ".htm": true,
+}
+
+// errUsage 命令行参数错误，输出帮助信息后退出
+var errUsage = errors.New("参数错误")
+
+func main() {
+	configPath := flag.String("config", "", "配置文件路径（默认 ./config.yml）")
+	serverURL := flag.String("server", "", "运行中的服务地址，文档变更后调用其管理接口清除缓存（默认 http://127.0.0.1:{server.port}，为 - 时不通知）")
+	flag.Usage = usage
+	flag.Parse()
+
+	// 在 run 中返回错误而不是直接退出，保证数据库等资源的 defer 关闭得以执行
+	if err := run(*configPath, *serverURL, flag.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			usage()
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+// run 执行命令
+func run(configPath, serverURL string, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %v", err)
+	}
+	if cfg.Database.Type == "none" {
+		return fmt.Errorf("文档管理需要数据库，请配置 database.type")
+	}
+	// 检索缓存由服务进程持有，命令行工具不加载也不保存，避免覆盖服务写入的缓存文件
+	cfg.Knowledge.Cache.Enabled = false
+	resilience.Init(&cfg.Resilience)
+
+	if cfg.Segment.UserDict != "" {
+		if err := segment.Default().LoadUserDict(cfg.Segment.UserDict); err != nil {
+			return err
+		}
+	}
+
+	db, err := database.Open(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("数据库初始化失败: %v", err)
+	}
+	defer db.Close()
+
+	aiService := service.NewAIService(cfg)
+	knowledgeService, err := service.NewKnowledgeService(cfg, aiService, aiService)
+	if errors.Is(err, filelock.ErrLocked) {
+		return fmt.Errorf("本地知识库正被服务进程使用（%v），服务运行期间请改用 /api/v1/documents 接口", err)
+	}
+	if err != nil {
+		return fmt.Errorf("初始化知识库失败: %v", err)
+	}
+	// 释放本地向量存储和关键词索引的文件锁
+	defer knowledgeService.Close()
+
+	// 记录内容变更的知识库，命令结束后通知服务清除相关缓存
+	changed := map[string]bool{}
+	knowledgeService.OnChange(func(name string) {
+		changed[name] = true
+	})
+	documentService, err := service.NewDocumentService(db, knowledgeService, cfg)
+	if err != nil {
+		return fmt.Errorf("初始化文档服务失败: %v", err)
+	}
+
+	switch args[0] {
+	case "add":
+		err = runAdd(documentService, args[1:])
+	case "list":
+		err = runList(documentService, args[1:])
+	case "get":
+		err = runGet(documentService, args[1:])
+	case "delete":
+		err = runDelete(documentService, args[1:])
+	default:
+		return errUsage
+	}
+
+	if serverURL == "" {
+		serverURL = "http://127.0.0.1:" + cfg.Server.Port
+	}
+	if serverURL != "-" {
+		for _, name := range slices.Sorted(maps.Keys(changed)) {
+			purgeServerCache(serverURL, cfg.Server.AdminToken, name)
+		}
+	}
+	return err
+}
+
+// purgeServerCache 调用服务的管理接口清除与知识库相关的检索缓存和缓存回答，失败时只输出提示
+func purgeServerCache(serverURL, adminToken, name string) {
+	if adminToken == "" {
+		fmt.Fprintf(os.Stderr, "未配置 server.admin_token，无法通知服务清除知识库 %s 的缓存\n", name)
+		return
+	}
+
+	endpoint := strings.TrimSuffix(serverURL, "/") + "/api/v1/cache?knowledge_base=" + url.QueryEscape(name)
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "通知服务清除知识库 %s 的缓存失败: %v\n", name, err)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "通知服务清除知识库 %s 的缓存失败（服务未运行时可忽略）: %v\n", name, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		fmt.Fprintf(os.Stderr, "通知服务清除知识库 %s 的缓存失败，状态码: %d, 响应: %s\n", name, resp.StatusCode, string(body))
+		return
+	}
+	fmt.Printf("已通知服务清除知识库 %s 的缓存\n", name)
+}
+
+// usage 输出帮助信息
+func usage() {
+	fmt.Fprintf(os.Stderr, `用法: ingest [-config config.yml] [-server 服务地址] <命令> [参数]
+
+选项:
+  -config   配置文件路径（默认 ./config.yml）
+  -server   运行中的服务地址（默认 http://127.0.0.1:{server.port}），导入或删除文档后调用其管理接口清除相关缓存，为 - 时不通知
+
+命令:
+  add [-kb 知识库] [-url-prefix 链接前缀] <文件或目录>...   导入文档（目录递归导入 .md/.markdown/.txt/.html/.htm）
+  list [-kb 知识库]                                         列出已导入的文档
+  get <文档ID>                                              查看文档详情
+  delete <文档ID>                                           删除文档
+
+注意: 本地向量存储和关键词索引由服务进程独占锁定，服务运行期间本工具会拒绝执行，请改用 /api/v1/documents 接口。
+只使用远程知识库（如 tcvectordb）时本工具可以与服务同时运行，文档变更后通过 -server 通知服务清除缓存。
+`)
+}
+
+// runAdd 导入文件或目录
+func runAdd(ds *service.DocumentService, args []string) error {
+	fset := flag.NewFlagSet("add", flag.ExitOnError)
+	kb := fset.String("kb", "", "目标知识库（默认使用默认知识库）")
+	urlPrefix := fset.String("url-prefix", "", "文档链接前缀，链接为前缀拼接文件相对路径")
+	fset.Parse(args)
+
+	if fset.NArg() == 0 {
+		return fmt.Errorf("请指定要导入的文件或目录")
+	}
+
+	counts := map[string]int{}
+	failed := 0
+	for _, root := range fset.Args() {
+		files, err := collectFiles(root)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			content, err := os.ReadFile(file.path)
+			if err != nil {
+				return fmt.Errorf("读取文件 %s 失败: %v", file.path, err)
+			}
+
+			req := model.IngestDocumentRequest{
+				KnowledgeBase: *kb,
+				Source:        file.source,
+				Content:       string(content),
+			}
+			if *urlPrefix != "" {
+				req.URL = strings.TrimSuffix(*urlPrefix, "/") + "/" + file.source
+			}
+
+			doc, status, err := ds.IngestDocument(context.Background(), req)
+			if err != nil {
+				failed++
+				fmt.Printf("%-10s %s: %v\n", "failed", file.source, err)
+				continue
+			}
+			counts[status]++
+			fmt.Printf("%-10s %s -> %s（%d 个片段）\n", status, file.source, doc.ID, doc.ChunkCount)
+		}
+	}
+
+	fmt.Printf("\n新增 %d，更新 %d，未变化 %d，失败 %d\n",
+		counts[model.DocumentStatusCreated], counts[model.DocumentStatusUpdated], counts[model.DocumentStatusUnchanged], failed)
+	if failed > 0 {
+		return fmt.Errorf("%d 个文档导入失败", failed)
+	}
+	return nil
+}
+
+// runList 列出文档
+func runList(ds *service.DocumentService, args []string) error {
+	fset := flag.NewFlagSet("list", flag.ExitOnError)
+	kb := fset.String("kb", "", "只列出指定知识库的文档")
+	fset.Parse(args)
+
+	const pageSize = 500
+	for offset := 0; ; offset += pageSize {
+		documents, err := ds.ListDocuments(*kb, pageSize, offset)
+		if err != nil {
+			return err
+		}
+		for _, doc := range documents {
+			fmt.Printf("%s\t%s\t%d\t%s\t%s\n", doc.ID, doc.KnowledgeBase, doc.ChunkCount,
+				doc.UpdatedAt.Format("2006-01-02 15:04:05"), doc.Source)
+		}
+		if len(documents) < pageSize {
+			return nil
+		}
+	}
+}
+
+// runGet 输出文档详情
+func runGet(ds *service.DocumentService, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("请指定文档 ID")
+	}
+
+	doc, err := ds.GetDocument(args[0])
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// runDelete 删除文档
+func runDelete(ds *service.DocumentService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("请指定文档 ID")
+	}
+
+	for _, id := range args {
+		if err := ds.DeleteDocument(context.Background(), id); err != nil {
+			return err
+		}
+		fmt.Printf("已删除 %s\n", id)
+	}
+	return nil
+}
+
+// sourceFile 待导入的文件
+type sourceFile struct {
+	path   string
+	source string // 相对导入根目录的路径，作为文档来源标识
+}
+
+// collectFiles 收集待导入的文件，目录递归查找支持的扩展名
+func collectFiles(root string) ([]sourceFile, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []sourceFile{{path: root, source: filepath.ToSlash(filepath.Base(root))}}, nil
+	}
+
+	var files []sourceFile
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !supportedExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, sourceFile{path: path, source: filepath.ToSlash(rel)})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,117 @@
+// querystats 命令行工具：统计日志中记录的用户查询关键词，用于分析高频问题和知识库覆盖情况
+//
+// 用法:
+//
+//	querystats [-config config.yml] [-dir 日志目录] [-since 2006-01-02] [-n 30]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"knowledge-maker/internal/config"
+)
+
+// keywordMarker 查询关键词日志标记，与 RAG 服务的日志格式保持一致
+const keywordMarker = "查询关键词: "
+
+func main() {
+	configPath := flag.String("config", "", "配置文件路径（默认 ./config.yml）")
+	dir := flag.String("dir", "", "日志目录（默认使用配置中的 log.dir）")
+	since := flag.String("since", "", "只统计该日期（含）之后的日志，格式 2006-01-02")
+	top := flag.Int("n", 30, "输出的关键词数量")
+	flag.Parse()
+
+	logDir := *dir
+	if logDir == "" {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("加载配置失败: %v", err)
+		}
+		logDir = cfg.Log.Dir
+	}
+
+	files, err := filepath.Glob(filepath.Join(logDir, "app_*.log"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(files)
+
+	if *since != "" {
+		if _, err := time.Parse("2006-01-02", *since); err != nil {
+			log.Fatalf("日期格式错误: %v", err)
+		}
+	}
+
+	counts := make(map[string]int)
+	queries := 0
+	for _, file := range files {
+		// 日志文件名为 app_YYYY-MM-DD.log，按文件名过滤日期
+		date := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "app_"), ".log")
+		if *since != "" && date < *since {
+			continue
+		}
+
+		n, err := countKeywords(file, counts)
+		if err != nil {
+			log.Fatalf("读取日志 %s 失败: %v", file, err)
+		}
+		queries += n
+	}
+
+	if queries == 0 {
+		fmt.Println("没有找到查询关键词记录")
+		return
+	}
+
+	keywords := make([]string, 0, len(counts))
+	for k := range counts {
+		keywords = append(keywords, k)
+	}
+	sort.Slice(keywords, func(i, j int) bool {
+		if counts[keywords[i]] != counts[keywords[j]] {
+			return counts[keywords[i]] > counts[keywords[j]]
+		}
+		return keywords[i] < keywords[j]
+	})
+	if len(keywords) > *top {
+		keywords = keywords[:*top]
+	}
+
+	fmt.Printf("查询次数: %d，不同关键词: %d\n\n", queries, len(counts))
+	for i, k := range keywords {
+		fmt.Printf("%3d. %-20s %6d  %5.1f%%\n", i+1, k, counts[k], float64(counts[k])*100/float64(queries))
+	}
+}
+
+// countKeywords 统计单个日志文件中的关键词，返回查询次数
+func countKeywords(path string, counts map[string]int) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	queries := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		idx := strings.Index(line, keywordMarker)
+		if idx < 0 {
+			continue
+		}
+		queries++
+		for _, keyword := range strings.Fields(line[idx+len(keywordMarker):]) {
+			counts[keyword]++
+		}
+	}
+	return queries, scanner.Err()
+}
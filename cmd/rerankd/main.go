@@ -0,0 +1,88 @@
+// rerankd 本地重排序服务替身：实现通用 /rerank 接口，按查询关键词在文档中的覆盖率打分，
+// 用于开发调试或在没有重排序模型时替代 rerank.type=http 的上游服务
+//
+// 用法:
+//
+//	rerankd [-addr :8100]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"knowledge-maker/internal/segment"
+)
+
+// rerankRequest 重排序请求
+type rerankRequest struct {
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// rerankResult 单个文档的重排序结果
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+func main() {
+	addr := flag.String("addr", ":8100", "监听地址")
+	flag.Parse()
+
+	http.HandleFunc("/rerank", handleRerank)
+	http.HandleFunc("/v1/rerank", handleRerank)
+
+	log.Printf("本地重排序服务启动在 %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// handleRerank 处理重排序请求
+func handleRerank(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req rerankRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keywords := segment.Keywords(req.Query)
+	results := make([]rerankResult, len(req.Documents))
+	for i, doc := range req.Documents {
+		results[i] = rerankResult{Index: i, RelevanceScore: coverage(keywords, doc)}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RelevanceScore > results[j].RelevanceScore
+	})
+	if req.TopN > 0 && len(results) > req.TopN {
+		results = results[:req.TopN]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
+
+// coverage 计算查询关键词在文档中出现的比例
+func coverage(keywords []string, doc string) float64 {
+	if len(keywords) == 0 {
+		return 0
+	}
+
+	doc = strings.ToLower(segment.Normalize(doc))
+	hit := 0
+	for _, keyword := range keywords {
+		if strings.Contains(doc, keyword) {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(keywords))
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/database"
 	"knowledge-maker/internal/handler"
 	"knowledge-maker/internal/logger"
 	"knowledge-maker/internal/middleware"
+	"knowledge-maker/internal/prompt"
+	"knowledge-maker/internal/resilience"
+	"knowledge-maker/internal/segment"
 	"knowledge-maker/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -37,9 +47,13 @@ func handleRoot(c *gin.Context) {
 		"status":      "running",
 		"description": "基于 RAG 技术的智能问答服务",
 		"endpoints": gin.H{
-			"health": "/api/v1/health",
-			"chat":   "/api/v1/chat",
-			"stream": "/api/v1/chat/stream",
+			"health":        "/api/v1/health",
+			"chat":          "/api/v1/chat",
+			"stream":        "/api/v1/chat/stream",
+			"conversations": "/api/v1/conversations",
+			"documents":     "/api/v1/documents",
+			"cache":         "/api/v1/cache",
+			"upstreams":     "/api/v1/upstreams",
 		},
 	})
 }
@@ -84,6 +98,18 @@ func main() {
 	logger.Info("应用启动中...")
 	logger.Info("配置加载完成 - 服务端口: %s, 模式: %s", cfg.Server.Port, cfg.Server.Mode)
 
+	// 上游服务的重试与熔断配置（需在创建各服务的 HTTP 客户端之前设置）
+	resilience.Init(&cfg.Resilience)
+
+	// 加载中文分词用户词典（需在初始化关键词索引之前）
+	if cfg.Segment.UserDict != "" {
+		if err := segment.Default().LoadUserDict(cfg.Segment.UserDict); err != nil {
+			logger.Warn("%v", err)
+		} else {
+			logger.Info("已加载分词用户词典: %s", cfg.Segment.UserDict)
+		}
+	}
+
 	// 设置 Gin 模式
 	gin.SetMode(cfg.Server.Mode)
 
@@ -113,7 +139,7 @@ func main() {
 
 		c.Header("Access-Control-Allow-Origin", allowOrigin)
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Captcha-Ticket, X-Captcha-Randstr, X-Geetest-Lot-Number, X-Geetest-Captcha-Output, X-Geetest-Pass-Token, X-Geetest-Gen-Time, X-Recaptcha-Token, X-Recaptcha-Action, X-Cf-Turnstile-Token, X-Session-Token")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Captcha-Ticket, X-Captcha-Randstr, X-Geetest-Lot-Number, X-Geetest-Captcha-Output, X-Geetest-Pass-Token, X-Geetest-Gen-Time, X-Recaptcha-Token, X-Recaptcha-Action, X-Cf-Turnstile-Token, X-Session-Token, X-Client-Id, X-Stream-Protocol")
 		c.Header("Access-Control-Expose-Headers", "X-Session-Token")
 
 		if c.Request.Method == "OPTIONS" {
@@ -127,10 +153,60 @@ func main() {
 	// 注册通用路由
 	setupCommonRoutes(r)
 
+	// 初始化数据库和会话服务
+	var (
+		db                  *database.DB
+		conversationService *service.ConversationService
+	)
+	if cfg.Database.Type != "none" {
+		db, err = database.Open(&cfg.Database)
+		if err != nil {
+			logger.Warn("数据库初始化失败，会话及文档管理功能不可用: %v", err)
+			db = nil
+		} else {
+			defer db.Close()
+			conversationService, err = service.NewConversationService(db)
+			if err != nil {
+				logger.Warn("会话服务初始化失败，会话功能不可用: %v", err)
+				conversationService = nil
+			} else {
+				logger.Info("会话服务初始化成功，数据库类型: %s", db.Type())
+			}
+		}
+	}
+
 	// 初始化服务
-	knowledgeService := service.NewKnowledgeService(cfg)
 	aiService := service.NewAIService(cfg)
-	ragService := service.NewRAGService(knowledgeService, aiService, cfg)
+	knowledgeService, err := service.NewKnowledgeService(cfg, aiService, aiService)
+	if err != nil {
+		log.Fatalf("初始化知识库失败: %v", err)
+	}
+	rerankService, err := service.NewRerankService(cfg, aiService)
+	if err != nil {
+		log.Fatalf("初始化重排序服务失败: %v", err)
+	}
+	templates, err := prompt.NewTemplates(cfg)
+	if err != nil {
+		log.Fatalf("加载提示词模板失败: %v", err)
+	}
+	ragService, err := service.NewRAGService(knowledgeService, rerankService, aiService, conversationService, templates, cfg)
+	if err != nil {
+		log.Fatalf("初始化 RAG 服务失败: %v", err)
+	}
+	knowledgeService.OnChange(ragService.InvalidateAnswerCache)
+
+	// 初始化文档服务（依赖数据库保存文档元数据）
+	var documentService *service.DocumentService
+	if db != nil {
+		documentService, err = service.NewDocumentService(db, knowledgeService, cfg)
+		if err != nil {
+			logger.Warn("文档服务初始化失败，文档管理功能不可用: %v", err)
+			documentService = nil
+		}
+	}
+	if cfg.Server.AdminToken == "" {
+		logger.Warn("未配置 server.admin_token，文档管理接口不可用")
+	}
 
 	// 初始化验证码服务
 	captchaService, err := service.NewCaptchaService(&cfg.Captcha)
@@ -142,11 +218,17 @@ func main() {
 	}
 
 	// 初始化 MCP 服务和处理器
-	mcpService := service.NewMCPService(knowledgeService, aiService, cfg)
+	mcpService := service.NewMCPService(knowledgeService, aiService, templates, cfg)
 	mcpHandler := handler.NewMCPHandler(mcpService)
 
 	// 初始化处理器
-	ragHandler := handler.NewRAGHandler(ragService)
+	ragHandler := handler.NewRAGHandler(ragService, &cfg.Server)
+	conversationHandler := handler.NewConversationHandler(conversationService)
+	documentHandler := handler.NewDocumentHandler(documentService)
+	cacheHandler := handler.NewCacheHandler(knowledgeService, ragService)
+	modelHandler := handler.NewModelHandler(aiService)
+	promptHandler := handler.NewPromptHandler(ragService)
+	upstreamHandler := handler.NewUpstreamHandler()
 
 	// 初始化验证码中间件
 	captchaMiddleware := middleware.NewCaptchaMiddleware(captchaService)
@@ -157,6 +239,41 @@ func main() {
 		// 使用验证码中间件保护聊天接口
 		api.POST("/chat", captchaMiddleware.VerifyCaptcha(), ragHandler.HandleChat)
 		api.POST("/chat/stream", captchaMiddleware.VerifyCaptcha(), ragHandler.HandleStreamChat)
+
+		// 会话管理接口
+		conversations := api.Group("/conversations")
+		{
+			conversations.POST("", conversationHandler.HandleCreate)
+			conversations.GET("", conversationHandler.HandleList)
+			conversations.GET("/:id", conversationHandler.HandleGet)
+			conversations.DELETE("/:id", conversationHandler.HandleDelete)
+		}
+
+		// 文档管理接口 - 需要管理员令牌
+		documents := api.Group("/documents", middleware.AdminAuth(cfg.Server.AdminToken))
+		{
+			documents.POST("", documentHandler.HandleIngest)
+			documents.GET("", documentHandler.HandleList)
+			documents.GET("/:id", documentHandler.HandleGet)
+			documents.DELETE("/:id", documentHandler.HandleDelete)
+		}
+
+		// 检索缓存管理接口 - 需要管理员令牌
+		cache := api.Group("/cache", middleware.AdminAuth(cfg.Server.AdminToken))
+		{
+			cache.GET("", cacheHandler.HandleStats)
+			cache.DELETE("", cacheHandler.HandlePurge)
+		}
+
+		// 重新加载提示词模板 - 需要管理员令牌
+		api.POST("/prompts/reload", middleware.AdminAuth(cfg.Server.AdminToken), promptHandler.HandleReload)
+
+		// 上游服务熔断状态 - 需要管理员令牌
+		api.GET("/upstreams", middleware.AdminAuth(cfg.Server.AdminToken), upstreamHandler.HandleStatus)
+
+		// 可选模型列表
+		api.GET("/models", modelHandler.HandleList)
+
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"status":  "ok",
@@ -178,8 +295,39 @@ func main() {
 	}
 
 	// 启动服务器
-	log.Printf("服务器启动在端口 :%s", cfg.Server.Port)
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatal("服务器启动失败:", err)
+	srv := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: r,
+	}
+	go func() {
+		log.Printf("服务器启动在端口 :%s", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("服务器启动失败:", err)
+		}
+	}()
+
+	// 收到 SIGHUP 时重新加载提示词模板
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			ragService.ReloadPrompts()
+		}
+	}()
+
+	// 收到退出信号后等待进行中的请求完成，再保存检索缓存
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	logger.Info("收到退出信号，正在关闭服务器...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("服务器关闭超时: %v", err)
+	}
+	if err := knowledgeService.Close(); err != nil {
+		logger.Error("关闭知识库服务失败: %v", err)
 	}
+	logger.Info("服务器已关闭")
 }
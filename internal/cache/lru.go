@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Stats 缓存统计信息
+type Stats struct {
+	Size      int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// Entry 缓存条目，用于持久化
+type Entry[V any] struct {
+	Key       string
+	Value     V
+	ExpiresAt time.Time
+}
+
+// LRU 带过期时间的定长 LRU 缓存，并发安全
+type LRU[V any] struct {
+	mu        sync.Mutex
+	capacity  int
+	ttl       time.Duration
+	ll        *list.List // 最近使用的条目在前
+	items     map[string]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewLRU 创建 LRU 缓存，ttl 不大于 0 时条目不过期
+func NewLRU[V any](capacity int, ttl time.Duration) *LRU[V] {
+	return &LRU[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 获取缓存值，过期的条目视为未命中并被删除
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+	entry := elem.Value.(*Entry[V])
+	if c.expired(entry, time.Now()) {
+		c.removeElement(elem)
+		c.misses++
+		return zero, false
+	}
+
+	c.ll.MoveToFront(elem)
+	c.hits++
+	return entry.Value, true
+}
+
+// Set 写入缓存，超出容量时淘汰最久未使用的条目
+func (c *LRU[V]) Set(key string, value V) {
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+	c.set(key, value, expiresAt)
+}
+
+// set 写入带指定过期时间的条目
+func (c *LRU[V]) set(key string, value V, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*Entry[V])
+		entry.Value = value
+		entry.ExpiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&Entry[V]{Key: key, Value: value, ExpiresAt: expiresAt})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+// RemoveFunc 删除键满足条件的条目，返回删除数量
+func (c *LRU[V]) RemoveFunc(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, elem := range c.items {
+		if match(key) {
+			c.removeElement(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Purge 清空缓存，返回删除数量；统计计数保留
+func (c *LRU[V]) Purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.ll.Len()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	return n
+}
+
+// Stats 获取统计信息
+func (c *LRU[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Size:      c.ll.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// Entries 导出未过期的条目，按最近使用到最久未使用排列
+func (c *LRU[V]) Entries() []Entry[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry[V], 0, c.ll.Len())
+	for elem := c.ll.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*Entry[V])
+		if !c.expired(entry, now) {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+// Restore 导入条目并保留原过期时间，已过期的条目被跳过，返回导入数量
+func (c *LRU[V]) Restore(entries []Entry[V]) int {
+	now := time.Now()
+	restored := 0
+	// 倒序写入，使最近使用的条目保持在最前
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if c.expired(&entry, now) {
+			continue
+		}
+		c.set(entry.Key, entry.Value, entry.ExpiresAt)
+		restored++
+	}
+	return restored
+}
+
+// expired 判断条目是否已过期
+func (c *LRU[V]) expired(entry *Entry[V], now time.Time) bool {
+	return !entry.ExpiresAt.IsZero() && now.After(entry.ExpiresAt)
+}
+
+// removeElement 删除条目，调用方需持有锁
+func (c *LRU[V]) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*Entry[V]).Key)
+}
@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keys 返回按最近使用排列的键
+func keys[V any](c *LRU[V]) []string {
+	var result []string
+	for _, entry := range c.Entries() {
+		result = append(result, entry.Key)
+	}
+	return result
+}
+
+func TestLRUEviction(t *testing.T) {
+	tests := []struct {
+		name          string
+		capacity      int
+		ops           func(c *LRU[int])
+		wantKeys      []string
+		wantEvictions uint64
+	}{
+		{
+			name:     "超出容量时淘汰最久未使用的条目",
+			capacity: 2,
+			ops: func(c *LRU[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+			},
+			wantKeys:      []string{"c", "b"},
+			wantEvictions: 1,
+		},
+		{
+			name:     "读取后变为最近使用",
+			capacity: 2,
+			ops: func(c *LRU[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Get("a")
+				c.Set("c", 3)
+			},
+			wantKeys:      []string{"c", "a"},
+			wantEvictions: 1,
+		},
+		{
+			name:     "更新已有的键不淘汰",
+			capacity: 2,
+			ops: func(c *LRU[int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("a", 10)
+			},
+			wantKeys: []string{"a", "b"},
+		},
+		{
+			name:     "容量为 0 时不限制",
+			capacity: 0,
+			ops: func(c *LRU[int]) {
+				for _, key := range []string{"a", "b", "c"} {
+					c.Set(key, 1)
+				}
+			},
+			wantKeys: []string{"c", "b", "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[int](tt.capacity, 0)
+			tt.ops(c)
+			if got := keys(c); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", got, tt.wantKeys)
+			}
+			if got := c.Stats().Evictions; got != tt.wantEvictions {
+				t.Errorf("evictions = %d, want %d", got, tt.wantEvictions)
+			}
+		})
+	}
+}
+
+func TestLRUGet(t *testing.T) {
+	c := NewLRU[int](2, 0)
+	c.Set("a", 1)
+	c.Set("a", 2)
+
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Get(a) = %d, %v, want 2, true", v, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get(missing) = true")
+	}
+
+	stats := c.Stats()
+	want := Stats{Size: 1, Capacity: 2, Hits: 1, Misses: 1}
+	if stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		expiresAt time.Time
+		wantHit   bool
+	}{
+		{"未过期", time.Hour, time.Now().Add(time.Hour), true},
+		{"已过期", time.Hour, time.Now().Add(-time.Second), false},
+		{"不过期", 0, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[int](10, tt.ttl)
+			c.set("a", 1, tt.expiresAt)
+
+			if _, ok := c.Get("a"); ok != tt.wantHit {
+				t.Fatalf("Get() hit = %v, want %v", ok, tt.wantHit)
+			}
+			// 过期的条目读取后被删除
+			wantSize := 1
+			if !tt.wantHit {
+				wantSize = 0
+			}
+			if got := c.Stats().Size; got != wantSize {
+				t.Errorf("size = %d, want %d", got, wantSize)
+			}
+		})
+	}
+}
+
+func TestLRUSetTTL(t *testing.T) {
+	c := NewLRU[int](10, 20*time.Millisecond)
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get() before expiry = false")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get() after expiry = true")
+	}
+
+	// ttl 为负数时不过期
+	c = NewLRU[int](10, -time.Second)
+	c.Set("a", 1)
+	if entry := c.Entries()[0]; !entry.ExpiresAt.IsZero() {
+		t.Errorf("ExpiresAt = %v, want zero", entry.ExpiresAt)
+	}
+}
+
+func TestLRUEntriesAndRestore(t *testing.T) {
+	c := NewLRU[int](10, time.Hour)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.set("expired", 3, time.Now().Add(-time.Second))
+
+	entries := c.Entries()
+	if got := keys(c); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatalf("Entries() keys = %v, want [b a]", got)
+	}
+
+	// 导入时保持最近使用顺序，跳过已过期的条目
+	entries = append(entries, Entry[int]{Key: "old", Value: 4, ExpiresAt: time.Now().Add(-time.Minute)})
+	restored := NewLRU[int](10, time.Hour)
+	if n := restored.Restore(entries); n != 2 {
+		t.Errorf("Restore() = %d, want 2", n)
+	}
+	if got := keys(restored); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Errorf("restored keys = %v, want [b a]", got)
+	}
+
+	// 容量较小时保留最近使用的条目
+	small := NewLRU[int](1, time.Hour)
+	small.Restore(entries)
+	if got := keys(small); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("restored keys with capacity 1 = %v, want [b]", got)
+	}
+}
+
+func TestLRURemove(t *testing.T) {
+	c := NewLRU[int](10, 0)
+	for _, key := range []string{"docs/a", "docs/b", "faq/a"} {
+		c.Set(key, 1)
+	}
+	c.Get("docs/a")
+
+	if n := c.RemoveFunc(func(key string) bool { return strings.HasPrefix(key, "docs/") }); n != 2 {
+		t.Errorf("RemoveFunc() = %d, want 2", n)
+	}
+	if got := keys(c); !reflect.DeepEqual(got, []string{"faq/a"}) {
+		t.Errorf("keys after RemoveFunc = %v, want [faq/a]", got)
+	}
+
+	// 清空后统计计数保留
+	if n := c.Purge(); n != 1 {
+		t.Errorf("Purge() = %d, want 1", n)
+	}
+	if stats := c.Stats(); stats.Size != 0 || stats.Hits != 1 {
+		t.Errorf("Stats() after Purge = %+v, want size 0 and 1 hit", stats)
+	}
+}
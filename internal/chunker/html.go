@@ -0,0 +1,119 @@
+package chunker
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// skipTags 提取正文时忽略的标签
+var skipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"nav": true, "footer": true, "header": true, "svg": true, "iframe": true,
+}
+
+// blockTags 块级标签，前后需要换行
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"ul": true, "ol": true, "table": true, "tr": true, "blockquote": true,
+	"dl": true, "dt": true, "dd": true, "figure": true, "aside": true,
+}
+
+// headingLevels 标题标签对应的 Markdown 标题级别
+var headingLevels = map[string]int{"h1": 1, "h2": 2, "h3": 3, "h4": 4, "h5": 5, "h6": 6}
+
+// HTMLToText 从 HTML 中提取标题和正文，标题、列表和代码块转换为 Markdown 语法以保留文档结构
+func HTMLToText(source string) (string, string, error) {
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return "", "", err
+	}
+
+	var (
+		title string
+		b     strings.Builder
+	)
+
+	var walk func(n *html.Node, inPre bool)
+	walk = func(n *html.Node, inPre bool) {
+		if n.Type == html.TextNode {
+			if inPre {
+				b.WriteString(n.Data)
+			} else if text := strings.Join(strings.Fields(n.Data), " "); text != "" {
+				b.WriteString(text)
+				if strings.HasSuffix(n.Data, " ") || strings.HasSuffix(n.Data, "\n") {
+					b.WriteString(" ")
+				}
+			}
+			return
+		}
+		if n.Type != html.ElementNode && n.Type != html.DocumentNode {
+			return
+		}
+
+		tag := n.Data
+		if n.Type == html.ElementNode {
+			if skipTags[tag] {
+				return
+			}
+			if tag == "title" {
+				if n.FirstChild != nil && title == "" {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+				return
+			}
+		}
+
+		switch {
+		case headingLevels[tag] > 0:
+			b.WriteString("\n\n" + strings.Repeat("#", headingLevels[tag]) + " ")
+		case tag == "pre":
+			b.WriteString("\n\n```\n")
+			inPre = true
+		case tag == "li":
+			b.WriteString("\n- ")
+		case tag == "br":
+			b.WriteString("\n")
+		case tag == "td" || tag == "th":
+			b.WriteString(" | ")
+		case blockTags[tag]:
+			b.WriteString("\n\n")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inPre)
+		}
+
+		switch {
+		case headingLevels[tag] > 0:
+			b.WriteString("\n\n")
+		case tag == "pre":
+			b.WriteString("\n```\n\n")
+		case blockTags[tag]:
+			b.WriteString("\n\n")
+		}
+	}
+	walk(doc, false)
+
+	return title, cleanBlankLines(b.String()), nil
+}
+
+// cleanBlankLines 去除行尾空白并合并连续空行
+func cleanBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
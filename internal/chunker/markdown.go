@@ -0,0 +1,326 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBlockFactor 代码块和表格保持完整的最大长度（相对 size 的倍数），超出后按行拆分
+const maxBlockFactor = 2
+
+var (
+	// headingPattern ATX 标题（# 标题）
+	headingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	// tableSeparatorPattern 表格分隔行（| --- | :---: |）
+	tableSeparatorPattern = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Chunk 按 Markdown 结构切分得到的片段
+type Chunk struct {
+	Content string // 片段内容，以标题路径开头
+	Section string // 标题路径，如 "安装指南 > 配置"
+}
+
+// blockKind 内容块类型
+type blockKind int
+
+const (
+	blockText blockKind = iota
+	blockCode
+	blockTable
+)
+
+// block 内容块：段落、代码块或表格
+type block struct {
+	kind  blockKind
+	lines []string
+}
+
+// text 返回内容块文本
+func (b block) text() string {
+	return strings.Join(b.lines, "\n")
+}
+
+// section 同一标题下的内容
+type section struct {
+	path   []string
+	blocks []block
+}
+
+// SplitMarkdown 按 Markdown 结构切分文档：以标题层级划分章节，代码块和表格保持完整，
+// 每个片段以所属章节的标题路径开头，片段长度目标为 size 个字符，同一章节内相邻片段重叠 overlap 个字符
+func SplitMarkdown(text string, size, overlap int) []Chunk {
+	text = stripFrontMatter(strings.ReplaceAll(text, "\r\n", "\n"))
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []Chunk
+	for _, sec := range parseSections(text) {
+		chunks = append(chunks, sec.split(size, overlap)...)
+	}
+	return chunks
+}
+
+// parseSections 将 Markdown 解析为章节列表
+func parseSections(text string) []*section {
+	lines := strings.Split(text, "\n")
+
+	type heading struct {
+		level int
+		title string
+	}
+	var (
+		stack    []heading
+		sections []*section
+		para     []string
+	)
+	current := &section{}
+
+	flushPara := func() {
+		if len(para) > 0 {
+			current.blocks = append(current.blocks, block{kind: blockText, lines: para})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// 代码块：直到匹配的结束标记，未闭合时延续到文末
+		if fence := fenceOpen(line); fence != "" {
+			flushPara()
+			end := i + 1
+			for end < len(lines) && !isFenceClose(lines[end], fence) {
+				end++
+			}
+			if end == len(lines) {
+				end--
+			}
+			current.blocks = append(current.blocks, block{kind: blockCode, lines: lines[i : end+1]})
+			i = end
+			continue
+		}
+
+		// 标题：开始新章节
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			flushPara()
+			sections = append(sections, current)
+
+			level := len(m[1])
+			for len(stack) > 0 && stack[len(stack)-1].level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			if title := strings.TrimSpace(m[2]); title != "" {
+				stack = append(stack, heading{level: level, title: title})
+			}
+
+			path := make([]string, len(stack))
+			for j, h := range stack {
+				path[j] = h.title
+			}
+			current = &section{path: path}
+			continue
+		}
+
+		// 表格：表头行后紧跟分隔行
+		if strings.Contains(line, "|") && i+1 < len(lines) && tableSeparatorPattern.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-") {
+			flushPara()
+			end := i + 2
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|") {
+				end++
+			}
+			current.blocks = append(current.blocks, block{kind: blockTable, lines: lines[i:end]})
+			i = end - 1
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushPara()
+			continue
+		}
+		para = append(para, strings.TrimRight(line, " \t"))
+	}
+	flushPara()
+	sections = append(sections, current)
+
+	return sections
+}
+
+// split 将章节内容切分为片段
+func (s *section) split(size, overlap int) []Chunk {
+	if len(s.blocks) == 0 {
+		return nil
+	}
+
+	sectionPath := strings.Join(s.path, " > ")
+	prefix := ""
+	if sectionPath != "" {
+		prefix = sectionPath + "\n\n"
+	}
+
+	if size <= 0 {
+		parts := make([]string, len(s.blocks))
+		for i, b := range s.blocks {
+			parts[i] = b.text()
+		}
+		return []Chunk{{Content: prefix + strings.Join(parts, "\n\n"), Section: sectionPath}}
+	}
+
+	// 标题路径计入片段长度，但至少为正文保留一半空间
+	budget := size - utf8.RuneCountInString(prefix)
+	if budget < size/2 {
+		budget = size / 2
+	}
+
+	var units []block
+	for _, b := range s.blocks {
+		units = append(units, splitBlock(b, budget)...)
+	}
+
+	var (
+		chunks  []Chunk
+		current []block
+		length  int
+	)
+	flush := func() {
+		parts := make([]string, len(current))
+		for i, b := range current {
+			parts[i] = b.text()
+		}
+		chunks = append(chunks, Chunk{Content: prefix + strings.Join(parts, "\n\n"), Section: sectionPath})
+	}
+
+	for _, unit := range units {
+		unitLen := utf8.RuneCountInString(unit.text())
+		if len(current) > 0 && length+unitLen+2 > budget {
+			flush()
+			last := current[len(current)-1]
+			current, length = nil, 0
+
+			// 仅在普通段落之间重叠，避免截断代码块和表格
+			if last.kind == blockText && unit.kind == blockText {
+				if tail := overlapTail(last.text(), overlap); tail != "" {
+					if tailLen := utf8.RuneCountInString(tail); tailLen+unitLen+2 <= budget {
+						current = append(current, block{kind: blockText, lines: []string{tail}})
+						length = tailLen
+					}
+				}
+			}
+		}
+		if len(current) > 0 {
+			length += 2
+		}
+		current = append(current, unit)
+		length += unitLen
+	}
+	if len(current) > 0 {
+		flush()
+	}
+
+	return chunks
+}
+
+// splitBlock 拆分超长内容块：段落按句子拆分，代码块和表格在不超过 maxBlockFactor 倍长度时保持完整，
+// 否则按行拆分，并为每一部分补全代码块标记或表头
+func splitBlock(b block, budget int) []block {
+	length := utf8.RuneCountInString(b.text())
+	if length <= budget {
+		return []block{b}
+	}
+
+	switch b.kind {
+	case blockText:
+		var out []block
+		for _, part := range splitLong(b.text(), budget) {
+			out = append(out, block{kind: blockText, lines: []string{part}})
+		}
+		return out
+	case blockCode:
+		if length <= budget*maxBlockFactor || len(b.lines) < 3 {
+			return []block{b}
+		}
+		open := b.lines[0]
+		body := b.lines[1:]
+		closing := strings.TrimSpace(body[len(body)-1])
+		if isFenceClose(closing, fenceOpen(open)) {
+			body = body[:len(body)-1]
+		} else {
+			closing = fenceOpen(open)
+		}
+		return splitLines(b.kind, []string{open}, body, []string{closing}, budget)
+	default:
+		if length <= budget*maxBlockFactor || len(b.lines) < 3 {
+			return []block{b}
+		}
+		return splitLines(b.kind, b.lines[:2], b.lines[2:], nil, budget)
+	}
+}
+
+// splitLines 将内容行分组，每组加上相同的头尾行，使每组长度尽量不超过 budget
+func splitLines(kind blockKind, head, body, tail []string, budget int) []block {
+	fixed := utf8.RuneCountInString(strings.Join(append(append([]string{}, head...), tail...), "\n")) + 1
+
+	var (
+		out    []block
+		group  []string
+		length = fixed
+	)
+	emit := func() {
+		lines := append(append(append([]string{}, head...), group...), tail...)
+		out = append(out, block{kind: kind, lines: lines})
+	}
+	for _, line := range body {
+		lineLen := utf8.RuneCountInString(line) + 1
+		if len(group) > 0 && length+lineLen > budget {
+			emit()
+			group, length = nil, fixed
+		}
+		group = append(group, line)
+		length += lineLen
+	}
+	if len(group) > 0 {
+		emit()
+	}
+	return out
+}
+
+// fenceOpen 判断是否为代码块开始行，返回代码块标记（``` 或 ~~~ 及其长度）
+func fenceOpen(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	for _, ch := range []byte{'`', '~'} {
+		n := 0
+		for n < len(trimmed) && trimmed[n] == ch {
+			n++
+		}
+		if n >= 3 {
+			if ch == '`' && strings.Contains(trimmed[n:], "`") {
+				return ""
+			}
+			return trimmed[:n]
+		}
+	}
+	return ""
+}
+
+// isFenceClose 判断是否为与 fence 匹配的代码块结束行
+func isFenceClose(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == "" && trimmed[0] == fence[0]
+}
+
+// stripFrontMatter 去除文档开头的 YAML front matter
+func stripFrontMatter(text string) string {
+	if !strings.HasPrefix(text, "---\n") {
+		return text
+	}
+	if end := strings.Index(text[4:], "\n---\n"); end >= 0 {
+		return text[4+end+5:]
+	}
+	return text
+}
@@ -0,0 +1,163 @@
+package chunker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []Chunk
+	}{
+		{
+			name: "按标题层级划分章节",
+			text: "# 安装\n\n简介。\n\n## 配置\n\n配置说明。\n\n# 使用\n\n用法。",
+			size: 100,
+			want: []Chunk{
+				{Content: "安装\n\n简介。", Section: "安装"},
+				{Content: "安装 > 配置\n\n配置说明。", Section: "安装 > 配置"},
+				{Content: "使用\n\n用法。", Section: "使用"},
+			},
+		},
+		{
+			name: "标题前的内容没有标题路径",
+			text: "前言。\n\n# 标题\n\n正文。",
+			size: 100,
+			want: []Chunk{
+				{Content: "前言。"},
+				{Content: "标题\n\n正文。", Section: "标题"},
+			},
+		},
+		{
+			name: "没有正文的章节不产生片段",
+			text: "# 一\n\n## 二\n\n正文。",
+			size: 100,
+			want: []Chunk{{Content: "一 > 二\n\n正文。", Section: "一 > 二"}},
+		},
+		{
+			name: "去除 front matter",
+			text: "---\ntitle: 示例\n---\n# 标题\n\n正文。",
+			size: 100,
+			want: []Chunk{{Content: "标题\n\n正文。", Section: "标题"}},
+		},
+		{
+			name: "代码块中的井号不是标题",
+			text: "# 脚本\n\n```sh\n# 注释\necho hi\n```",
+			size: 100,
+			want: []Chunk{{Content: "脚本\n\n```sh\n# 注释\necho hi\n```", Section: "脚本"}},
+		},
+		{
+			name: "未闭合的代码块延续到文末",
+			text: "```\n# 不是标题\ncode",
+			size: 100,
+			want: []Chunk{{Content: "```\n# 不是标题\ncode"}},
+		},
+		{
+			name: "size 为 0 时每个章节一个片段",
+			text: "# 标题\n\n第一段。\n\n第二段。",
+			size: 0,
+			want: []Chunk{{Content: "标题\n\n第一段。\n\n第二段。", Section: "标题"}},
+		},
+		{
+			name: "段落超出长度时拆分",
+			text: "第一段内容。\n\n第二段内容。",
+			size: 10,
+			want: []Chunk{{Content: "第一段内容。"}, {Content: "第二段内容。"}},
+		},
+		{
+			name: "空文档",
+			text: " \n\n",
+			size: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMarkdown(tt.text, tt.size, 0)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMarkdownOverlap(t *testing.T) {
+	got := SplitMarkdown("第一句。第二句。\n\n第三句。", 12, 4)
+	want := []Chunk{{Content: "第一句。第二句。"}, {Content: "第二句。\n\n第三句。"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitMarkdown() = %q, want %q", got, want)
+	}
+
+	// 代码块和表格不参与重叠
+	got = SplitMarkdown("第一句。第二句。\n\n```\ncode\n```", 12, 4)
+	want = []Chunk{{Content: "第一句。第二句。"}, {Content: "```\ncode\n```"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitMarkdown() = %q, want %q", got, want)
+	}
+}
+
+func TestSplitMarkdownLongBlocks(t *testing.T) {
+	var code, table []string
+	for range 20 {
+		code = append(code, "fmt.Println(1)")
+		table = append(table, "| 名称 | 说明 |")
+	}
+	codeText := "```go\n" + strings.Join(code, "\n") + "\n```"
+	tableText := "| 参数 | 含义 |\n| --- | --- |\n" + strings.Join(table, "\n")
+
+	tests := []struct {
+		name       string
+		text       string
+		size       int
+		wantChunks int
+		check      func(content string) bool
+	}{
+		{
+			name:       "不超过两倍长度的代码块保持完整",
+			text:       codeText,
+			size:       200,
+			wantChunks: 1,
+			check:      func(content string) bool { return content == codeText },
+		},
+		{
+			name:       "超长代码块拆分后补全代码块标记",
+			text:       codeText,
+			size:       100,
+			wantChunks: 4,
+			check: func(content string) bool {
+				return strings.HasPrefix(content, "```go\n") && strings.HasSuffix(content, "\n```")
+			},
+		},
+		{
+			name:       "超长表格拆分后保留表头",
+			text:       tableText,
+			size:       100,
+			wantChunks: 4,
+			check: func(content string) bool {
+				return strings.HasPrefix(content, "| 参数 | 含义 |\n| --- | --- |\n| 名称 | 说明 |")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := SplitMarkdown(tt.text, tt.size, 0)
+			if len(chunks) != tt.wantChunks {
+				t.Fatalf("len(chunks) = %d, want %d: %q", len(chunks), tt.wantChunks, chunks)
+			}
+			for _, chunk := range chunks {
+				if !tt.check(chunk.Content) {
+					t.Errorf("unexpected chunk %q", chunk.Content)
+				}
+				if n := utf8.RuneCountInString(chunk.Content); tt.wantChunks > 1 && n > tt.size {
+					t.Errorf("chunk length = %d, want <= %d", n, tt.size)
+				}
+			}
+		})
+	}
+}
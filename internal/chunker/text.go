@@ -0,0 +1,122 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// paragraphSeparator 段落分隔（一个或多个空行）
+var paragraphSeparator = regexp.MustCompile(`\n\s*\n`)
+
+// sentenceEnds 句子结束符
+const sentenceEnds = "。！？；.!?;\n"
+
+// SplitText 将纯文本按段落切分为不超过 size 个字符的片段，相邻片段之间重叠 overlap 个字符
+func SplitText(text string, size, overlap int) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	if size <= 0 {
+		return []string{text}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	// 先拆成不超过 size 的段落单元
+	var units []string
+	for _, para := range paragraphSeparator.Split(text, -1) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		units = append(units, splitLong(para, size)...)
+	}
+
+	// 再将段落单元合并为片段
+	var chunks []string
+	var current strings.Builder
+	for _, unit := range units {
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+utf8.RuneCountInString(unit)+2 > size {
+			chunk := current.String()
+			chunks = append(chunks, chunk)
+			current.Reset()
+			if tail := overlapTail(chunk, overlap); tail != "" {
+				current.WriteString(tail)
+			}
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(unit)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
+
+// splitLong 将超长段落按句子切分，单个句子仍然超长时按字符数硬切分
+func splitLong(para string, size int) []string {
+	if utf8.RuneCountInString(para) <= size {
+		return []string{para}
+	}
+
+	var parts []string
+	var current []rune
+	for _, sentence := range splitSentences(para) {
+		runes := []rune(sentence)
+		if len(current)+len(runes) > size && len(current) > 0 {
+			parts = append(parts, strings.TrimSpace(string(current)))
+			current = current[:0]
+		}
+		for len(runes) > size {
+			parts = append(parts, strings.TrimSpace(string(runes[:size])))
+			runes = runes[size:]
+		}
+		current = append(current, runes...)
+	}
+	if s := strings.TrimSpace(string(current)); s != "" {
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+// splitSentences 按句子结束符切分，结束符保留在句尾
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if strings.ContainsRune(sentenceEnds, r) {
+			end := i + utf8.RuneLen(r)
+			sentences = append(sentences, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// overlapTail 取片段末尾约 overlap 个字符作为下一个片段的开头，尽量从句子边界开始
+func overlapTail(chunk string, overlap int) string {
+	if overlap <= 0 {
+		return ""
+	}
+	runes := []rune(chunk)
+	if len(runes) <= overlap {
+		return ""
+	}
+
+	tail := runes[len(runes)-overlap:]
+	for i, r := range tail {
+		if strings.ContainsRune(sentenceEnds, r) && i < len(tail)-1 {
+			return strings.TrimSpace(string(tail[i+1:]))
+		}
+	}
+	return strings.TrimSpace(string(tail))
+}
@@ -21,12 +21,15 @@ type LogConfig struct {
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
+	// 数据库类型: "sqlite"（默认）, "postgres", "none"（不启用持久化）
 	Type     string `yaml:"type"`
+	Path     string `yaml:"path"` // SQLite 数据库文件路径
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	Database string `yaml:"database"`
+	SSLMode  string `yaml:"sslmode"` // PostgreSQL SSL 模式
 }
 
 // KnowledgeConfig 知识库配置
@@ -35,6 +38,84 @@ type KnowledgeConfig struct {
 	Token    string         `yaml:"token"`
 	TopK     int            `yaml:"top_k"`
 	VectorDB VectorDBConfig `yaml:"vector_db"`
+	// 命名知识库，未配置时使用上面的 base_url / token 作为名为 default 的 http 知识库
+	Bases   map[string]KnowledgeBaseConfig `yaml:"bases"`
+	Default string                         `yaml:"default"` // 默认知识库名称
+	Chunk   ChunkConfig                    `yaml:"chunk"`   // 文档导入时的切分配置
+	Hybrid  HybridConfig                   `yaml:"hybrid"`  // 关键词与向量混合检索配置
+	Router  RouterConfig                   `yaml:"router"`  // 多知识库自动路由配置
+	Cache   CacheConfig                    `yaml:"cache"`   // 检索结果缓存配置
+}
+
+// CacheConfig 检索结果缓存配置：按知识库、top_k 和规范化后的查询缓存检索结果
+type CacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Size    int    `yaml:"size"` // 最多缓存的查询数量，超出时淘汰最久未使用的条目
+	TTL     int    `yaml:"ttl"`  // 缓存有效期（秒），0 使用默认值，负数表示不过期
+	Path    string `yaml:"path"` // 持久化文件路径，留空时不持久化；服务退出时保存，启动时加载
+}
+
+// RouterConfig 知识库自动路由配置：请求未指定知识库时根据问题选择要检索的知识库
+type RouterConfig struct {
+	// 路由类型: ""（只检索默认知识库）, "keyword"（按知识库关键词匹配）, "llm"（由对话模型分类，失败时退回关键词匹配）
+	Type     string `yaml:"type"`
+	MaxBases int    `yaml:"max_bases"` // 一次最多检索的知识库数量
+	// 没有匹配的知识库时的处理: "default"（检索默认知识库）, "all"（检索全部知识库）
+	Fallback string `yaml:"fallback"`
+}
+
+// HybridConfig 混合检索配置：本地 BM25 关键词索引与向量/远程检索结果通过倒数排名融合（RRF）合并
+type HybridConfig struct {
+	Enabled       bool    `yaml:"enabled"`
+	KeywordTopK   int     `yaml:"keyword_top_k"`  // 关键词检索的候选数量
+	VectorTopK    int     `yaml:"vector_top_k"`   // 向量/远程检索的候选数量
+	KeywordWeight float64 `yaml:"keyword_weight"` // 关键词检索结果的融合权重
+	VectorWeight  float64 `yaml:"vector_weight"`  // 向量/远程检索结果的融合权重
+	RRFK          int     `yaml:"rrf_k"`          // RRF 平滑常数，越大排名差异的影响越小
+	Dir           string  `yaml:"dir"`            // 关键词索引文件目录，索引文件为 {dir}/{知识库名称}.gob
+}
+
+// ChunkConfig 文档切分配置
+type ChunkConfig struct {
+	Size    int `yaml:"size"`    // 每个片段的目标字符数
+	Overlap int `yaml:"overlap"` // 相邻片段重叠的字符数
+}
+
+// KnowledgeBaseConfig 单个知识库配置
+type KnowledgeBaseConfig struct {
+	// 知识库类型: "http"（通用 HTTP 接口）, "tcvectordb"（腾讯云向量数据库）, "local"（内置本地向量存储）
+	Type    string `yaml:"type"`
+	BaseURL string `yaml:"base_url"`
+	Token   string `yaml:"token"`
+	TopK    int    `yaml:"top_k"` // 为 0 时使用 knowledge.top_k
+	// 路由配置
+	Description string   `yaml:"description"` // 知识库内容说明，用于 LLM 路由和 MCP 工具说明
+	Keywords    []string `yaml:"keywords"`    // 路由关键词，问题包含其中任一关键词时检索该知识库
+	// 向量数据库配置
+	Username   string `yaml:"username"`
+	Database   string `yaml:"database"`
+	Collection string `yaml:"collection"`
+	// 本地向量存储配置
+	Path     string  `yaml:"path"`      // 向量文件路径，默认 data/vectors/{name}.gob
+	MinScore float64 `yaml:"min_score"` // 最低相似度，低于该值的结果被丢弃
+}
+
+// RerankConfig 重排序配置：检索时先多取候选片段，重排序后过滤低相关片段再构建提示词
+type RerankConfig struct {
+	// 重排序类型: ""（不启用）, "llm"（使用对话模型打分）, "http"（通用 /rerank 接口）
+	Type       string  `yaml:"type"`
+	BaseURL    string  `yaml:"base_url"`   // http 类型的完整接口地址，如 http://localhost:8000/v1/rerank
+	APIKey     string  `yaml:"api_key"`    // http 类型的接口密钥
+	Model      string  `yaml:"model"`      // http 类型的重排序模型名称
+	Candidates int     `yaml:"candidates"` // 重排序前检索的候选片段数量
+	TopN       int     `yaml:"top_n"`      // 重排序后保留的片段数量，为 0 时使用 knowledge.top_k
+	MinScore   float64 `yaml:"min_score"`  // 最低相关度（0-1），低于该值的片段被丢弃
+	Timeout    int     `yaml:"timeout"`    // 请求超时时间（秒）
+}
+
+// SegmentConfig 中文分词配置
+type SegmentConfig struct {
+	UserDict string `yaml:"user_dict"` // 用户词典文件路径，每行格式为 "词 [频率]"，用于补充领域词汇
 }
 
 // VectorDBConfig 向量数据库配置
@@ -45,13 +126,40 @@ type VectorDBConfig struct {
 
 // Config 应用配置
 type Config struct {
-	Server    ServerConfig    `yaml:"server"`
-	AI        AIConfig        `yaml:"ai"`
-	RAG       RAGConfig       `yaml:"rag"`
-	Database  DatabaseConfig  `yaml:"database"`
-	Knowledge KnowledgeConfig `yaml:"knowledge"`
-	Log       LogConfig       `yaml:"log"`
-	Captcha   CaptchaConfig   `yaml:"captcha"`
+	Path       string           `yaml:"-"` // 加载的配置文件路径，用于运行时重新加载部分配置
+	Server     ServerConfig     `yaml:"server"`
+	AI         AIConfig         `yaml:"ai"`
+	RAG        RAGConfig        `yaml:"rag"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Knowledge  KnowledgeConfig  `yaml:"knowledge"`
+	Rerank     RerankConfig     `yaml:"rerank"`
+	Segment    SegmentConfig    `yaml:"segment"`
+	Log        LogConfig        `yaml:"log"`
+	Captcha    CaptchaConfig    `yaml:"captcha"`
+	Timeouts   TimeoutsConfig   `yaml:"timeouts"`
+	Resilience ResilienceConfig `yaml:"resilience"`
+}
+
+// TimeoutsConfig 问答请求各阶段的超时时间（秒），负数表示不限制；客户端断开连接时各阶段立即取消
+type TimeoutsConfig struct {
+	Retrieval  int `yaml:"retrieval"`   // 检索阶段：问题改写、知识库路由、检索和重排序
+	FirstToken int `yaml:"first_token"` // 流式回答从调用 AI 服务到收到第一个数据块，超时后切换到下一个提供方
+	Total      int `yaml:"total"`       // 整个请求
+}
+
+// ResilienceConfig 上游服务（AI 服务提供方、知识库、验证码）的重试与熔断配置
+type ResilienceConfig struct {
+	Default   UpstreamPolicy            `yaml:"default"`
+	Upstreams map[string]UpstreamPolicy `yaml:"upstreams"` // 按上游名称覆盖，如 ai/primary、knowledge/web、captcha；未设置的字段使用 default
+}
+
+// UpstreamPolicy 单个上游服务的重试与熔断策略
+type UpstreamPolicy struct {
+	MaxRetries       int `yaml:"max_retries"`       // 失败后的最大重试次数，负数表示不重试
+	BaseDelay        int `yaml:"base_delay"`        // 首次重试前的等待时间（毫秒），之后按指数增长并加入随机抖动
+	MaxDelay         int `yaml:"max_delay"`         // 单次等待的上限（毫秒），Retry-After 超过该值时不再重试
+	FailureThreshold int `yaml:"failure_threshold"` // 连续失败达到该次数后熔断，负数表示不熔断
+	OpenTimeout      int `yaml:"open_timeout"`      // 熔断持续时间（秒），之后放行一个探测请求
 }
 
 // ServerConfig 服务器配置
@@ -59,6 +167,8 @@ type ServerConfig struct {
 	Port         string   `yaml:"port"`
 	Mode         string   `yaml:"mode"`
 	AllowDomains []string `yaml:"allow_domains"`
+	AdminToken   string   `yaml:"admin_token"`   // 管理接口（文档导入等）访问令牌，留空表示禁用管理接口
+	SSEKeepalive int      `yaml:"sse_keepalive"` // 流式问答没有输出时发送 SSE 保活注释的间隔（秒），负数表示不发送
 }
 
 // AIConfig AI 服务配置
@@ -66,11 +176,112 @@ type AIConfig struct {
 	BaseURL string `yaml:"base_url"`
 	APIKey  string `yaml:"api_key"`
 	Model   string `yaml:"model"`
+	// 按顺序尝试的服务提供方，连接失败、5xx 或 429 时切换到下一个；未配置时使用上面的 base_url / api_key
+	Providers []ProviderConfig `yaml:"providers"`
+	// Embedding 配置，base_url / api_key 留空时与对话模型共用
+	EmbeddingBaseURL string `yaml:"embedding_base_url"`
+	EmbeddingAPIKey  string `yaml:"embedding_api_key"`
+	EmbeddingModel   string `yaml:"embedding_model"`
+	// 默认生成参数，max_tokens 同时用于提示词预算，构建提示词时为回复预留同样的空间
+	GenerationConfig `yaml:",inline"`
+	// 提示词预算配置
+	ContextWindow  int            `yaml:"context_window"`  // 模型上下文窗口（token），为 0 时按模型名称推断
+	ContextWindows map[string]int `yaml:"context_windows"` // 按模型名称前缀配置上下文窗口，优先于内置的窗口大小
+	// 流式回答时请求服务提供方在最后一个数据块中返回 token 用量（stream_options.include_usage），
+	// 需要所有提供方都支持；未开启或提供方未返回时按字数估算
+	StreamUsage bool `yaml:"stream_usage"`
+	// 模型配置，键为配置名称；请求可以通过 model 字段选择其中之一，未设置的生成参数使用上面的默认值
+	Profiles map[string]ModelProfile `yaml:"profiles"`
+	// 允许请求覆盖的生成参数及范围
+	RequestLimits GenerationLimits `yaml:"request_limits"`
+}
+
+// ProviderConfig 对话模型服务提供方配置
+type ProviderConfig struct {
+	Name    string `yaml:"name"` // 名称，记录在日志和响应中，为空时为 provider1、provider2…
+	BaseURL string `yaml:"base_url"`
+	APIKey  string `yaml:"api_key"`
+	Model   string `yaml:"model"` // 请求使用 ai.model 时该提供方改用的模型名称，为空时使用请求的模型
+}
+
+// GenerationConfig 生成参数，指针类型的参数未设置时不发送，使用模型服务的默认值
+type GenerationConfig struct {
+	MaxTokens        int      `yaml:"max_tokens"` // 0 使用默认值（模型配置中为沿用默认参数），负数表示不限制
+	Temperature      *float32 `yaml:"temperature"`
+	TopP             *float32 `yaml:"top_p"`
+	Stop             []string `yaml:"stop"`
+	PresencePenalty  *float32 `yaml:"presence_penalty"`
+	FrequencyPenalty *float32 `yaml:"frequency_penalty"`
+	Seed             *int     `yaml:"seed"`
+}
+
+// ModelProfile 模型配置：可供请求选择的模型及其生成参数
+type ModelProfile struct {
+	Model            string   `yaml:"model"`        // 模型名称，为空时与配置名称相同
+	DisplayName      string   `yaml:"display_name"` // 展示名称，为空时与配置名称相同
+	Description      string   `yaml:"description"`
+	Capabilities     []string `yaml:"capabilities"` // 模型能力，如 reasoning（输出思考过程）、tools（函数调用）
+	GenerationConfig `yaml:",inline"`
+}
+
+// GenerationLimits 请求可以覆盖的生成参数范围，未配置范围的参数不允许请求覆盖
+type GenerationLimits struct {
+	MaxTokens        *ParamRange `yaml:"max_tokens"`
+	Temperature      *ParamRange `yaml:"temperature"`
+	TopP             *ParamRange `yaml:"top_p"`
+	PresencePenalty  *ParamRange `yaml:"presence_penalty"`
+	FrequencyPenalty *ParamRange `yaml:"frequency_penalty"`
+	MaxStop          int         `yaml:"max_stop"` // 请求最多可以指定的停止序列数，为 0 时不允许
+	Seed             bool        `yaml:"seed"`     // 是否允许请求指定随机种子
+}
+
+// ParamRange 参数取值范围（闭区间）
+type ParamRange struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
 }
 
 // RAGConfig RAG 服务配置
 type RAGConfig struct {
-	SystemPrompt string `yaml:"system_prompt"`
+	SystemPrompt    string        `yaml:"system_prompt"`
+	Prompts         PromptsConfig `yaml:"prompts"`           // 提示词模板
+	MaxHistoryTurns int           `yaml:"max_history_turns"` // 携带的最大历史轮数（一问一答为一轮），负数表示不携带历史
+	MaxHistoryChars int           `yaml:"max_history_chars"` // 携带的历史消息最大总字符数，负数表示不限制
+	// 多轮对话时是否先结合历史将追问改写为独立的检索问题
+	QueryRewrite       bool              `yaml:"query_rewrite"`
+	QueryRewritePrompt string            `yaml:"query_rewrite_prompt"`
+	AnswerCache        AnswerCacheConfig `yaml:"answer_cache"` // 语义答案缓存配置
+	NoKnowledge        NoKnowledgeConfig `yaml:"no_knowledge"` // 未检索到相关知识时的处理策略
+	// 提示词超出模型上下文窗口时，知识库内容至少可以使用的剩余空间比例（0-1），其余留给历史对话
+	KnowledgeBudgetRatio float64 `yaml:"knowledge_budget_ratio"`
+}
+
+// PromptsConfig 提示词模板配置（Go text/template 语法），设置了 *_file 时从文件加载，优先于内联模板
+type PromptsConfig struct {
+	System        string `yaml:"system"` // 系统提示词模板，为空时使用 rag.system_prompt
+	SystemFile    string `yaml:"system_file"`
+	Context       string `yaml:"context"` // 检索到知识时的用户消息模板
+	ContextFile   string `yaml:"context_file"`
+	NoContext     string `yaml:"no_context"` // 未检索到知识时的用户消息模板
+	NoContextFile string `yaml:"no_context_file"`
+}
+
+// NoKnowledgeConfig 未检索到相关知识（检索失败或结果为空）时的处理策略
+type NoKnowledgeConfig struct {
+	// Policy 处理策略：answer（由 AI 直接回答）、disclaimer（回答前附加提示）、refuse（返回固定回复，不调用 AI）、fallback（检索备用知识库）
+	Policy         string   `yaml:"policy"`
+	Disclaimer     string   `yaml:"disclaimer"`      // disclaimer 策略附加在回答前的提示
+	Message        string   `yaml:"message"`         // refuse 策略的固定回复
+	FallbackBases  []string `yaml:"fallback_bases"`  // fallback 策略检索的备用知识库
+	FallbackPolicy string   `yaml:"fallback_policy"` // 备用知识库同样没有结果时的策略：answer、disclaimer 或 refuse
+}
+
+// AnswerCacheConfig 语义答案缓存配置：新问题与已回答问题的向量相似度达到阈值时直接返回之前生成的回答
+type AnswerCacheConfig struct {
+	Enabled   bool    `yaml:"enabled"`
+	Threshold float64 `yaml:"threshold"` // 余弦相似度阈值（0-1），越高越严格
+	Size      int     `yaml:"size"`      // 最多缓存的回答数量，超出时淘汰最久未使用的回答
+	TTL       int     `yaml:"ttl"`       // 回答有效期（秒）
 }
 
 // CaptchaConfig 验证码配置
@@ -121,7 +332,7 @@ func LoadConfig(configPath string) (*Config, error) {
 		}
 	}
 
-	config := &Config{}
+	config := &Config{Path: configPath}
 
 	// 如果配置文件存在，从文件加载
 	if fileExists(configPath) {
@@ -136,6 +347,16 @@ func LoadConfig(configPath string) (*Config, error) {
 	// 环境变量覆盖配置文件设置
 	overrideWithEnv(config)
 
+	// 补全依赖环境变量的派生配置
+	setAIProviders(config)
+	setKnowledgeBases(config)
+	setUpstreamPolicies(config)
+	setHistoryLimits(config)
+	setTimeouts(config)
+	setSSEKeepalive(config)
+	setCacheTTL(config)
+	setMaxTokens(config)
+
 	return config, nil
 }
 
@@ -169,6 +390,14 @@ func overrideWithEnv(config *Config) {
 			config.Server.AllowDomains[i] = strings.TrimSpace(domain)
 		}
 	}
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		config.Server.AdminToken = adminToken
+	}
+	if keepalive := os.Getenv("SSE_KEEPALIVE"); keepalive != "" {
+		if n, err := strconv.Atoi(keepalive); err == nil {
+			config.Server.SSEKeepalive = n
+		}
+	}
 	// 向后兼容：如果设置了 ALLOW_DOMAIN 但没有设置 ALLOW_DOMAINS，则转换为数组
 	if allowDomain := os.Getenv("ALLOW_DOMAIN"); allowDomain != "" && len(config.Server.AllowDomains) == 0 {
 		config.Server.AllowDomains = []string{allowDomain}
@@ -191,6 +420,36 @@ func overrideWithEnv(config *Config) {
 	if model := os.Getenv("AI_MODEL"); model != "" {
 		config.AI.Model = model
 	}
+	if embeddingBaseURL := os.Getenv("AI_EMBEDDING_BASE_URL"); embeddingBaseURL != "" {
+		config.AI.EmbeddingBaseURL = embeddingBaseURL
+	}
+	if embeddingAPIKey := os.Getenv("AI_EMBEDDING_API_KEY"); embeddingAPIKey != "" {
+		config.AI.EmbeddingAPIKey = embeddingAPIKey
+	}
+	if embeddingModel := os.Getenv("AI_EMBEDDING_MODEL"); embeddingModel != "" {
+		config.AI.EmbeddingModel = embeddingModel
+	}
+	if maxTokens := os.Getenv("AI_MAX_TOKENS"); maxTokens != "" {
+		if n, err := strconv.Atoi(maxTokens); err == nil {
+			config.AI.MaxTokens = n
+		}
+	}
+	if temperature := os.Getenv("AI_TEMPERATURE"); temperature != "" {
+		if f, err := strconv.ParseFloat(temperature, 32); err == nil {
+			t := float32(f)
+			config.AI.Temperature = &t
+		}
+	}
+	if contextWindow := os.Getenv("AI_CONTEXT_WINDOW"); contextWindow != "" {
+		if n, err := strconv.Atoi(contextWindow); err == nil {
+			config.AI.ContextWindow = n
+		}
+	}
+	if streamUsage := os.Getenv("AI_STREAM_USAGE"); streamUsage != "" {
+		if enabled, err := strconv.ParseBool(streamUsage); err == nil {
+			config.AI.StreamUsage = enabled
+		}
+	}
 
 	// 知识库配置
 	if baseURL := os.Getenv("KNOWLEDGE_BASE_URL"); baseURL != "" {
@@ -204,11 +463,149 @@ func overrideWithEnv(config *Config) {
 			config.Knowledge.TopK = k
 		}
 	}
+	if chunkSize := os.Getenv("KNOWLEDGE_CHUNK_SIZE"); chunkSize != "" {
+		if n, err := strconv.Atoi(chunkSize); err == nil {
+			config.Knowledge.Chunk.Size = n
+		}
+	}
+	if chunkOverlap := os.Getenv("KNOWLEDGE_CHUNK_OVERLAP"); chunkOverlap != "" {
+		if n, err := strconv.Atoi(chunkOverlap); err == nil {
+			config.Knowledge.Chunk.Overlap = n
+		}
+	}
+	if hybrid := os.Getenv("KNOWLEDGE_HYBRID"); hybrid != "" {
+		if enabled, err := strconv.ParseBool(hybrid); err == nil {
+			config.Knowledge.Hybrid.Enabled = enabled
+		}
+	}
+
+	if router := os.Getenv("KNOWLEDGE_ROUTER"); router != "" {
+		config.Knowledge.Router.Type = router
+	}
+	if cacheEnabled := os.Getenv("KNOWLEDGE_CACHE"); cacheEnabled != "" {
+		if enabled, err := strconv.ParseBool(cacheEnabled); err == nil {
+			config.Knowledge.Cache.Enabled = enabled
+		}
+	}
+	if cacheTTL := os.Getenv("KNOWLEDGE_CACHE_TTL"); cacheTTL != "" {
+		if n, err := strconv.Atoi(cacheTTL); err == nil {
+			config.Knowledge.Cache.TTL = n
+		}
+	}
 
 	// RAG 配置
 	if systemPrompt := os.Getenv("RAG_SYSTEM_PROMPT"); systemPrompt != "" {
 		config.RAG.SystemPrompt = systemPrompt
 	}
+	if maxTurns := os.Getenv("RAG_MAX_HISTORY_TURNS"); maxTurns != "" {
+		if n, err := strconv.Atoi(maxTurns); err == nil {
+			config.RAG.MaxHistoryTurns = n
+		}
+	}
+	if maxChars := os.Getenv("RAG_MAX_HISTORY_CHARS"); maxChars != "" {
+		if n, err := strconv.Atoi(maxChars); err == nil {
+			config.RAG.MaxHistoryChars = n
+		}
+	}
+	if queryRewrite := os.Getenv("RAG_QUERY_REWRITE"); queryRewrite != "" {
+		if enabled, err := strconv.ParseBool(queryRewrite); err == nil {
+			config.RAG.QueryRewrite = enabled
+		}
+	}
+	if answerCache := os.Getenv("RAG_ANSWER_CACHE"); answerCache != "" {
+		if enabled, err := strconv.ParseBool(answerCache); err == nil {
+			config.RAG.AnswerCache.Enabled = enabled
+		}
+	}
+	if threshold := os.Getenv("RAG_ANSWER_CACHE_THRESHOLD"); threshold != "" {
+		if f, err := strconv.ParseFloat(threshold, 64); err == nil {
+			config.RAG.AnswerCache.Threshold = f
+		}
+	}
+	if policy := os.Getenv("RAG_NO_KNOWLEDGE_POLICY"); policy != "" {
+		config.RAG.NoKnowledge.Policy = policy
+	}
+	if bases := os.Getenv("RAG_NO_KNOWLEDGE_FALLBACK_BASES"); bases != "" {
+		config.RAG.NoKnowledge.FallbackBases = strings.Split(bases, ",")
+	}
+
+	// 数据库配置
+	if dbType := os.Getenv("DB_TYPE"); dbType != "" {
+		config.Database.Type = dbType
+	}
+	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
+		config.Database.Path = dbPath
+	}
+	if dbHost := os.Getenv("DB_HOST"); dbHost != "" {
+		config.Database.Host = dbHost
+	}
+	if dbPort := os.Getenv("DB_PORT"); dbPort != "" {
+		if port, err := strconv.Atoi(dbPort); err == nil {
+			config.Database.Port = port
+		}
+	}
+	if dbUser := os.Getenv("DB_USERNAME"); dbUser != "" {
+		config.Database.Username = dbUser
+	}
+	if dbPassword := os.Getenv("DB_PASSWORD"); dbPassword != "" {
+		config.Database.Password = dbPassword
+	}
+	if dbName := os.Getenv("DB_DATABASE"); dbName != "" {
+		config.Database.Database = dbName
+	}
+
+	// 重排序配置
+	if rerankType := os.Getenv("RERANK_TYPE"); rerankType != "" {
+		config.Rerank.Type = rerankType
+	}
+	if rerankBaseURL := os.Getenv("RERANK_BASE_URL"); rerankBaseURL != "" {
+		config.Rerank.BaseURL = rerankBaseURL
+	}
+	if rerankAPIKey := os.Getenv("RERANK_API_KEY"); rerankAPIKey != "" {
+		config.Rerank.APIKey = rerankAPIKey
+	}
+	if rerankModel := os.Getenv("RERANK_MODEL"); rerankModel != "" {
+		config.Rerank.Model = rerankModel
+	}
+	if minScore := os.Getenv("RERANK_MIN_SCORE"); minScore != "" {
+		if f, err := strconv.ParseFloat(minScore, 64); err == nil {
+			config.Rerank.MinScore = f
+		}
+	}
+
+	// 中文分词配置
+	if userDict := os.Getenv("SEGMENT_USER_DICT"); userDict != "" {
+		config.Segment.UserDict = userDict
+	}
+
+	// 超时配置
+	if retrieval := os.Getenv("TIMEOUT_RETRIEVAL"); retrieval != "" {
+		if n, err := strconv.Atoi(retrieval); err == nil {
+			config.Timeouts.Retrieval = n
+		}
+	}
+	if firstToken := os.Getenv("TIMEOUT_FIRST_TOKEN"); firstToken != "" {
+		if n, err := strconv.Atoi(firstToken); err == nil {
+			config.Timeouts.FirstToken = n
+		}
+	}
+	if total := os.Getenv("TIMEOUT_TOTAL"); total != "" {
+		if n, err := strconv.Atoi(total); err == nil {
+			config.Timeouts.Total = n
+		}
+	}
+
+	// 重试与熔断配置
+	if maxRetries := os.Getenv("RESILIENCE_MAX_RETRIES"); maxRetries != "" {
+		if n, err := strconv.Atoi(maxRetries); err == nil {
+			config.Resilience.Default.MaxRetries = n
+		}
+	}
+	if threshold := os.Getenv("RESILIENCE_FAILURE_THRESHOLD"); threshold != "" {
+		if n, err := strconv.Atoi(threshold); err == nil {
+			config.Resilience.Default.FailureThreshold = n
+		}
+	}
 
 	// 日志配置
 	if logDir := os.Getenv("LOG_DIR"); logDir != "" {
@@ -302,10 +699,113 @@ func getEnv(key, defaultValue string) string {
 
 // setDefaults 设置默认配置值
 func setDefaults(config *Config) {
+	// AI 默认配置
+	if config.AI.EmbeddingModel == "" {
+		config.AI.EmbeddingModel = "text-embedding-3-small"
+	}
+	if config.AI.Temperature == nil {
+		temperature := float32(0.7)
+		config.AI.Temperature = &temperature
+	}
+
 	// 知识库默认配置
 	if config.Knowledge.TopK == 0 {
 		config.Knowledge.TopK = 3
 	}
+	if config.Knowledge.Chunk.Size == 0 {
+		config.Knowledge.Chunk.Size = 800
+	}
+	if config.Knowledge.Chunk.Overlap == 0 {
+		config.Knowledge.Chunk.Overlap = 100
+	}
+	if config.Knowledge.Hybrid.KeywordTopK == 0 {
+		config.Knowledge.Hybrid.KeywordTopK = 10
+	}
+	if config.Knowledge.Hybrid.VectorTopK == 0 {
+		config.Knowledge.Hybrid.VectorTopK = 10
+	}
+	if config.Knowledge.Hybrid.KeywordWeight == 0 {
+		config.Knowledge.Hybrid.KeywordWeight = 1
+	}
+	if config.Knowledge.Hybrid.VectorWeight == 0 {
+		config.Knowledge.Hybrid.VectorWeight = 1
+	}
+	if config.Knowledge.Hybrid.RRFK == 0 {
+		config.Knowledge.Hybrid.RRFK = 60
+	}
+	if config.Knowledge.Hybrid.Dir == "" {
+		config.Knowledge.Hybrid.Dir = "data/keyword"
+	}
+	if config.Knowledge.Router.MaxBases == 0 {
+		config.Knowledge.Router.MaxBases = 2
+	}
+	if config.Knowledge.Router.Fallback == "" {
+		config.Knowledge.Router.Fallback = "default"
+	}
+	if config.Knowledge.Cache.Size == 0 {
+		config.Knowledge.Cache.Size = 1000
+	}
+
+	// 重排序默认配置
+	if config.Rerank.Candidates == 0 {
+		config.Rerank.Candidates = 20
+	}
+	if config.Rerank.Timeout == 0 {
+		config.Rerank.Timeout = 30
+	}
+
+	// RAG 默认配置
+	if config.RAG.QueryRewritePrompt == "" {
+		config.RAG.QueryRewritePrompt = "你是检索问题改写助手。请结合对话历史，将用户的后续问题改写为一个不依赖上下文、可以直接用于知识库检索的独立问题。" +
+			"补全代词和省略的主语，保留专有名词、配置项和代码标识符原样，不要回答问题，只输出改写后的问题。"
+	}
+
+	if config.RAG.Prompts.Context == "" {
+		config.RAG.Prompts.Context = "参考知识库内容（引用时请使用对应编号，如 [1]）：\n{{.Context}}\n\n用户问题：{{.Query}}"
+	}
+	if config.RAG.Prompts.NoContext == "" {
+		config.RAG.Prompts.NoContext = "{{.Query}}"
+	}
+
+	if config.RAG.KnowledgeBudgetRatio == 0 {
+		config.RAG.KnowledgeBudgetRatio = 0.6
+	}
+	if config.RAG.NoKnowledge.Policy == "" {
+		config.RAG.NoKnowledge.Policy = "answer"
+	}
+	if config.RAG.NoKnowledge.Disclaimer == "" {
+		config.RAG.NoKnowledge.Disclaimer = "> 知识库中没有找到相关内容，以下回答由 AI 根据通用知识生成，请注意甄别。"
+	}
+	if config.RAG.NoKnowledge.Message == "" {
+		config.RAG.NoKnowledge.Message = "抱歉，知识库中没有找到与该问题相关的内容，请尝试换个问法或查阅官方文档。"
+	}
+	if config.RAG.NoKnowledge.FallbackPolicy == "" {
+		config.RAG.NoKnowledge.FallbackPolicy = "disclaimer"
+	}
+
+	if config.RAG.AnswerCache.Threshold == 0 {
+		config.RAG.AnswerCache.Threshold = 0.92
+	}
+	if config.RAG.AnswerCache.Size == 0 {
+		config.RAG.AnswerCache.Size = 500
+	}
+	if config.RAG.AnswerCache.TTL == 0 {
+		config.RAG.AnswerCache.TTL = 86400
+	}
+
+	// 数据库默认配置
+	if config.Database.Type == "" {
+		config.Database.Type = "sqlite"
+	}
+	if config.Database.Path == "" {
+		config.Database.Path = "data/knowledge-maker.db"
+	}
+	if config.Database.Port == 0 {
+		config.Database.Port = 5432
+	}
+	if config.Database.SSLMode == "" {
+		config.Database.SSLMode = "disable"
+	}
 
 	// 验证码默认配置 - 如果没有设置验证类型，则不进行验证码校验
 	// 不再设置默认的验证码类型，保持为空表示不启用验证码
@@ -331,6 +831,135 @@ func setDefaults(config *Config) {
 	}
 }
 
+// setAIProviders 补全对话模型服务提供方配置
+func setAIProviders(config *Config) {
+	// 向后兼容：未配置服务提供方时，使用 base_url / api_key 作为唯一的提供方
+	if len(config.AI.Providers) == 0 {
+		config.AI.Providers = []ProviderConfig{{
+			Name:    "default",
+			BaseURL: config.AI.BaseURL,
+			APIKey:  config.AI.APIKey,
+		}}
+	}
+	for i := range config.AI.Providers {
+		if config.AI.Providers[i].Name == "" {
+			config.AI.Providers[i].Name = fmt.Sprintf("provider%d", i+1)
+		}
+	}
+	if config.AI.Model == "" {
+		config.AI.Model = config.AI.Providers[0].Model
+	}
+}
+
+// setKnowledgeBases 补全命名知识库配置
+func setKnowledgeBases(config *Config) {
+	// 向后兼容：未配置命名知识库时，使用 base_url / token 作为默认知识库
+	if len(config.Knowledge.Bases) == 0 {
+		config.Knowledge.Bases = map[string]KnowledgeBaseConfig{
+			"default": {
+				Type:    "http",
+				BaseURL: config.Knowledge.BaseURL,
+				Token:   config.Knowledge.Token,
+			},
+		}
+	}
+	for name, base := range config.Knowledge.Bases {
+		if base.Type == "" {
+			base.Type = "http"
+			config.Knowledge.Bases[name] = base
+		}
+	}
+}
+
+// setHistoryLimits 历史对话限制为 0（未设置）时使用默认值；在环境变量覆盖之后执行，
+// 配置文件和环境变量中的 0 含义相同，不携带历史或不限制字符数使用负数
+func setHistoryLimits(config *Config) {
+	if config.RAG.MaxHistoryTurns == 0 {
+		config.RAG.MaxHistoryTurns = 5
+	}
+	if config.RAG.MaxHistoryChars == 0 {
+		config.RAG.MaxHistoryChars = 4000
+	}
+}
+
+// setSSEKeepalive 保活间隔为 0（未设置）时使用默认值；在环境变量覆盖之后执行，
+// 配置文件和环境变量中的 0 含义相同，不发送保活注释使用负数
+func setSSEKeepalive(config *Config) {
+	if config.Server.SSEKeepalive == 0 {
+		config.Server.SSEKeepalive = 15
+	}
+}
+
+// setCacheTTL 检索缓存有效期为 0（未设置）时使用默认值；在环境变量覆盖之后执行，
+// 配置文件和环境变量中的 0 含义相同，永不过期使用负数
+func setCacheTTL(config *Config) {
+	if config.Knowledge.Cache.TTL == 0 {
+		config.Knowledge.Cache.TTL = 600
+	}
+}
+
+// setMaxTokens 回复的最大 token 数为 0（未设置）时使用默认值；在环境变量覆盖之后执行，
+// 配置文件和环境变量中的 0 含义相同，不限制使用负数
+func setMaxTokens(config *Config) {
+	if config.AI.MaxTokens == 0 {
+		config.AI.MaxTokens = 2000
+	}
+}
+
+// setTimeouts 超时时间为 0（未设置）时使用默认值；在环境变量覆盖之后执行，
+// 配置文件和环境变量中的 0 含义相同，不限时使用负数
+func setTimeouts(config *Config) {
+	if config.Timeouts.Retrieval == 0 {
+		config.Timeouts.Retrieval = 15
+	}
+	if config.Timeouts.FirstToken == 0 {
+		config.Timeouts.FirstToken = 60
+	}
+	if config.Timeouts.Total == 0 {
+		config.Timeouts.Total = 300
+	}
+}
+
+// setUpstreamPolicies 默认策略中为 0（未设置）的字段使用内置默认值，按上游名称覆盖的策略中为 0 的字段使用默认策略；
+// 在环境变量覆盖之后执行，配置文件和环境变量中的 0 含义相同，不重试或不熔断使用负数
+func setUpstreamPolicies(config *Config) {
+	def := &config.Resilience.Default
+	if def.MaxRetries == 0 {
+		def.MaxRetries = 2
+	}
+	if def.BaseDelay == 0 {
+		def.BaseDelay = 200
+	}
+	if def.MaxDelay == 0 {
+		def.MaxDelay = 5000
+	}
+	if def.FailureThreshold == 0 {
+		def.FailureThreshold = 5
+	}
+	if def.OpenTimeout == 0 {
+		def.OpenTimeout = 30
+	}
+
+	for name, policy := range config.Resilience.Upstreams {
+		if policy.MaxRetries == 0 {
+			policy.MaxRetries = def.MaxRetries
+		}
+		if policy.BaseDelay == 0 {
+			policy.BaseDelay = def.BaseDelay
+		}
+		if policy.MaxDelay == 0 {
+			policy.MaxDelay = def.MaxDelay
+		}
+		if policy.FailureThreshold == 0 {
+			policy.FailureThreshold = def.FailureThreshold
+		}
+		if policy.OpenTimeout == 0 {
+			policy.OpenTimeout = def.OpenTimeout
+		}
+		config.Resilience.Upstreams[name] = policy
+	}
+}
+
 // fileExists 检查文件是否存在
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
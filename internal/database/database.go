@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"knowledge-maker/internal/config"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DB 数据库封装，屏蔽 SQLite 与 PostgreSQL 之间的差异
+type DB struct {
+	*sql.DB
+	dbType string
+}
+
+// Open 根据配置打开数据库连接
+func Open(cfg *config.DatabaseConfig) (*DB, error) {
+	var (
+		driver string
+		dsn    string
+	)
+
+	switch strings.ToLower(cfg.Type) {
+	case "sqlite", "sqlite3":
+		// 确保数据库文件所在目录存在
+		if dir := filepath.Dir(cfg.Path); dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+			}
+		}
+		driver = "sqlite3"
+		dsn = fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on", cfg.Path)
+	case "postgres", "postgresql":
+		driver = "postgres"
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.Database, cfg.SSLMode)
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型: %s", cfg.Type)
+	}
+
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+
+	// SQLite 只允许单个写连接，避免 database is locked
+	if driver == "sqlite3" {
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("连接数据库失败: %v", err)
+	}
+
+	return &DB{
+		DB:     sqlDB,
+		dbType: driver,
+	}, nil
+}
+
+// Type 返回数据库驱动类型（sqlite3 或 postgres）
+func (db *DB) Type() string {
+	return db.dbType
+}
+
+// Rebind 将 ? 占位符转换为当前数据库支持的格式
+func (db *DB) Rebind(query string) string {
+	if db.dbType != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
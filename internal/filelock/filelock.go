@@ -0,0 +1,53 @@
+// Package filelock 进程间排他文件锁，防止服务和命令行工具同时打开同一个本地存储文件：
+// 本地存储全部常驻内存、变更后整体写回磁盘，两个进程各自持有一份副本时会互相覆盖对方的写入
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLocked 锁已被其他进程持有
+var ErrLocked = errors.New("已被其他进程锁定")
+
+// Lock 已持有的文件锁，进程退出时自动释放
+type Lock struct {
+	f *os.File
+}
+
+// Acquire 以非阻塞方式获取 path 的排他锁，锁文件不存在时创建；已被其他进程持有时返回 ErrLocked
+func Acquire(path string) (*Lock, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建锁文件目录失败: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %v", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%s %w", path, ErrLocked)
+		}
+		return nil, fmt.Errorf("锁定 %s 失败: %v", path, err)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release 释放锁
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// lockFile 非 Unix 平台不加锁，需要自行避免多个进程同时打开同一个本地存储
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile 非 Unix 平台不加锁
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheHandler 缓存管理处理器，管理检索结果缓存和语义答案缓存
+type CacheHandler struct {
+	knowledgeService *service.KnowledgeService
+	ragService       *service.RAGService
+}
+
+// NewCacheHandler 创建缓存管理处理器实例
+func NewCacheHandler(knowledgeService *service.KnowledgeService, ragService *service.RAGService) *CacheHandler {
+	return &CacheHandler{
+		knowledgeService: knowledgeService,
+		ragService:       ragService,
+	}
+}
+
+// HandleStats 获取缓存统计信息
+func (h *CacheHandler) HandleStats(c *gin.Context) {
+	stats := h.knowledgeService.CacheStats()
+	answers := h.ragService.AnswerCacheStats()
+	c.JSON(http.StatusOK, model.CacheResponse{
+		Success: true,
+		Stats:   &stats,
+		Answers: &answers,
+	})
+}
+
+// HandlePurge 清除检索结果缓存和缓存回答，可通过 knowledge_base 参数只清除与指定知识库相关的缓存
+func (h *CacheHandler) HandlePurge(c *gin.Context) {
+	name := c.Query("knowledge_base")
+	purged, err := h.knowledgeService.PurgeCache(name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrKnowledgeBaseNotFound) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, model.CacheResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	purged += h.ragService.PurgeAnswerCache(name)
+
+	stats := h.knowledgeService.CacheStats()
+	answers := h.ragService.AnswerCacheStats()
+	c.JSON(http.StatusOK, model.CacheResponse{
+		Success: true,
+		Stats:   &stats,
+		Answers: &answers,
+		Purged:  purged,
+		Message: "缓存已清除",
+	})
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIDHeader 客户端标识请求头，用于隔离不同客户端的会话
+const clientIDHeader = "X-Client-Id"
+
+// ConversationHandler 会话处理器
+type ConversationHandler struct {
+	conversationService *service.ConversationService
+}
+
+// NewConversationHandler 创建会话处理器实例
+func NewConversationHandler(conversationService *service.ConversationService) *ConversationHandler {
+	return &ConversationHandler{
+		conversationService: conversationService,
+	}
+}
+
+// HandleCreate 处理创建会话请求
+func (h *ConversationHandler) HandleCreate(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	var req model.CreateConversationRequest
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ConversationResponse{
+				Success: false,
+				Message: "请求参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	conv, err := h.conversationService.CreateConversation(c.GetHeader(clientIDHeader), req.Title)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ConversationResponse{
+		Success:      true,
+		Conversation: &model.ConversationDetail{Conversation: *conv, Messages: []model.ConversationMessage{}},
+	})
+}
+
+// HandleList 处理会话列表请求
+func (h *ConversationHandler) HandleList(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	clientID := c.GetHeader(clientIDHeader)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	conversations, err := h.conversationService.ListConversations(clientID, limit, offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ConversationResponse{
+		Success:       true,
+		Conversations: conversations,
+	})
+}
+
+// HandleGet 处理获取会话详情请求
+func (h *ConversationHandler) HandleGet(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	detail, err := h.conversationService.GetConversation(c.GetHeader(clientIDHeader), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ConversationResponse{
+		Success:      true,
+		Conversation: detail,
+	})
+}
+
+// HandleDelete 处理删除会话请求
+func (h *ConversationHandler) HandleDelete(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	if err := h.conversationService.DeleteConversation(c.GetHeader(clientIDHeader), c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ConversationResponse{
+		Success: true,
+		Message: "会话已删除",
+	})
+}
+
+// checkEnabled 检查会话功能是否启用
+func (h *ConversationHandler) checkEnabled(c *gin.Context) bool {
+	if h.conversationService == nil {
+		c.JSON(http.StatusServiceUnavailable, model.ConversationResponse{
+			Success: false,
+			Message: service.ErrConversationDisabled.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// respondError 返回会话操作错误
+func (h *ConversationHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrClientIDRequired):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, model.ConversationResponse{
+		Success: false,
+		Message: err.Error(),
+	})
+}
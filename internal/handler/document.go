@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDocumentSize 单个文档的最大字节数
+const maxDocumentSize = 10 << 20
+
+// DocumentHandler 文档管理处理器
+type DocumentHandler struct {
+	documentService *service.DocumentService
+}
+
+// NewDocumentHandler 创建文档管理处理器实例
+func NewDocumentHandler(documentService *service.DocumentService) *DocumentHandler {
+	return &DocumentHandler{
+		documentService: documentService,
+	}
+}
+
+// HandleIngest 处理文档导入请求，支持 JSON 请求体或 multipart 文件上传（字段名 file）
+func (h *DocumentHandler) HandleIngest(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	var req model.IngestDocumentRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if err := h.bindUpload(c, &req); err != nil {
+			c.JSON(http.StatusBadRequest, model.DocumentResponse{
+				Success: false,
+				Message: "请求参数错误: " + err.Error(),
+			})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.DocumentResponse{
+			Success: false,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	doc, status, err := h.documentService.IngestDocument(c.Request.Context(), req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.DocumentResponse{
+		Success:  true,
+		Status:   status,
+		Document: &model.DocumentDetail{Document: *doc},
+	})
+}
+
+// HandleList 处理文档列表请求
+func (h *DocumentHandler) HandleList(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	documents, err := h.documentService.ListDocuments(c.Query("knowledge_base"), limit, offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.DocumentResponse{
+		Success:   true,
+		Documents: documents,
+	})
+}
+
+// HandleGet 处理获取文档详情请求
+func (h *DocumentHandler) HandleGet(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	doc, err := h.documentService.GetDocument(c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.DocumentResponse{
+		Success:  true,
+		Document: doc,
+	})
+}
+
+// HandleDelete 处理删除文档请求
+func (h *DocumentHandler) HandleDelete(c *gin.Context) {
+	if !h.checkEnabled(c) {
+		return
+	}
+
+	if err := h.documentService.DeleteDocument(c.Request.Context(), c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.DocumentResponse{
+		Success: true,
+		Message: "文档已删除",
+	})
+}
+
+// bindUpload 解析 multipart 上传请求，其余字段以表单字段传递
+func (h *DocumentHandler) bindUpload(c *gin.Context, req *model.IngestDocumentRequest) error {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return err
+	}
+	if fileHeader.Size > maxDocumentSize {
+		return errors.New("文件大小超过限制（10MB）")
+	}
+
+	f, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxDocumentSize))
+	if err != nil {
+		return err
+	}
+
+	req.ID = c.PostForm("id")
+	req.KnowledgeBase = c.PostForm("knowledge_base")
+	req.Title = c.PostForm("title")
+	req.URL = c.PostForm("url")
+	req.Source = c.DefaultPostForm("source", fileHeader.Filename)
+	req.ContentType = c.PostForm("content_type")
+	req.Content = string(content)
+	return nil
+}
+
+// checkEnabled 检查文档管理功能是否启用
+func (h *DocumentHandler) checkEnabled(c *gin.Context) bool {
+	if h.documentService == nil {
+		c.JSON(http.StatusServiceUnavailable, model.DocumentResponse{
+			Success: false,
+			Message: "文档管理功能未启用（需要配置数据库）",
+		})
+		return false
+	}
+	return true
+}
+
+// respondError 返回文档操作错误
+func (h *DocumentHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrDocumentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidDocument), errors.Is(err, service.ErrKnowledgeBaseNotFound),
+		errors.Is(err, service.ErrIndexNotSupported):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, model.DocumentResponse{
+		Success: false,
+		Message: err.Error(),
+	})
+}
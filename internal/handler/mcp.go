@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -45,9 +46,13 @@ func (h *MCPHandler) HandleCallTool(c *gin.Context) {
 
 	logger.Info("[MCP Handler] 工具调用请求: %s", req.ToolName)
 
-	result, err := h.mcpService.CallTool(req.ToolName, req.Arguments)
+	result, err := h.mcpService.CallTool(c.Request.Context(), req.ToolName, req.Arguments)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.MCPToolCallResponse{
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrTimeout) {
+			status = http.StatusGatewayTimeout
+		}
+		c.JSON(status, model.MCPToolCallResponse{
 			Success: false,
 			Message: fmt.Sprintf("工具调用失败: %v", err),
 		})
@@ -82,9 +87,16 @@ func (h *MCPHandler) HandleLLMChat(c *gin.Context) {
 
 // handleLLMNonStreamChat 处理非流式 LLM 聊天
 func (h *MCPHandler) handleLLMNonStreamChat(c *gin.Context, req model.LLMChatRequest) {
-	resp, err := h.mcpService.LLMChat(req)
+	resp, err := h.mcpService.LLMChat(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, resp)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrInvalidGeneration), errors.Is(err, service.ErrModelNotAllowed):
+			status = http.StatusBadRequest
+		case errors.Is(err, service.ErrTimeout):
+			status = http.StatusGatewayTimeout
+		}
+		c.JSON(status, resp)
 		return
 	}
 	c.JSON(http.StatusOK, resp)
@@ -98,7 +110,7 @@ func (h *MCPHandler) handleLLMStreamChat(c *gin.Context, req model.LLMChatReques
 	c.Header("Connection", "keep-alive")
 	c.Header("Access-Control-Allow-Origin", "*")
 
-	chunkChan, errorChan, err := h.mcpService.LLMStreamChat(req)
+	chunkChan, errorChan, err := h.mcpService.LLMStreamChat(c.Request.Context(), req)
 	if err != nil {
 		c.SSEvent("error", gin.H{
 			"success": false,
@@ -118,6 +130,16 @@ func (h *MCPHandler) handleLLMStreamChat(c *gin.Context, req model.LLMChatReques
 		select {
 		case chunk, ok := <-chunkChan:
 			if !ok {
+				// 错误只在流结束时产生，协程在关闭 chunkChan 之前已关闭 errorChan
+				if err := <-errorChan; err != nil {
+					logger.Error("[MCP Handler] 流式响应错误: %v", err)
+					c.SSEvent("error", gin.H{
+						"success": false,
+						"message": fmt.Sprintf("流式响应错误: %v", err),
+					})
+					c.Writer.Flush()
+					return
+				}
 				c.SSEvent("done", gin.H{
 					"success": true,
 					"message": "回答完成",
@@ -148,17 +170,6 @@ func (h *MCPHandler) handleLLMStreamChat(c *gin.Context, req model.LLMChatReques
 			c.SSEvent("data", chunk)
 			c.Writer.Flush()
 
-		case err := <-errorChan:
-			if err != nil {
-				logger.Error("[MCP Handler] 流式响应错误: %v", err)
-				c.SSEvent("error", gin.H{
-					"success": false,
-					"message": fmt.Sprintf("流式响应错误: %v", err),
-				})
-				c.Writer.Flush()
-				return
-			}
-
 		case <-c.Request.Context().Done():
 			logger.Info("[MCP Handler] 客户端断开连接")
 			return
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelHandler 模型列表处理器
+type ModelHandler struct {
+	aiService *service.AIService
+}
+
+// NewModelHandler 创建模型列表处理器实例
+func NewModelHandler(aiService *service.AIService) *ModelHandler {
+	return &ModelHandler{aiService: aiService}
+}
+
+// HandleList 列出可供请求选择的模型
+func (h *ModelHandler) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, model.ModelsResponse{
+		Success: true,
+		Models:  h.aiService.Models(),
+	})
+}
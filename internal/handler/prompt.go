@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PromptHandler 提示词模板管理处理器
+type PromptHandler struct {
+	ragService *service.RAGService
+}
+
+// NewPromptHandler 创建提示词模板管理处理器实例
+func NewPromptHandler(ragService *service.RAGService) *PromptHandler {
+	return &PromptHandler{
+		ragService: ragService,
+	}
+}
+
+// HandleReload 重新读取配置文件中的提示词模板，校验失败时继续使用原模板
+func (h *PromptHandler) HandleReload(c *gin.Context) {
+	if err := h.ragService.ReloadPrompts(); err != nil {
+		c.JSON(http.StatusBadRequest, model.PromptReloadResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.PromptReloadResponse{
+		Success: true,
+		Message: "提示词模板已重新加载",
+	})
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
-	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/model"
 	"knowledge-maker/internal/service"
 
@@ -14,15 +16,43 @@ import (
 // RAGHandler RAG 处理器
 type RAGHandler struct {
 	ragService *service.RAGService
+	keepalive  time.Duration // 流式问答没有输出时发送保活注释的间隔，为 0 时不发送
 }
 
 // NewRAGHandler 创建 RAG 处理器实例
-func NewRAGHandler(ragService *service.RAGService) *RAGHandler {
+func NewRAGHandler(ragService *service.RAGService, cfg *config.ServerConfig) *RAGHandler {
 	return &RAGHandler{
 		ragService: ragService,
+		keepalive:  time.Duration(max(cfg.SSEKeepalive, 0)) * time.Second,
 	}
 }
 
+// chatErrorStatus 根据服务层错误确定 HTTP 状态码
+func chatErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrConversationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrConversationDisabled), errors.Is(err, service.ErrClientIDRequired), errors.Is(err, service.ErrKnowledgeBaseNotFound),
+		errors.Is(err, service.ErrInvalidGeneration), errors.Is(err, service.ErrModelNotAllowed):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// acceptLanguage 取 Accept-Language 请求头中第一个语言的主标签，如 "zh-CN,zh;q=0.9" 返回 zh
+func acceptLanguage(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), "-")
+	if tag == "*" {
+		return ""
+	}
+	return strings.ToLower(tag)
+}
+
 // HandleChat 处理聊天请求
 func (h *RAGHandler) HandleChat(c *gin.Context) {
 	var req model.ChatRequest
@@ -34,16 +64,22 @@ func (h *RAGHandler) HandleChat(c *gin.Context) {
 		return
 	}
 
+	req.ClientID = c.GetHeader(clientIDHeader)
+	if req.Language == "" {
+		req.Language = acceptLanguage(c.GetHeader("Accept-Language"))
+	}
+
 	// 调用服务层处理请求
-	response, err := h.ragService.ProcessChat(req.Query)
+	response, err := h.ragService.ProcessChat(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, *response)
+		c.JSON(chatErrorStatus(err), *response)
 		return
 	}
 
-	// 如果需要调试，可以包含知识库上下文
-	if gin.Mode() == gin.DebugMode {
-		// 这里可以添加调试信息，但需要从服务层获取
+	// 调试信息（知识库上下文、改写后的检索问题）仅在调试模式下返回
+	if gin.Mode() != gin.DebugMode {
+		response.KnowledgeContext = ""
+		response.RewrittenQuery = ""
 	}
 
 	c.JSON(http.StatusOK, *response)
@@ -60,69 +96,144 @@ func (h *RAGHandler) HandleStreamChat(c *gin.Context) {
 		return
 	}
 
+	encoder, err := newStreamEncoder(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ChatResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	// 设置 SSE 响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("Access-Control-Allow-Origin", "*")
 
+	req.ClientID = c.GetHeader(clientIDHeader)
+	if req.Language == "" {
+		req.Language = acceptLanguage(c.GetHeader("Accept-Language"))
+	}
+
 	// 调用服务层处理流式请求
-	responseChan, errorChan, err := h.ragService.ProcessStreamChat(req.Query)
+	responseChan, errorChan, err := h.ragService.ProcessStreamChat(c.Request.Context(), req)
 	if err != nil {
-		c.SSEvent("error", gin.H{
-			"success": false,
-			"message": err.Error(),
-		})
+		encoder.Reject(c, err)
 		return
 	}
 
 	// 立即发送连接建立确认
 	c.SSEvent("connected", gin.H{
-		"success": true,
-		"message": "连接已建立，开始处理...",
+		"success":         true,
+		"message":         "连接已建立，开始处理...",
+		"conversation_id": req.ConversationID,
 	})
 	c.Writer.Flush()
 
-	// 发送流式数据 - 简化版本，直接转发 ai.go 中的标记和内容
+	// 检索、等待首个数据块等阶段可能长时间没有输出，空闲时定期发送 SSE 注释避免代理断开连接；
+	// 注释会被 EventSource 等客户端忽略
+	var (
+		ticker    *time.Ticker
+		keepalive <-chan time.Time
+	)
+	if h.keepalive > 0 {
+		ticker = time.NewTicker(h.keepalive)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+
+	// 发送流式数据，回答内容、用量、错误和结束事件的格式由协议版本决定
 	for {
 		select {
+		case <-keepalive:
+			c.Writer.WriteString(": keepalive\n\n")
+			c.Writer.Flush()
+
 		case streamContent, ok := <-responseChan:
+			if ticker != nil {
+				ticker.Reset(h.keepalive)
+			}
 			if !ok {
-				// 流结束
-				c.SSEvent("done", gin.H{
-					"success": true,
-					"message": "回答完成",
-				})
+				// 流结束；错误只在流结束时产生，协程在关闭 responseChan 之前已关闭 errorChan，
+				// 因此只在这里读取 errorChan，不在 select 中等待已关闭的通道
+				if err := <-errorChan; err != nil {
+					encoder.Error(c, err)
+					return
+				}
+				encoder.Done(c, req.ConversationID)
 				return
 			}
 
-			// 检查是否包含思考内容
-			if streamContent.ReasoningContent != "" {
-				// 发送思考内容
-				c.SSEvent("data", gin.H{
-					"content": streamContent.ReasoningContent,
+			// 处理进度
+			if streamContent.Progress != nil {
+				c.SSEvent(streamContent.Progress.Stage, progressEvent(streamContent.Progress))
+				c.Writer.Flush()
+				continue
+			}
+
+			// 调试模式下发送改写后的检索问题
+			if streamContent.RewrittenQuery != "" {
+				if gin.Mode() == gin.DebugMode {
+					c.SSEvent("debug", gin.H{
+						"rewritten_query": streamContent.RewrittenQuery,
+					})
+					c.Writer.Flush()
+				}
+				continue
+			}
+
+			// 回答来自语义答案缓存
+			if streamContent.Cached {
+				c.SSEvent("cached", gin.H{
+					"cached": true,
 				})
 				c.Writer.Flush()
+				continue
 			}
 
-			// 检查是否有回答内容（包括标记）
-			if streamContent.Content != "" {
-				// 发送回答内容（包括标记）
-				c.SSEvent("data", gin.H{
-					"content": streamContent.Content,
+			// 未检索到相关知识时采取的策略
+			if streamContent.NoKnowledge != "" {
+				c.SSEvent("no_knowledge", gin.H{
+					"policy": streamContent.NoKnowledge,
 				})
 				c.Writer.Flush()
+				continue
 			}
 
-		case err := <-errorChan:
-			if err != nil {
-				logger.Error("流式响应错误: %v", err)
-				c.SSEvent("error", gin.H{
-					"success": false,
-					"message": fmt.Sprintf("流式响应错误: %v", err),
+			// 生成回答的 AI 服务提供方
+			if streamContent.Provider != "" {
+				c.SSEvent("provider", gin.H{
+					"provider": streamContent.Provider,
 				})
-				return
+				c.Writer.Flush()
+				continue
+			}
+
+			// 发送参考来源
+			if len(streamContent.Sources) > 0 {
+				c.SSEvent("sources", gin.H{
+					"sources": streamContent.Sources,
+				})
+				c.Writer.Flush()
+				continue
+			}
+
+			// token 用量
+			if streamContent.Usage != nil {
+				encoder.Usage(c, streamContent.Usage)
+				c.Writer.Flush()
+				continue
+			}
+
+			// 思考内容和回答内容
+			if streamContent.ReasoningContent != "" {
+				encoder.Reasoning(c, streamContent.ReasoningContent)
+			}
+			if streamContent.Content != "" {
+				encoder.Answer(c, streamContent.Content)
 			}
+			c.Writer.Flush()
 
 		case <-c.Request.Context().Done():
 			// 客户端断开连接
@@ -130,3 +241,21 @@ func (h *RAGHandler) HandleStreamChat(c *gin.Context) {
 		}
 	}
 }
+
+// progressEvent 构建进度事件的数据
+func progressEvent(progress *model.StreamProgress) gin.H {
+	switch progress.Stage {
+	case model.StageRetrievalDone:
+		return gin.H{
+			"chunks":          progress.Chunks,
+			"knowledge_bases": progress.KnowledgeBases,
+			"latency_ms":      progress.Latency.Milliseconds(),
+		}
+	case model.StageGenerationStarted:
+		return gin.H{
+			"model": progress.Model,
+		}
+	default:
+		return gin.H{}
+	}
+}
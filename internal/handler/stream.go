@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamProtocolHeader 选择流式问答协议版本的请求头，也可以使用查询参数 protocol
+const streamProtocolHeader = "X-Stream-Protocol"
+
+// streamEncoder 将回答内容、token 用量、错误和结束编码为 SSE 事件；
+// 连接、进度、参考来源等其他事件各协议版本相同
+type streamEncoder interface {
+	Reasoning(c *gin.Context, text string)
+	Answer(c *gin.Context, text string)
+	Usage(c *gin.Context, usage *model.Usage)
+	Reject(c *gin.Context, err error) // 请求参数校验失败，尚未开始处理
+	Error(c *gin.Context, err error)
+	Done(c *gin.Context, conversationID string)
+}
+
+// newStreamEncoder 根据请求头或查询参数选择协议版本（1、2 或 v1、v2），未指定时使用 v1
+func newStreamEncoder(c *gin.Context) (streamEncoder, error) {
+	version := c.GetHeader(streamProtocolHeader)
+	if version == "" {
+		version = c.Query("protocol")
+	}
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v") {
+	case "", "1":
+		return &streamV1{}, nil
+	case "2":
+		return streamV2{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的流式协议版本: %s", version)
+	}
+}
+
+// streamV1 v1 协议：思考内容和回答内容都作为 data 事件发送，以 <think>、</think>、<answer> 标记区分；
+// 不发送 token 用量
+type streamV1 struct {
+	thinking  bool // 已发送 <think>，尚未发送 </think>
+	answering bool // 已发送 <answer>
+}
+
+// Reasoning 发送思考内容，第一段思考内容前发送 <think>
+func (e *streamV1) Reasoning(c *gin.Context, text string) {
+	if !e.thinking && !e.answering {
+		e.data(c, "<think>")
+		e.thinking = true
+	}
+	e.data(c, text)
+}
+
+// Answer 发送回答内容，第一段回答内容前结束思考并发送 <answer>
+func (e *streamV1) Answer(c *gin.Context, text string) {
+	e.startAnswer(c)
+	e.data(c, text)
+}
+
+// Usage v1 协议不发送 token 用量
+func (e *streamV1) Usage(c *gin.Context, usage *model.Usage) {}
+
+// Reject 发送请求失败事件
+func (e *streamV1) Reject(c *gin.Context, err error) {
+	c.SSEvent("error", gin.H{
+		"success": false,
+		"message": err.Error(),
+	})
+}
+
+// Error 发送流式响应错误事件
+func (e *streamV1) Error(c *gin.Context, err error) {
+	logger.Error("流式响应错误: %v", err)
+	c.SSEvent("error", gin.H{
+		"success": false,
+		"message": fmt.Sprintf("流式响应错误: %v", err),
+	})
+}
+
+// Done 补全未发送的标记后发送结束事件；回答为空时也发送 <answer>
+func (e *streamV1) Done(c *gin.Context, conversationID string) {
+	e.startAnswer(c)
+	c.SSEvent("done", gin.H{
+		"success": true,
+		"message": "回答完成",
+	})
+}
+
+// startAnswer 思考未结束时发送 </think>，回答未开始时发送 <answer>
+func (e *streamV1) startAnswer(c *gin.Context) {
+	if e.thinking {
+		e.data(c, "</think>")
+		e.thinking = false
+	}
+	if !e.answering {
+		e.data(c, "<answer>")
+		e.answering = true
+	}
+}
+
+// data 发送 data 事件
+func (e *streamV1) data(c *gin.Context, content string) {
+	c.SSEvent("data", gin.H{
+		"content": content,
+	})
+}
+
+// streamV2 v2 协议：思考内容、回答内容、token 用量、错误和结束分别使用 reasoning、answer、usage、error、done 事件，
+// 内容中不含标记
+type streamV2 struct{}
+
+// Reasoning 发送 reasoning 事件
+func (streamV2) Reasoning(c *gin.Context, text string) {
+	c.SSEvent("reasoning", gin.H{
+		"content": text,
+	})
+}
+
+// Answer 发送 answer 事件
+func (streamV2) Answer(c *gin.Context, text string) {
+	c.SSEvent("answer", gin.H{
+		"content": text,
+	})
+}
+
+// Usage 发送 usage 事件
+func (streamV2) Usage(c *gin.Context, usage *model.Usage) {
+	c.SSEvent("usage", usage)
+}
+
+// Reject 发送 error 事件
+func (e streamV2) Reject(c *gin.Context, err error) {
+	e.sendError(c, err)
+}
+
+// Error 发送 error 事件
+func (e streamV2) Error(c *gin.Context, err error) {
+	logger.Error("流式响应错误: %v", err)
+	e.sendError(c, err)
+}
+
+// Done 发送 done 事件
+func (streamV2) Done(c *gin.Context, conversationID string) {
+	c.SSEvent("done", gin.H{
+		"conversation_id": conversationID,
+	})
+}
+
+// sendError 发送 error 事件，status 为同样的错误在非流式接口中对应的 HTTP 状态码
+func (streamV2) sendError(c *gin.Context, err error) {
+	c.SSEvent("error", gin.H{
+		"message": err.Error(),
+		"status":  chatErrorStatus(err),
+	})
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestContext 创建带指定请求头和查询参数的请求上下文
+func newTestContext(header, query string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	target := "/api/v1/chat/stream"
+	if query != "" {
+		target += "?protocol=" + query
+	}
+	c.Request = httptest.NewRequest(http.MethodPost, target, nil)
+	if header != "" {
+		c.Request.Header.Set(streamProtocolHeader, header)
+	}
+	return c, w
+}
+
+// sseEvents 将 SSE 响应解析为 "事件名 数据" 列表；数据为 JSON，其中的 < 和 > 被转义为 \u003c、\u003e
+func sseEvents(body string) []string {
+	var events []string
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var name, data string
+		for _, line := range strings.Split(block, "\n") {
+			if v, ok := strings.CutPrefix(line, "event:"); ok {
+				name = v
+			} else if v, ok := strings.CutPrefix(line, "data:"); ok {
+				data = v
+			}
+		}
+		events = append(events, name+" "+data)
+	}
+	return events
+}
+
+func TestNewStreamEncoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{name: "未指定时使用 v1", want: "v1"},
+		{name: "请求头指定", header: "2", want: "v2"},
+		{name: "查询参数指定", query: "v2", want: "v2"},
+		{name: "请求头优先于查询参数", header: "1", query: "2", want: "v1"},
+		{name: "忽略大小写和空白", header: " V2 ", want: "v2"},
+		{name: "不支持的版本", header: "3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestContext(tt.header, tt.query)
+			encoder, err := newStreamEncoder(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newStreamEncoder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := "v1"
+			if _, ok := encoder.(streamV2); ok {
+				got = "v2"
+			}
+			if got != tt.want {
+				t.Errorf("protocol = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEncoders(t *testing.T) {
+	usage := &model.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	timeout := fmt.Errorf("%w: 请求总耗时", service.ErrTimeout)
+
+	tests := []struct {
+		name   string
+		header string
+		send   func(c *gin.Context, e streamEncoder)
+		want   []string
+	}{
+		{
+			name: "v1 思考和回答",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Reasoning(c, "推理")
+				e.Reasoning(c, "过程")
+				e.Answer(c, "回答")
+				e.Usage(c, usage)
+				e.Done(c, "conv")
+			},
+			want: []string{
+				`data {"content":"\u003cthink\u003e"}`,
+				`data {"content":"推理"}`,
+				`data {"content":"过程"}`,
+				`data {"content":"\u003c/think\u003e"}`,
+				`data {"content":"\u003canswer\u003e"}`,
+				`data {"content":"回答"}`,
+				`done {"message":"回答完成","success":true}`,
+			},
+		},
+		{
+			name: "v1 只有思考内容时结束前补全标记",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Reasoning(c, "推理")
+				e.Done(c, "conv")
+			},
+			want: []string{
+				`data {"content":"\u003cthink\u003e"}`,
+				`data {"content":"推理"}`,
+				`data {"content":"\u003c/think\u003e"}`,
+				`data {"content":"\u003canswer\u003e"}`,
+				`done {"message":"回答完成","success":true}`,
+			},
+		},
+		{
+			name: "v1 回答之后的思考内容不再加标记",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Answer(c, "回答")
+				e.Reasoning(c, "推理")
+			},
+			want: []string{
+				`data {"content":"\u003canswer\u003e"}`,
+				`data {"content":"回答"}`,
+				`data {"content":"推理"}`,
+			},
+		},
+		{
+			name: "v1 错误",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Reject(c, errors.New("会话不存在"))
+				e.Error(c, timeout)
+			},
+			want: []string{
+				`error {"message":"会话不存在","success":false}`,
+				`error {"message":"流式响应错误: 请求超时: 请求总耗时","success":false}`,
+			},
+		},
+		{
+			name:   "v2 思考和回答",
+			header: "2",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Reasoning(c, "推理")
+				e.Answer(c, "回答")
+				e.Usage(c, usage)
+				e.Done(c, "conv")
+			},
+			want: []string{
+				`reasoning {"content":"推理"}`,
+				`answer {"content":"回答"}`,
+				`usage {"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"estimated":false}`,
+				`done {"conversation_id":"conv"}`,
+			},
+		},
+		{
+			name:   "v2 错误带状态码",
+			header: "2",
+			send: func(c *gin.Context, e streamEncoder) {
+				e.Reject(c, service.ErrConversationNotFound)
+				e.Error(c, timeout)
+			},
+			want: []string{
+				`error {"message":"会话不存在","status":404}`,
+				`error {"message":"请求超时: 请求总耗时","status":504}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newTestContext(tt.header, "")
+			encoder, err := newStreamEncoder(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.send(c, encoder)
+			if got := sseEvents(w.Body.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestChatErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrConversationNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: fast", service.ErrModelNotAllowed), http.StatusBadRequest},
+		{fmt.Errorf("%w: temperature", service.ErrInvalidGeneration), http.StatusBadRequest},
+		{service.ErrClientIDRequired, http.StatusBadRequest},
+		{fmt.Errorf("%w: 请求总耗时", service.ErrTimeout), http.StatusGatewayTimeout},
+		{errors.New("AI 服务不可用"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := chatErrorStatus(tt.err); got != tt.want {
+			t.Errorf("chatErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh"},
+		{"EN-us", "en"},
+		{"ja;q=0.8", "ja"},
+		{"*", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := acceptLanguage(tt.header); got != tt.want {
+			t.Errorf("acceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
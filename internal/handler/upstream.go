@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/resilience"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpstreamHandler 上游服务状态处理器
+type UpstreamHandler struct{}
+
+// NewUpstreamHandler 创建上游服务状态处理器实例
+func NewUpstreamHandler() *UpstreamHandler {
+	return &UpstreamHandler{}
+}
+
+// HandleStatus 获取各上游服务的熔断状态和调用统计
+func (h *UpstreamHandler) HandleStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, model.UpstreamStatusResponse{
+		Success:   true,
+		Upstreams: resilience.Status(),
+	})
+}
@@ -130,11 +130,11 @@ func (l *Logger) Error(format string, args ...interface{}) {
 func (l *Logger) writeLog(level LogLevel, format string, args ...interface{}) {
 	msg := fmt.Sprintf(format, args...)
 	logMsg := fmt.Sprintf("[%s] %s", levelNames[level], msg)
-	
+
 	// 同时输出到控制台和文件
-	log.Printf(logMsg)
+	log.Print(logMsg)
 	if l.fileLog != nil {
-		l.fileLog.Printf(logMsg)
+		l.fileLog.Print(logMsg)
 	}
 }
 
@@ -177,4 +177,4 @@ func Close() error {
 		return globalLogger.Close()
 	}
 	return nil
-}
\ No newline at end of file
+}
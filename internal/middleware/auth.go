@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"knowledge-maker/internal/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理接口鉴权中间件，要求请求头携带 Authorization: Bearer <admin_token>
+// 未配置 admin_token 时拒绝全部管理请求，避免接口在无鉴权的情况下暴露
+func AdminAuth(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "管理接口未启用，请配置 server.admin_token",
+			})
+			c.Abort()
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			logger.Warn("管理接口鉴权失败，客户端: %s，路径: %s", c.ClientIP(), c.Request.URL.Path)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "管理接口鉴权失败",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
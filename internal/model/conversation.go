@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Conversation 会话信息
+type Conversation struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ConversationMessage 会话中的单条消息
+type ConversationMessage struct {
+	Seq              int       `json:"seq"`
+	Role             string    `json:"role"`
+	Content          string    `json:"content"`
+	KnowledgeContext string    `json:"knowledge_context,omitempty"` // 生成该回答时检索到的知识库内容
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ConversationDetail 会话详情（包含消息列表）
+type ConversationDetail struct {
+	Conversation
+	Messages []ConversationMessage `json:"messages"`
+}
+
+// CreateConversationRequest 创建会话请求
+type CreateConversationRequest struct {
+	Title string `json:"title"`
+}
+
+// ConversationResponse 会话接口响应
+type ConversationResponse struct {
+	Success       bool                `json:"success"`
+	Conversation  *ConversationDetail `json:"conversation,omitempty"`
+	Conversations []Conversation      `json:"conversations,omitempty"`
+	Message       string              `json:"message,omitempty"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// 文档导入结果状态
+const (
+	DocumentStatusCreated   = "created"
+	DocumentStatusUpdated   = "updated"
+	DocumentStatusUnchanged = "unchanged"
+)
+
+// Document 已导入知识库的文档
+type Document struct {
+	ID            string    `json:"id"`
+	KnowledgeBase string    `json:"knowledge_base"`
+	Title         string    `json:"title"`
+	URL           string    `json:"url,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	ContentType   string    `json:"content_type"`
+	ContentHash   string    `json:"content_hash"`
+	ChunkCount    int       `json:"chunk_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DocumentDetail 文档详情（包含原始内容）
+type DocumentDetail struct {
+	Document
+	Content string `json:"content,omitempty"`
+}
+
+// IngestDocumentRequest 文档导入请求
+type IngestDocumentRequest struct {
+	ID            string `json:"id"`             // 可选，未指定时根据 source 或内容生成
+	KnowledgeBase string `json:"knowledge_base"` // 可选，未指定时写入默认知识库
+	Title         string `json:"title"`
+	URL           string `json:"url"`          // 文档链接，作为回答的参考来源
+	Source        string `json:"source"`       // 来源标识，如文件路径
+	ContentType   string `json:"content_type"` // markdown, text, html；未指定时根据 source 扩展名判断
+	Content       string `json:"content" binding:"required"`
+}
+
+// DocumentResponse 文档接口响应
+type DocumentResponse struct {
+	Success   bool            `json:"success"`
+	Status    string          `json:"status,omitempty"` // 导入结果: created, updated, unchanged
+	Document  *DocumentDetail `json:"document,omitempty"`
+	Documents []Document      `json:"documents,omitempty"`
+	Message   string          `json:"message,omitempty"`
+}
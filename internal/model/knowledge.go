@@ -0,0 +1,53 @@
+package model
+
+// KnowledgeChunk 知识库检索结果片段
+type KnowledgeChunk struct {
+	Content  string                 `json:"content"`
+	Title    string                 `json:"title,omitempty"`
+	URL      string                 `json:"url,omitempty"`
+	Source   string                 `json:"source,omitempty"` // 来源标识（文件名、文档 ID 等）
+	Score    float64                `json:"score"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// KnowledgeBase 片段所属的知识库名称
+	KnowledgeBase string `json:"knowledge_base,omitempty"`
+}
+
+// CacheStats 检索结果缓存统计
+type CacheStats struct {
+	Enabled   bool    `json:"enabled"`
+	Size      int     `json:"size"`
+	Capacity  int     `json:"capacity"`
+	TTL       int     `json:"ttl"` // 有效期（秒）
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// CacheResponse 缓存管理接口响应
+type CacheResponse struct {
+	Success bool        `json:"success"`
+	Stats   *CacheStats `json:"stats,omitempty"`   // 检索结果缓存统计
+	Answers *CacheStats `json:"answers,omitempty"` // 语义答案缓存统计
+	Purged  int         `json:"purged,omitempty"`  // 清除的条目总数
+	Message string      `json:"message,omitempty"`
+}
+
+// KnowledgeBaseInfo 知识库信息
+type KnowledgeBaseInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"`
+}
+
+// Source 回答引用的参考来源，Index 与提示词中的 [n] 编号对应
+type Source struct {
+	Index  int     `json:"index"`
+	Title  string  `json:"title,omitempty"`
+	URL    string  `json:"url,omitempty"`
+	Source string  `json:"source,omitempty"`
+	Score  float64 `json:"score"`
+	// KnowledgeBase 来源所属的知识库名称
+	KnowledgeBase string `json:"knowledge_base,omitempty"`
+}
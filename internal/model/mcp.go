@@ -70,19 +70,24 @@ type LLMChatRequest struct {
 	Messages []LLMChatMessage `json:"messages" binding:"required"`
 	Tools    []LLMToolDef     `json:"tools,omitempty"` // 可用工具列表
 	Stream   bool             `json:"stream"`          // 是否流式输出
+	Model    string           `json:"model,omitempty"` // 模型配置名称，为空时使用默认模型
+	// 生成参数（temperature、top_p 等，与 OpenAI 接口同名），只能在服务端配置允许的范围内调整
+	GenerationParams
 }
 
 // LLMChatResponse LLM 非流式聊天响应
 type LLMChatResponse struct {
-	Success bool            `json:"success"`
-	Message *LLMChatMessage `json:"message,omitempty"`
-	Error   string          `json:"error,omitempty"`
+	Success  bool            `json:"success"`
+	Message  *LLMChatMessage `json:"message,omitempty"`
+	Provider string          `json:"provider,omitempty"` // 生成回复的 AI 服务提供方
+	Error    string          `json:"error,omitempty"`
 }
 
 // LLMStreamChunk LLM 流式响应块
 type LLMStreamChunk struct {
 	Delta        *LLMChatMessageDelta `json:"delta,omitempty"`
 	FinishReason string               `json:"finish_reason,omitempty"`
+	Provider     string               `json:"provider,omitempty"` // 生成回复的 AI 服务提供方，仅在第一个数据块中返回
 }
 
 // LLMChatMessageDelta 流式消息增量
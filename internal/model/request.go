@@ -8,8 +8,37 @@ type ChatMessage struct {
 
 // ChatRequest 聊天请求结构
 type ChatRequest struct {
-	Query   string        `json:"Query" binding:"required"`
-	History []ChatMessage `json:"History,omitempty"`
+	Query          string        `json:"Query" binding:"required"`
+	History        []ChatMessage `json:"History,omitempty"`
+	ConversationID string        `json:"ConversationID,omitempty"` // 服务端会话 ID，设置后使用会话中保存的历史
+	ClientID       string        `json:"-"`                        // 客户端标识，由处理器从请求头填充
+	// KnowledgeBases 要检索的知识库名称，为空时由路由器根据问题自动选择
+	KnowledgeBases []string `json:"KnowledgeBases,omitempty"`
+	// NoCache 为 true 时不使用缓存的回答，重新生成的回答会更新缓存
+	NoCache bool `json:"NoCache,omitempty"`
+	// Model 使用的模型配置名称，为空时使用默认模型，可选值见 GET /api/v1/models
+	Model string `json:"Model,omitempty"`
+	// Generation 覆盖生成参数，只能在服务端配置允许的范围内调整
+	Generation *GenerationParams `json:"Generation,omitempty"`
+	// Language 用户语言（如 zh、en），供提示词模板使用；为空时取 Accept-Language 请求头，仍为空时根据问题内容判断
+	Language string `json:"Language,omitempty"`
+}
+
+// GenerationParams 请求级生成参数，未设置的参数使用服务端配置
+type GenerationParams struct {
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// IsZero 是否未设置任何参数
+func (p *GenerationParams) IsZero() bool {
+	return p == nil || (p.MaxTokens == nil && p.Temperature == nil && p.TopP == nil && len(p.Stop) == 0 &&
+		p.PresencePenalty == nil && p.FrequencyPenalty == nil && p.Seed == nil)
 }
 
 // KnowledgeQuery 知识库查询请求
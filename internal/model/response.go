@@ -1,16 +1,48 @@
 package model
 
+import "encoding/json"
+
 // ChatResponse 聊天响应结构
 type ChatResponse struct {
-	Success          bool   `json:"success"`
-	Answer           string `json:"answer"`
-	KnowledgeContext string `json:"knowledge_context,omitempty"`
-	Message          string `json:"message,omitempty"`
+	Success          bool     `json:"success"`
+	Answer           string   `json:"answer"`
+	KnowledgeContext string   `json:"knowledge_context,omitempty"`
+	RewrittenQuery   string   `json:"rewritten_query,omitempty"` // 改写后的检索问题（仅调试模式返回）
+	Message          string   `json:"message,omitempty"`
+	ConversationID   string   `json:"conversation_id,omitempty"`
+	Sources          []Source `json:"sources,omitempty"`  // 参考来源
+	Cached           bool     `json:"cached,omitempty"`   // 回答来自语义答案缓存
+	Provider         string   `json:"provider,omitempty"` // 生成回答的 AI 服务提供方
+	// NoKnowledge 未检索到相关知识时采取的策略（answer、disclaimer、refuse、fallback），检索到知识时为空
+	NoKnowledge string `json:"no_knowledge,omitempty"`
 }
 
-// KnowledgeResponse 知识库查询响应
+// KnowledgeResponse 知识库查询响应，Data 可能是文本、片段数组或包含片段数组的对象
 type KnowledgeResponse struct {
+	Success *bool           `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
+}
+
+// ModelInfo 可供请求选择的模型信息
+type ModelInfo struct {
+	Name          string   `json:"name"` // 请求 model 字段使用的名称
+	DisplayName   string   `json:"display_name"`
+	Description   string   `json:"description,omitempty"`
+	Capabilities  []string `json:"capabilities"`
+	ContextWindow int      `json:"context_window"` // 上下文窗口（token）
+	MaxTokens     int      `json:"max_tokens"`     // 默认回复的最大 token 数，0 表示不限制
+	Default       bool     `json:"default"`
+}
+
+// ModelsResponse 模型列表响应
+type ModelsResponse struct {
+	Success bool        `json:"success"`
+	Models  []ModelInfo `json:"models"`
+}
+
+// PromptReloadResponse 重新加载提示词模板响应
+type PromptReloadResponse struct {
 	Success bool   `json:"success"`
-	Data    string `json:"data"`
 	Message string `json:"message"`
-}
\ No newline at end of file
+}
@@ -1,7 +1,40 @@
 package model
 
+import "time"
+
+// 流式问答的处理阶段
+const (
+	StageRetrievalStarted  = "retrieval_started"  // 开始检索知识库
+	StageRetrievalDone     = "retrieval_done"     // 检索完成
+	StageGenerationStarted = "generation_started" // AI 服务开始生成回答（已收到首个数据块）
+)
+
+// StreamProgress 流式问答的处理进度
+type StreamProgress struct {
+	Stage          string
+	Chunks         int           // retrieval_done：检索到的知识片段数
+	KnowledgeBases []string      // retrieval_done：检索的知识库
+	Latency        time.Duration // retrieval_done：检索耗时
+	Model          string        // generation_started：实际生成回答的模型
+}
+
 // StreamContent 流式内容结构
 type StreamContent struct {
-	Content          string `json:"content"`
-	ReasoningContent string `json:"reasoning_content"`
+	Content          string          `json:"content"`
+	ReasoningContent string          `json:"reasoning_content"`
+	RewrittenQuery   string          `json:"rewritten_query,omitempty"` // 改写后的检索问题（调试信息）
+	Sources          []Source        `json:"sources,omitempty"`         // 参考来源，在回答开始前发送
+	Cached           bool            `json:"cached,omitempty"`          // 回答来自语义答案缓存，在回答开始前发送
+	Provider         string          `json:"provider,omitempty"`        // 生成回答的 AI 服务提供方，在回答开始前发送
+	NoKnowledge      string          `json:"no_knowledge,omitempty"`    // 未检索到相关知识时采取的策略，在回答开始前发送
+	Progress         *StreamProgress `json:"progress,omitempty"`        // 处理进度
+	Usage            *Usage          `json:"usage,omitempty"`           // token 用量，在回答结束后发送
+}
+
+// Usage 生成回答的 token 用量
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"estimated"` // 服务提供方未返回用量，按字数估算
 }
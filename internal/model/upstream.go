@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// UpstreamStatus 上游服务的熔断状态和调用统计
+type UpstreamStatus struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"` // closed（正常）、open（熔断中）、half_open（探测中）
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"` // 最近一次熔断的时间
+	Requests            uint64     `json:"requests"`            // 发出的请求数，包括重试
+	Retries             uint64     `json:"retries"`
+	Failures            uint64     `json:"failures"`
+	Rejected            uint64     `json:"rejected"` // 熔断期间直接拒绝的请求数
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	MaxRetries          int        `json:"max_retries"`
+	FailureThreshold    int        `json:"failure_threshold"`
+}
+
+// UpstreamStatusResponse 上游服务状态接口响应
+type UpstreamStatusResponse struct {
+	Success   bool             `json:"success"`
+	Upstreams []UpstreamStatus `json:"upstreams"`
+}
@@ -0,0 +1,199 @@
+// Package prompt 基于 text/template 的 RAG 提示词模板：系统提示词、检索到知识时的用户消息和未检索到知识时的用户消息。
+//
+// 模板在启动时解析并用示例数据试渲染，引用不存在的变量或语法错误都会在启动时报错；
+// 运行时可以重新加载，新模板校验失败时保留原模板。
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+)
+
+// Data 模板变量
+type Data struct {
+	Query          string                 // 用户问题
+	Chunks         []model.KnowledgeChunk // 检索到的知识片段，按相关度排序
+	Sources        []model.Source         // 参考来源，Index 与 Context 中的 [n] 编号对应
+	Context        string                 // 按默认格式拼接的带编号知识库内容
+	History        []model.ChatMessage    // 携带的历史对话
+	KnowledgeBases []string               // 检索的知识库名称
+	Language       string                 // 用户语言，如 zh、en
+	Date           string                 // 当前日期，格式 2006-01-02
+	Now            time.Time              // 当前时间
+}
+
+// Templates 提示词模板集合，并发安全
+type Templates struct {
+	mu         sync.RWMutex
+	system     *template.Template
+	context    *template.Template
+	noContext  *template.Template
+	configPath string
+}
+
+// funcs 模板可用的辅助函数
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"inc":  func(i int) int { return i + 1 },
+	// truncate 按字符数截断文本
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n]) + "..."
+		}
+		return s
+	},
+}
+
+// NewTemplates 解析并校验提示词模板，重新加载时读取 cfg.Path 对应的配置文件
+func NewTemplates(cfg *config.Config) (*Templates, error) {
+	t := &Templates{configPath: cfg.Path}
+	if err := t.load(&cfg.RAG); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload 重新读取配置文件和模板文件，新模板校验失败时保留原模板并返回错误
+func (t *Templates) Reload() error {
+	cfg, err := config.LoadConfig(t.configPath)
+	if err != nil {
+		return err
+	}
+	return t.load(&cfg.RAG)
+}
+
+// load 解析全部模板，全部校验通过后才替换
+func (t *Templates) load(cfg *config.RAGConfig) error {
+	systemText := cfg.Prompts.System
+	if systemText == "" {
+		systemText = cfg.SystemPrompt
+	}
+	system, err := parse("system", systemText, cfg.Prompts.SystemFile)
+	if err != nil {
+		return err
+	}
+	context, err := parse("context", cfg.Prompts.Context, cfg.Prompts.ContextFile)
+	if err != nil {
+		return err
+	}
+	noContext, err := parse("no_context", cfg.Prompts.NoContext, cfg.Prompts.NoContextFile)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.system, t.context, t.noContext = system, context, noContext
+	t.mu.Unlock()
+	return nil
+}
+
+// parse 解析模板并用示例数据试渲染，file 不为空时从文件读取模板
+func parse(name, text, file string) (*template.Template, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取提示词模板 %s 失败: %v", name, err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析提示词模板 %s 失败: %v", name, err)
+	}
+	if _, err := execute(tmpl, sampleData()); err != nil {
+		return nil, fmt.Errorf("校验提示词模板 %s 失败: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// sampleData 校验模板使用的示例数据
+func sampleData() Data {
+	now := time.Now()
+	return Data{
+		Query:          "示例问题",
+		Chunks:         []model.KnowledgeChunk{{Content: "示例内容", Title: "示例标题", URL: "https://example.com", Score: 1, KnowledgeBase: "default"}},
+		Sources:        []model.Source{{Index: 1, Title: "示例标题", URL: "https://example.com", Score: 1, KnowledgeBase: "default"}},
+		Context:        "[1] 示例标题\n示例内容",
+		History:        []model.ChatMessage{{Role: "user", Content: "示例"}, {Role: "assistant", Content: "示例"}},
+		KnowledgeBases: []string{"default"},
+		Language:       "zh",
+		Date:           now.Format("2006-01-02"),
+		Now:            now,
+	}
+}
+
+// execute 渲染模板
+func execute(tmpl *template.Template, data Data) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// System 渲染系统提示词
+func (t *Templates) System(data Data) (string, error) {
+	t.mu.RLock()
+	tmpl := t.system
+	t.mu.RUnlock()
+	return execute(tmpl, withNow(data))
+}
+
+// User 渲染用户消息：有知识片段时使用 context 模板，否则使用 no_context 模板
+func (t *Templates) User(data Data) (string, error) {
+	t.mu.RLock()
+	tmpl := t.noContext
+	if len(data.Chunks) > 0 {
+		tmpl = t.context
+	}
+	t.mu.RUnlock()
+	return execute(tmpl, withNow(data))
+}
+
+// ContextFrame 渲染不含知识片段的 context 模板，用于估算知识库内容以外的固定开销
+func (t *Templates) ContextFrame(data Data) (string, error) {
+	t.mu.RLock()
+	tmpl := t.context
+	t.mu.RUnlock()
+	data.Chunks, data.Sources, data.Context = nil, nil, ""
+	return execute(tmpl, withNow(data))
+}
+
+// withNow 补全时间变量
+func withNow(data Data) Data {
+	if data.Now.IsZero() {
+		data.Now = time.Now()
+	}
+	if data.Date == "" {
+		data.Date = data.Now.Format("2006-01-02")
+	}
+	return data
+}
+
+// DetectLanguage 根据文本中的文字粗略判断语言：包含汉字时为 zh，包含假名时为 ja，包含谚文时为 ko，否则为 en
+func DetectLanguage(text string) string {
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			return "ja"
+		case unicode.Is(unicode.Hangul, r):
+			return "ko"
+		}
+	}
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return "zh"
+		}
+	}
+	return "en"
+}
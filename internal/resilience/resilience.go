@@ -0,0 +1,77 @@
+// Package resilience 为调用上游服务（AI 服务提供方、知识库、验证码）的 HTTP 客户端提供
+// 带随机抖动的指数退避重试和按上游划分的熔断
+package resilience
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+)
+
+// ErrCircuitOpen 上游服务处于熔断状态，请求未发出
+var ErrCircuitOpen = errors.New("上游服务已熔断")
+
+var (
+	mu        sync.Mutex
+	settings  *config.ResilienceConfig
+	upstreams = make(map[string]*Upstream)
+)
+
+// Init 设置全局的重试与熔断配置，需在创建上游客户端之前调用；未调用时不重试也不熔断
+func Init(cfg *config.ResilienceConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	settings = cfg
+}
+
+// Get 获取指定名称的上游，首次获取时按配置创建；同名上游共享熔断状态
+func Get(name string) *Upstream {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if u, ok := upstreams[name]; ok {
+		return u
+	}
+	u := newUpstream(name, policyFor(name))
+	upstreams[name] = u
+	return u
+}
+
+// Client 返回使用指定上游重试与熔断策略的 HTTP 客户端，其余设置与 client 相同
+func Client(name string, client *http.Client, opts ...Option) *http.Client {
+	wrapped := *client
+	wrapped.Transport = Get(name).Transport(client.Transport, opts...)
+	return &wrapped
+}
+
+// Status 获取全部上游的熔断状态和调用统计，按名称排序
+func Status() []model.UpstreamStatus {
+	mu.Lock()
+	list := make([]*Upstream, 0, len(upstreams))
+	for _, u := range upstreams {
+		list = append(list, u)
+	}
+	mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	status := make([]model.UpstreamStatus, len(list))
+	for i, u := range list {
+		status[i] = u.Status()
+	}
+	return status
+}
+
+// policyFor 确定上游使用的策略，调用方需持有锁
+func policyFor(name string) config.UpstreamPolicy {
+	if settings == nil {
+		return config.UpstreamPolicy{}
+	}
+	if policy, ok := settings.Upstreams[name]; ok {
+		return policy
+	}
+	return settings.Default
+}
@@ -0,0 +1,185 @@
+package resilience
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"knowledge-maker/internal/logger"
+)
+
+// transport 按上游策略重试和熔断的 http.RoundTripper
+type transport struct {
+	upstream           *Upstream
+	base               http.RoundTripper
+	retryNonIdempotent bool // 是否重试 POST 等非幂等请求
+}
+
+// Option 上游客户端选项
+type Option func(*transport)
+
+// RetryNonIdempotent 允许重试 POST 等非幂等请求，只用于重复发送没有副作用的调用，如检索、向量化，
+// 以及 AI 服务在开始返回回答之前失败的请求；验证码校验等一次性请求不能使用
+func RetryNonIdempotent() Option {
+	return func(t *transport) {
+		t.retryNonIdempotent = true
+	}
+}
+
+// Transport 包装 base，为请求加上重试与熔断；base 为 nil 时使用 http.DefaultTransport
+func (u *Upstream) Transport(base http.RoundTripper, opts ...Option) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{upstream: u, base: base}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// RoundTrip 发送请求：熔断时直接返回 ErrCircuitOpen；连接失败、429、502、503、504（幂等请求还包括其他 5xx）
+// 时按退避时间重试，响应带有 Retry-After 时至少等待该时间。非幂等请求只在设置了 RetryNonIdempotent 时重试。
+// 重试只发生在拿到响应之前，不影响已开始的流式响应
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := t.upstream
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if !u.allow() {
+			return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, u.name)
+		}
+
+		r, err := rewind(req, attempt)
+		if err != nil {
+			u.onAbort()
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(r)
+		if err != nil && ctx.Err() != nil {
+			u.onAbort()
+			return resp, err
+		}
+		failure, retryable, retryAfter := classify(req, resp, err)
+		if failure == nil {
+			u.onSuccess()
+			return resp, err
+		}
+		u.onFailure(failure)
+
+		if !idempotent(req.Method) && !t.retryNonIdempotent {
+			retryable = false
+		}
+		if !retryable || attempt >= u.policy.MaxRetries || !rewindable(req) || u.isOpen() {
+			return resp, err
+		}
+		delay := u.backoff(attempt)
+		if retryAfter > 0 {
+			// 上游要求等待的时间过长时不再重试，交由调用方处理（如切换到下一个 AI 服务提供方）
+			if limit := time.Duration(u.policy.MaxDelay) * time.Millisecond; limit > 0 && retryAfter > limit {
+				return resp, err
+			}
+			delay = max(delay, retryAfter)
+		}
+		if resp != nil {
+			discard(resp)
+		}
+
+		u.onRetry()
+		logger.Warn("上游 %s 请求失败，%v 后第 %d 次重试: %v", u.name, delay.Round(time.Millisecond), attempt+1, failure)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// classify 判断请求结果：返回计入熔断的失败原因（成功或上游正常返回的 4xx 为 nil）、是否可以重试，以及 Retry-After 要求的等待时间
+func classify(req *http.Request, resp *http.Response, err error) (error, bool, time.Duration) {
+	if err != nil {
+		return err, true, 0
+	}
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests, code == http.StatusBadGateway,
+		code == http.StatusServiceUnavailable, code == http.StatusGatewayTimeout:
+		return fmt.Errorf("状态码 %d", code), true, parseRetryAfter(resp.Header.Get("Retry-After"))
+	case code >= 500:
+		return fmt.Errorf("状态码 %d", code), idempotent(req.Method), 0
+	default:
+		return nil, false, 0
+	}
+}
+
+// backoff 计算第 attempt 次重试前的等待时间：按指数增长，不超过 max_delay，并在 [d/2, d] 之间随机，避免多个请求同时重试
+func (u *Upstream) backoff(attempt int) time.Duration {
+	limit := time.Duration(u.policy.MaxDelay) * time.Millisecond
+	delay := time.Duration(u.policy.BaseDelay) * time.Millisecond << min(attempt, 16)
+	if limit > 0 && delay > limit {
+		delay = limit
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isOpen 判断是否处于熔断状态
+func (u *Upstream) isOpen() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.state == stateOpen
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// rewindable 判断请求体能否重新读取，不能重新读取的请求不重试
+func rewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind 重试时复制请求并重新读取请求体
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// idempotent 判断请求方法是否幂等
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// discard 读取并关闭不再使用的响应体，以便复用连接
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,133 @@
+package resilience
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"knowledge-maker/internal/config"
+)
+
+func TestBackoff(t *testing.T) {
+	u := newUpstream("test", config.UpstreamPolicy{BaseDelay: 100, MaxDelay: 1000})
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		{2, 200 * time.Millisecond, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond, time.Second}, // 1600ms 被 max_delay 截断
+		{100, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := u.backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want [%v, %v]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestBackoffWithoutDelay(t *testing.T) {
+	u := newUpstream("test", config.UpstreamPolicy{})
+	if d := u.backoff(3); d != 0 {
+		t.Fatalf("backoff(3) = %v, want 0", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if d := parseRetryAfter(tt.value); d < tt.min || d > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want [%v, %v]", tt.value, d, tt.min, tt.max)
+		}
+	}
+}
+
+// flakyServer 前 failures 次请求返回 503，之后返回 200，返回服务和收到的请求数
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRoundTripRetries(t *testing.T) {
+	policy := config.UpstreamPolicy{MaxRetries: 2, BaseDelay: 1, MaxDelay: 5}
+	tests := []struct {
+		name       string
+		method     string
+		opts       []Option
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"GET 重试", http.MethodGet, nil, http.StatusOK, 3},
+		{"POST 默认不重试", http.MethodPost, nil, http.StatusServiceUnavailable, 1},
+		{"POST 允许重试", http.MethodPost, []Option{RetryNonIdempotent()}, http.StatusOK, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := flakyServer(t, 2)
+			client := &http.Client{Transport: newUpstream("test", policy).Transport(nil, tt.opts...)}
+
+			req, err := http.NewRequest(tt.method, srv.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRoundTripRetryAfterTooLong(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	u := newUpstream("test", config.UpstreamPolicy{MaxRetries: 2, BaseDelay: 1, MaxDelay: 1000})
+	resp, err := (&http.Client{Transport: u.Transport(nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || calls.Load() != 1 {
+		t.Fatalf("status = %d, calls = %d, want 429 without retry", resp.StatusCode, calls.Load())
+	}
+}
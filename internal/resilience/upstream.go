@@ -0,0 +1,151 @@
+package resilience
+
+import (
+	"sync"
+	"time"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+)
+
+// 熔断器状态
+const (
+	stateClosed   = "closed"    // 正常放行
+	stateOpen     = "open"      // 熔断中，直接拒绝请求
+	stateHalfOpen = "half_open" // 熔断时间已过，放行一个探测请求
+)
+
+// Upstream 一个上游服务：重试策略、熔断器状态和调用统计
+type Upstream struct {
+	name   string
+	policy config.UpstreamPolicy
+
+	mu                  sync.Mutex
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	probing             bool // 半开状态下已放行探测请求，等待其结果
+	requests            uint64
+	retries             uint64
+	failures            uint64
+	rejected            uint64
+	lastError           string
+	lastFailureAt       time.Time
+}
+
+// newUpstream 创建上游
+func newUpstream(name string, policy config.UpstreamPolicy) *Upstream {
+	return &Upstream{
+		name:   name,
+		policy: policy,
+		state:  stateClosed,
+	}
+}
+
+// Name 获取上游名称
+func (u *Upstream) Name() string {
+	return u.name
+}
+
+// allow 判断是否放行请求：熔断时间已过时转为半开并放行一个探测请求，其余请求在探测结束前继续拒绝
+func (u *Upstream) allow() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	switch u.state {
+	case stateOpen:
+		if time.Since(u.openedAt) < time.Duration(u.policy.OpenTimeout)*time.Second {
+			u.rejected++
+			return false
+		}
+		u.state = stateHalfOpen
+		u.probing = true
+		logger.Info("上游 %s 熔断时间已过，放行探测请求", u.name)
+	case stateHalfOpen:
+		if u.probing {
+			u.rejected++
+			return false
+		}
+		u.probing = true
+	}
+	u.requests++
+	return true
+}
+
+// onSuccess 记录一次成功（包括上游正常返回的 4xx），半开状态下恢复正常
+func (u *Upstream) onSuccess() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.consecutiveFailures = 0
+	u.probing = false
+	if u.state != stateClosed {
+		u.state = stateClosed
+		logger.Info("上游 %s 探测请求成功，熔断已恢复", u.name)
+	}
+}
+
+// onFailure 记录一次失败，连续失败达到阈值或半开状态下探测失败时熔断
+func (u *Upstream) onFailure(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.failures++
+	u.consecutiveFailures++
+	u.lastError = err.Error()
+	u.lastFailureAt = time.Now()
+	u.probing = false
+
+	threshold := u.policy.FailureThreshold
+	if threshold <= 0 {
+		return
+	}
+	if u.state == stateHalfOpen || (u.state == stateClosed && u.consecutiveFailures >= threshold) {
+		u.state = stateOpen
+		u.openedAt = time.Now()
+		logger.Warn("上游 %s 连续失败 %d 次，熔断 %d 秒: %v", u.name, u.consecutiveFailures, u.policy.OpenTimeout, err)
+	}
+}
+
+// onAbort 请求被调用方取消，不计入成功或失败，半开状态下允许重新探测
+func (u *Upstream) onAbort() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.probing = false
+}
+
+// onRetry 记录一次重试
+func (u *Upstream) onRetry() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.retries++
+}
+
+// Status 获取熔断状态和调用统计
+func (u *Upstream) Status() model.UpstreamStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	status := model.UpstreamStatus{
+		Name:                u.name,
+		State:               u.state,
+		ConsecutiveFailures: u.consecutiveFailures,
+		Requests:            u.requests,
+		Retries:             u.retries,
+		Failures:            u.failures,
+		Rejected:            u.rejected,
+		LastError:           u.lastError,
+		MaxRetries:          max(u.policy.MaxRetries, 0),
+		FailureThreshold:    max(u.policy.FailureThreshold, 0),
+	}
+	if !u.openedAt.IsZero() {
+		openedAt := u.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if !u.lastFailureAt.IsZero() {
+		lastFailureAt := u.lastFailureAt
+		status.LastFailureAt = &lastFailureAt
+	}
+	return status
+}
@@ -0,0 +1,112 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"knowledge-maker/internal/config"
+)
+
+// expireOpen 模拟熔断时间已过
+func expireOpen(u *Upstream) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.openedAt = time.Now().Add(-time.Duration(u.policy.OpenTimeout+1) * time.Second)
+}
+
+func assertState(t *testing.T, u *Upstream, want string) {
+	t.Helper()
+	if got := u.Status().State; got != want {
+		t.Fatalf("state = %s, want %s", got, want)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	u := newUpstream("test", config.UpstreamPolicy{FailureThreshold: 2, OpenTimeout: 30})
+	failure := errors.New("状态码 502")
+
+	u.allow()
+	u.onFailure(failure)
+	assertState(t, u, stateClosed)
+
+	// 成功后连续失败次数重新计算
+	u.allow()
+	u.onSuccess()
+	u.allow()
+	u.onFailure(failure)
+	assertState(t, u, stateClosed)
+
+	u.allow()
+	u.onFailure(failure)
+	assertState(t, u, stateOpen)
+
+	if u.allow() {
+		t.Fatal("allow() = true while open")
+	}
+	if got := u.Status().Rejected; got != 1 {
+		t.Fatalf("rejected = %d, want 1", got)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	failure := errors.New("状态码 503")
+	newOpen := func() *Upstream {
+		u := newUpstream("test", config.UpstreamPolicy{FailureThreshold: 1, OpenTimeout: 30})
+		u.allow()
+		u.onFailure(failure)
+		assertState(t, u, stateOpen)
+		expireOpen(u)
+		return u
+	}
+
+	t.Run("探测成功后恢复", func(t *testing.T) {
+		u := newOpen()
+		if !u.allow() {
+			t.Fatal("probe not allowed after open timeout")
+		}
+		assertState(t, u, stateHalfOpen)
+		if u.allow() {
+			t.Fatal("second request allowed while probing")
+		}
+		u.onSuccess()
+		assertState(t, u, stateClosed)
+		if !u.allow() {
+			t.Fatal("request rejected after recovery")
+		}
+	})
+
+	t.Run("探测失败后重新熔断", func(t *testing.T) {
+		u := newOpen()
+		u.allow()
+		u.onFailure(failure)
+		assertState(t, u, stateOpen)
+		if u.allow() {
+			t.Fatal("allow() = true right after failed probe")
+		}
+	})
+
+	t.Run("探测被取消后重新探测", func(t *testing.T) {
+		u := newOpen()
+		u.allow()
+		u.onAbort()
+		assertState(t, u, stateHalfOpen)
+		if !u.allow() {
+			t.Fatal("new probe not allowed after aborted probe")
+		}
+		if u.allow() {
+			t.Fatal("second request allowed while probing")
+		}
+	})
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	u := newUpstream("test", config.UpstreamPolicy{FailureThreshold: -1})
+	for range 10 {
+		if !u.allow() {
+			t.Fatal("request rejected with breaker disabled")
+		}
+		u.onFailure(errors.New("状态码 500"))
+	}
+	assertState(t, u, stateClosed)
+}
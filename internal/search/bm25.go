@@ -0,0 +1,290 @@
+package search
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"knowledge-maker/internal/filelock"
+	"knowledge-maker/internal/model"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Entry 索引条目：一个知识片段
+type Entry struct {
+	ID         string
+	DocumentID string
+	Chunk      model.KnowledgeChunk
+}
+
+// Result 检索结果
+type Result struct {
+	Chunk model.KnowledgeChunk
+	Score float64 // BM25 分数
+}
+
+// indexedEntry 已建立索引的条目
+type indexedEntry struct {
+	Entry
+	length int
+	terms  map[string]int
+}
+
+// Index 进程内倒排索引，使用 BM25 计算相关度，变更后整体写入磁盘文件；打开期间持有文件锁，
+// 其他进程无法同时打开同一个索引文件
+type Index struct {
+	mu       sync.RWMutex
+	path     string
+	lock     *filelock.Lock
+	entries  map[string]*indexedEntry
+	postings map[string]map[string]int // 检索词 -> 条目 ID -> 词频
+	totalLen int
+}
+
+// Open 打开关键词索引并锁定，文件不存在时创建空索引；path 为空时仅保存在内存中。
+// 索引已被其他进程打开时返回 filelock.ErrLocked
+func Open(path string) (*Index, error) {
+	idx := &Index{
+		path:     path,
+		entries:  make(map[string]*indexedEntry),
+		postings: make(map[string]map[string]int),
+	}
+	if path == "" {
+		return idx, nil
+	}
+
+	lock, err := filelock.Acquire(path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("锁定关键词索引失败: %w", err)
+	}
+	if err := idx.load(); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	idx.lock = lock
+	return idx, nil
+}
+
+// load 从磁盘文件读取索引条目
+func (idx *Index) load() error {
+	f, err := os.Open(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开关键词索引文件失败: %v", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	if err := gob.NewDecoder(f).Decode(&entries); err != nil {
+		return fmt.Errorf("读取关键词索引文件失败: %v", err)
+	}
+	for _, e := range entries {
+		idx.add(e)
+	}
+	return nil
+}
+
+// Close 释放文件锁，之后不能再写入
+func (idx *Index) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.lock.Release()
+}
+
+// ReplaceDocument 写入文档的全部片段，替换该文档已有的片段，并持久化到磁盘
+func (idx *Index) ReplaceDocument(documentID string, entries []Entry) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeDocument(documentID)
+	for _, e := range entries {
+		e.DocumentID = documentID
+		idx.add(e)
+	}
+	return idx.save()
+}
+
+// Load 批量写入片段（不删除已有片段），用于从其他存储初始化索引
+func (idx *Index) Load(entries []Entry) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, e := range entries {
+		idx.add(e)
+	}
+	return idx.save()
+}
+
+// DeleteDocument 删除文档的全部片段
+func (idx *Index) DeleteDocument(documentID string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.removeDocument(documentID) == 0 {
+		return nil
+	}
+	return idx.save()
+}
+
+// Search BM25 Top-K 检索
+func (idx *Index) Search(query string, topK int) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.entries) == 0 || topK <= 0 {
+		return nil
+	}
+
+	n := float64(len(idx.entries))
+	avgLen := float64(idx.totalLen) / n
+	scores := make(map[string]float64)
+
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		posting := idx.postings[term]
+		if len(posting) == 0 {
+			continue
+		}
+		df := float64(len(posting))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range posting {
+			length := float64(idx.entries[id].length)
+			freq := float64(tf)
+			scores[id] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > topK {
+		ids = ids[:topK]
+	}
+	for _, id := range ids {
+		chunk := idx.entries[id].Chunk
+		chunk.Score = scores[id]
+		results = append(results, Result{Chunk: chunk, Score: scores[id]})
+	}
+	return results
+}
+
+// Count 返回片段数量
+func (idx *Index) Count() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.entries)
+}
+
+// add 为条目建立索引，已存在相同 ID 的条目时先移除
+func (idx *Index) add(e Entry) {
+	if _, ok := idx.entries[e.ID]; ok {
+		idx.remove(e.ID)
+	}
+
+	terms := make(map[string]int)
+	length := 0
+	for _, term := range Tokenize(e.Chunk.Title + "\n" + e.Chunk.Content) {
+		terms[term]++
+		length++
+	}
+
+	idx.entries[e.ID] = &indexedEntry{Entry: e, length: length, terms: terms}
+	idx.totalLen += length
+	for term, tf := range terms {
+		posting := idx.postings[term]
+		if posting == nil {
+			posting = make(map[string]int)
+			idx.postings[term] = posting
+		}
+		posting[e.ID] = tf
+	}
+}
+
+// remove 移除条目
+func (idx *Index) remove(id string) {
+	entry, ok := idx.entries[id]
+	if !ok {
+		return
+	}
+	for term := range entry.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	idx.totalLen -= entry.length
+	delete(idx.entries, id)
+}
+
+// removeDocument 移除文档的全部条目，返回移除的数量
+func (idx *Index) removeDocument(documentID string) int {
+	var ids []string
+	for id, entry := range idx.entries {
+		if entry.DocumentID == documentID {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		idx.remove(id)
+	}
+	return len(ids)
+}
+
+// save 写入磁盘，先写临时文件再重命名，避免写入中断导致文件损坏
+func (idx *Index) save() error {
+	if idx.path == "" {
+		return nil
+	}
+	if dir := filepath.Dir(idx.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建关键词索引目录失败: %v", err)
+		}
+	}
+
+	entries := make([]Entry, 0, len(idx.entries))
+	for _, entry := range idx.entries {
+		entries = append(entries, entry.Entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	tmp := idx.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("创建关键词索引文件失败: %v", err)
+	}
+	if err := gob.NewEncoder(f).Encode(entries); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("写入关键词索引文件失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入关键词索引文件失败: %v", err)
+	}
+
+	return os.Rename(tmp, idx.path)
+}
@@ -0,0 +1,178 @@
+package search
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"knowledge-maker/internal/filelock"
+	"knowledge-maker/internal/model"
+)
+
+func entry(id, content string) Entry {
+	return Entry{ID: id, Chunk: model.KnowledgeChunk{Content: content}}
+}
+
+// resultContents 返回检索结果的片段内容
+func resultContents(results []Result) []string {
+	var contents []string
+	for _, r := range results {
+		contents = append(contents, r.Chunk.Content)
+	}
+	return contents
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = idx.Load([]Entry{
+		entry("a#0", "schema default.custom.yaml patch"),
+		entry("b#0", "speller/algebra rules speller speller"),
+		entry("c#0", "switcher hotkeys and speller"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		topK  int
+		want  []string
+	}{
+		{
+			name:  "词频越高排名越靠前",
+			query: "speller",
+			topK:  10,
+			want:  []string{"speller/algebra rules speller speller", "switcher hotkeys and speller"},
+		},
+		{
+			name:  "标识符的组成部分可以命中",
+			query: "custom",
+			topK:  10,
+			want:  []string{"schema default.custom.yaml patch"},
+		},
+		{
+			name:  "完整标识符可以命中",
+			query: "speller/algebra",
+			topK:  10,
+			want:  []string{"speller/algebra rules speller speller", "switcher hotkeys and speller"},
+		},
+		{
+			name:  "按 topK 截断",
+			query: "speller",
+			topK:  1,
+			want:  []string{"speller/algebra rules speller speller"},
+		},
+		{
+			name:  "没有命中",
+			query: "emoji",
+			topK:  10,
+		},
+		{
+			name:  "topK 为 0",
+			query: "speller",
+			topK:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := idx.Search(tt.query, tt.topK)
+			if got := resultContents(results); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+			for i, r := range results {
+				if r.Chunk.Score != r.Score || r.Score <= 0 {
+					t.Errorf("result %d: chunk score = %v, score = %v", i, r.Chunk.Score, r.Score)
+				}
+				if i > 0 && r.Score > results[i-1].Score {
+					t.Errorf("results not sorted by score: %v > %v", r.Score, results[i-1].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexReplaceAndDeleteDocument(t *testing.T) {
+	idx, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := idx.ReplaceDocument("doc", []Entry{entry("doc#0", "alpha"), entry("doc#1", "beta")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.ReplaceDocument("other", []Entry{entry("other#0", "alpha gamma")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 文档变短后旧片段不再残留
+	if err := idx.ReplaceDocument("doc", []Entry{entry("doc#0", "delta")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Count(); got != 2 {
+		t.Fatalf("Count() = %d, want 2", got)
+	}
+	if got := resultContents(idx.Search("beta", 10)); got != nil {
+		t.Errorf("Search(beta) = %q, want none", got)
+	}
+	if got := resultContents(idx.Search("alpha", 10)); !reflect.DeepEqual(got, []string{"alpha gamma"}) {
+		t.Errorf("Search(alpha) = %q, want [alpha gamma]", got)
+	}
+
+	if err := idx.DeleteDocument("doc"); err != nil {
+		t.Fatal(err)
+	}
+	if got := idx.Count(); got != 1 {
+		t.Fatalf("Count() = %d, want 1", got)
+	}
+	if got := idx.Search("delta", 10); len(got) != 0 {
+		t.Errorf("Search(delta) after delete = %v, want none", got)
+	}
+}
+
+func TestIndexPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.gob")
+
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.ReplaceDocument("doc", []Entry{entry("doc#0", "persisted keyword")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 打开期间其他调用方无法打开同一个索引
+	if _, err := Open(path); !errors.Is(err, filelock.ErrLocked) {
+		t.Fatalf("second Open() error = %v, want %v", err, filelock.ErrLocked)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resultContents(reopened.Search("keyword", 10)); !reflect.DeepEqual(got, []string{"persisted keyword"}) {
+		t.Errorf("Search after reopen = %q, want [persisted keyword]", got)
+	}
+
+	// 删除同样持久化
+	if err := reopened.DeleteDocument("doc"); err != nil {
+		t.Fatal(err)
+	}
+	reopened.Close()
+	again, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if got := again.Count(); got != 0 {
+		t.Errorf("Count() after delete and reopen = %d, want 0", got)
+	}
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"knowledge-maker/internal/segment"
+)
+
+// identifierJoiners 标识符内部的连接符，如 speller/algebra、default.custom.yaml、luna-pinyin
+const identifierJoiners = "/.-:"
+
+// Tokenize 将文本切分为检索词：英文和数字按标识符切分，保留完整形式（如 speller/algebra、__include）
+// 并额外拆出各组成部分；中文使用词典分词（搜索模式），并去除停用词
+func Tokenize(text string) []string {
+	var tokens []string
+	runes := []rune(strings.ToLower(text))
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.Is(unicode.Han, r):
+			j := i
+			for j < len(runes) && unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			for _, word := range segment.Default().CutForSearch(string(runes[i:j])) {
+				if !segment.IsStopword(word) {
+					tokens = append(tokens, word)
+				}
+			}
+			i = j
+		case isWordRune(r):
+			j := i
+			for j < len(runes) {
+				if isWordRune(runes[j]) {
+					j++
+					continue
+				}
+				// 连接符两侧都是标识符字符时视为标识符的一部分
+				if strings.ContainsRune(identifierJoiners, runes[j]) && j+1 < len(runes) && isWordRune(runes[j+1]) {
+					j++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, identifierTokens(string(runes[i:j]))...)
+			i = j
+		default:
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// isWordRune 判断是否为标识符字符（中文除外）
+func isWordRune(r rune) bool {
+	return r == '_' || (unicode.IsLetter(r) || unicode.IsDigit(r)) && !unicode.Is(unicode.Han, r)
+}
+
+// identifierTokens 返回标识符本身及其组成部分
+func identifierTokens(word string) []string {
+	tokens := []string{word}
+	parts := strings.FieldsFunc(word, func(r rune) bool {
+		return r == '_' || strings.ContainsRune(identifierJoiners, r)
+	})
+	if len(parts) == 1 && parts[0] == word {
+		return tokens
+	}
+	for _, part := range parts {
+		if part != word {
+			tokens = append(tokens, part)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"中文分词并去除停用词", "如何配置输入法", []string{"配置", "输入", "输入法"}},
+		{"标识符保留完整形式并拆出组成部分", "speller/algebra", []string{"speller/algebra", "speller", "algebra"}},
+		{"下划线开头的标识符", "__include", []string{"__include", "include"}},
+		{"文件名", "default.custom.yaml", []string{"default.custom.yaml", "default", "custom", "yaml"}},
+		{"英文统一为小写", "Rime", []string{"rime"}},
+		{"句末的连接符不属于标识符", "luna-pinyin.", []string{"luna-pinyin", "luna", "pinyin"}},
+		{"中英混合", "luna-pinyin 的 key_binder", []string{"luna-pinyin", "luna", "pinyin", "key_binder", "key", "binder"}},
+		{"只有标点", "？！", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
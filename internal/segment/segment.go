@@ -0,0 +1,203 @@
+package segment
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bundledDict 内置词典，格式为每行 "词 频率"
+//
+//go:embed dict.txt
+var bundledDict string
+
+// defaultUserFreq 用户词典未指定频率时使用的词频，保证用户词优先于拆分结果
+const defaultUserFreq = 10000
+
+var (
+	defaultOnce      sync.Once
+	defaultSegmenter *Segmenter
+)
+
+// Default 获取全局分词器（首次调用时加载内置词典）
+func Default() *Segmenter {
+	defaultOnce.Do(func() {
+		defaultSegmenter = New()
+	})
+	return defaultSegmenter
+}
+
+// Segmenter 基于词典的中文分词器：根据词典构建切分有向无环图，按词频选择概率最大的切分路径
+type Segmenter struct {
+	mu     sync.RWMutex
+	freq   map[string]float64
+	total  float64
+	maxLen int // 最长词的字数
+}
+
+// New 创建分词器并加载内置词典
+func New() *Segmenter {
+	s := &Segmenter{freq: make(map[string]float64)}
+	if err := s.LoadDict(strings.NewReader(bundledDict)); err != nil {
+		panic(fmt.Sprintf("加载内置词典失败: %v", err))
+	}
+	return s
+}
+
+// LoadDict 加载词典，每行格式为 "词 [频率] [词性]"，频率缺省时使用默认用户词频，# 开头的行为注释
+func (s *Segmenter) LoadDict(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		freq := float64(defaultUserFreq)
+		if len(fields) > 1 {
+			f, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || f <= 0 {
+				return fmt.Errorf("第 %d 行词频无效: %s", lineNo, line)
+			}
+			freq = f
+		}
+		s.AddWord(fields[0], freq)
+	}
+	return scanner.Err()
+}
+
+// LoadUserDict 加载用户词典文件，用于补充领域词汇
+func (s *Segmenter) LoadUserDict(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开用户词典失败: %v", err)
+	}
+	defer f.Close()
+
+	if err := s.LoadDict(f); err != nil {
+		return fmt.Errorf("加载用户词典 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// AddWord 添加或更新词条
+func (s *Segmenter) AddWord(word string, freq float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.total += freq - s.freq[word]
+	s.freq[word] = freq
+	if n := utf8.RuneCountInString(word); n > s.maxLen {
+		s.maxLen = n
+	}
+}
+
+// Cut 精确模式分词：中文按词典切分，连续的字母数字作为一个词，忽略空白，标点单独成词
+func (s *Segmenter) Cut(text string) []string {
+	var words []string
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.Is(unicode.Han, r):
+			j := i
+			for j < len(runes) && unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			words = append(words, s.cutHan(runes[i:j])...)
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) && !unicode.Is(unicode.Han, runes[j]) {
+				j++
+			}
+			words = append(words, string(runes[i:j]))
+			i = j
+		case unicode.IsSpace(r):
+			i++
+		default:
+			words = append(words, string(r))
+			i++
+		}
+	}
+
+	return words
+}
+
+// CutForSearch 搜索模式分词：在精确模式基础上，为长词额外输出词典中的二字词和三字词，提高召回率
+func (s *Segmenter) CutForSearch(text string) []string {
+	cut := s.Cut(text)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var words []string
+	for _, word := range cut {
+		runes := []rune(word)
+		for _, n := range []int{2, 3} {
+			if len(runes) <= n {
+				continue
+			}
+			for i := 0; i+n <= len(runes); i++ {
+				if sub := string(runes[i : i+n]); s.freq[sub] > 0 {
+					words = append(words, sub)
+				}
+			}
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// cutHan 对连续的中文字符进行切分
+func (s *Segmenter) cutHan(runes []rune) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := len(runes)
+	if n == 0 {
+		return nil
+	}
+	logTotal := math.Log(s.total)
+
+	// 从后向前计算每个位置开始的最大概率路径
+	type route struct {
+		score float64
+		end   int
+	}
+	routes := make([]route, n+1)
+	for i := n - 1; i >= 0; i-- {
+		best := route{score: math.Inf(-1), end: i + 1}
+		for end := i + 1; end <= n && end-i <= s.maxLen; end++ {
+			freq := s.freq[string(runes[i:end])]
+			if freq == 0 {
+				if end > i+1 {
+					continue
+				}
+				freq = 1 // 未登录的单字
+			}
+			if score := math.Log(freq) - logTotal + routes[end].score; score > best.score {
+				best = route{score: score, end: end}
+			}
+		}
+		routes[i] = best
+	}
+
+	var words []string
+	for i := 0; i < n; i = routes[i].end {
+		words = append(words, string(runes[i:routes[i].end]))
+	}
+	return words
+}
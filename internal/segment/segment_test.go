@@ -0,0 +1,140 @@
+package segment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestSegmenter 创建只包含指定词典的分词器
+func newTestSegmenter(t *testing.T, dict string) *Segmenter {
+	t.Helper()
+	s := &Segmenter{freq: make(map[string]float64)}
+	if err := s.LoadDict(strings.NewReader(dict)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+const testDict = `
+# 测试词典
+输入 500
+输入法 300
+法 10
+候选 200
+候选词 100
+选词 50
+词 10
+数量 200
+`
+
+func TestCut(t *testing.T) {
+	s := newTestSegmenter(t, testDict)
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"按词频选择切分路径", "输入法候选词数量", []string{"输入法", "候选词", "数量"}},
+		{"未登录的字单独成词", "新输入法", []string{"新", "输入法"}},
+		{"字母数字连续成词", "Rime0.15输入法", []string{"Rime0", ".", "15", "输入法"}},
+		{"忽略空白，标点单独成词", "输入 ， abc", []string{"输入", "，", "abc"}},
+		{"空文本", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Cut(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cut(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutForSearch(t *testing.T) {
+	s := newTestSegmenter(t, testDict)
+	got := s.CutForSearch("输入法候选词")
+	want := []string{"输入", "输入法", "候选", "选词", "候选词"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CutForSearch() = %q, want %q", got, want)
+	}
+}
+
+func TestAddWord(t *testing.T) {
+	s := newTestSegmenter(t, testDict)
+	if got := s.Cut("小狼毫输入法"); !reflect.DeepEqual(got, []string{"小", "狼", "毫", "输入法"}) {
+		t.Fatalf("Cut() before AddWord = %q", got)
+	}
+
+	s.AddWord("小狼毫", defaultUserFreq)
+	if got := s.Cut("小狼毫输入法"); !reflect.DeepEqual(got, []string{"小狼毫", "输入法"}) {
+		t.Errorf("Cut() after AddWord = %q", got)
+	}
+
+	// 更新词频时总频率同步调整
+	total := s.total
+	s.AddWord("小狼毫", 1)
+	if want := total - defaultUserFreq + 1; s.total != want {
+		t.Errorf("total = %v, want %v", s.total, want)
+	}
+}
+
+func TestLoadDict(t *testing.T) {
+	tests := []struct {
+		name     string
+		dict     string
+		wantErr  bool
+		wantFreq map[string]float64
+	}{
+		{"频率和词性", "输入法 300 n", false, map[string]float64{"输入法": 300}},
+		{"频率缺省时使用默认用户词频", "小狼毫", false, map[string]float64{"小狼毫": defaultUserFreq}},
+		{"忽略注释和空行", "# 注释\n\n输入 5", false, map[string]float64{"输入": 5}},
+		{"频率无效", "输入 abc", true, nil},
+		{"频率不为正数", "输入 0", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Segmenter{freq: make(map[string]float64)}
+			err := s.LoadDict(strings.NewReader(tt.dict))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadDict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(s.freq, tt.wantFreq) {
+				t.Errorf("freq = %v, want %v", s.freq, tt.wantFreq)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ｒｉｍｅ　输入法", "rime 输入法"},
+		{"  多个 \t\n 空白  ", "多个 空白"},
+		{"问题？（全角）", "问题?(全角)"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"小狼毫输入法怎么切换简繁体", []string{"小狼毫", "输入法", "切换", "简繁体"}},
+		{"Rime 的 default.custom.yaml 在哪里", []string{"rime", "default", "custom", "yaml"}},
+		{"输入法？输入法！", []string{"输入法"}},
+		{"请问怎么办？", nil},
+	}
+	for _, tt := range tests {
+		if got := Keywords(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Keywords(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package segment
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords 停用词：检索和统计时忽略的虚词、代词及常见提问用语
+var stopwords = makeSet(`的 了 着 过 地 得 之 与 和 及 或 而 且 并 但 则 就 都 也 还 又 再 才 很 太 更 最 把 被 让 给 对 向 从 在 于 为 以 由 是 有 吗 呢 吧 啊 呀 么 嘛 哦 哈 嗯 呗 啦 哪 个 这 那 此 其 该 某 我 你 您 他 她 它 们
+中 上 下 里 内 外 前 后 时 到 成 来 去 做 用 着 过
+我们 你们 他们 她们 它们 自己 大家 这个 那个 这些 那些 这样 那样 这里 那里 这种 那种 这么 那么
+什么 怎么 怎样 怎么样 怎么办 如何 为什么 为何 哪里 哪个 哪些 哪儿 多少 是否 是不是 有没有 能不能 会不会 可不可以
+请问 请教 一下 一些 一个 一点 可以 能够 应该 需要 想要 想 要 能 会 可 得 吗 呢
+因为 所以 但是 可是 然后 而且 并且 或者 还是 如果 虽然 即使 只要 只有 不过 比如 例如 以及 等等 等
+a an the of to in on at for and or is are was were be been it this that these those with by as from do does how what why which who`)
+
+// makeSet 将空白分隔的词列表转换为集合
+func makeSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// IsStopword 判断是否为停用词
+func IsStopword(word string) bool {
+	return stopwords[word]
+}
+
+// Normalize 规范化查询文本：全角字符转半角，合并连续空白
+func Normalize(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r -= 0xfee0
+		}
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Keywords 提取文本关键词：规范化后分词，去除标点和停用词并去重，英文统一为小写
+func Keywords(text string) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, word := range Default().Cut(strings.ToLower(Normalize(text))) {
+		if seen[word] || IsStopword(word) || !hasWordRune(word) {
+			continue
+		}
+		seen[word] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// hasWordRune 判断是否包含文字或数字（排除纯标点）
+func hasWordRune(word string) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
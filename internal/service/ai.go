@@ -5,29 +5,36 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
 	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/resilience"
+	"knowledge-maker/internal/tokenizer"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+// embeddingBatchSize 单次 Embedding 请求的最大文本数
+const embeddingBatchSize = 64
+
 // AIService AI 服务
 type AIService struct {
-	client *openai.Client
-	model  string
+	providers       []*aiProvider // 按顺序尝试的服务提供方
+	model           string
+	embeddingClient *openai.Client
+	embeddingModel  string
+	firstToken      time.Duration // 流式回复等待首个数据块的超时时间，为 0 时不限制
+	config          *config.AIConfig
 }
 
 // NewAIService 创建 AI 服务实例
 func NewAIService(cfg *config.Config) *AIService {
-	openaiConfig := openai.DefaultConfig(cfg.AI.APIKey)
-	openaiConfig.BaseURL = cfg.AI.BaseURL
-	
-	// 对于流式响应，优化 HTTP 客户端配置
-	openaiConfig.HTTPClient = &http.Client{
-		Timeout: 0, // 不设置超时，让流式响应自然结束
+	// 对于流式响应，优化 HTTP 客户端配置，各提供方共用连接池，重试与熔断按提供方（上游 ai/<name>）分别计算
+	httpClient := &http.Client{
+		Timeout: 0, // 不设置整体超时，由请求上下文控制首个数据块和总超时
 		Transport: &http.Transport{
 			MaxIdleConns:        100,
 			MaxIdleConnsPerHost: 100,
@@ -36,17 +43,87 @@ func NewAIService(cfg *config.Config) *AIService {
 		},
 	}
 
-	client := openai.NewClientWithConfig(openaiConfig)
+	providers := make([]*aiProvider, 0, len(cfg.AI.Providers))
+	for _, p := range cfg.AI.Providers {
+		openaiConfig := openai.DefaultConfig(p.APIKey)
+		openaiConfig.BaseURL = p.BaseURL
+		// 重试只发生在收到响应之前，重复发送未开始返回的对话请求没有副作用
+		openaiConfig.HTTPClient = resilience.Client("ai/"+p.Name, httpClient, resilience.RetryNonIdempotent())
+		providers = append(providers, &aiProvider{
+			name:   p.Name,
+			client: openai.NewClientWithConfig(openaiConfig),
+			model:  p.Model,
+		})
+	}
+	if len(providers) > 1 {
+		names := make([]string, len(providers))
+		for i, p := range providers {
+			names[i] = p.name
+		}
+		logger.Info("AI 服务提供方: %s", strings.Join(names, " → "))
+	}
+
+	// Embedding 未单独配置地址和密钥时与首选提供方共用客户端
+	primary := cfg.AI.Providers[0]
+	embeddingClient := providers[0].client
+	if cfg.AI.EmbeddingBaseURL != "" || cfg.AI.EmbeddingAPIKey != "" {
+		apiKey := primary.APIKey
+		if cfg.AI.EmbeddingAPIKey != "" {
+			apiKey = cfg.AI.EmbeddingAPIKey
+		}
+		embeddingConfig := openai.DefaultConfig(apiKey)
+		embeddingConfig.BaseURL = primary.BaseURL
+		if cfg.AI.EmbeddingBaseURL != "" {
+			embeddingConfig.BaseURL = cfg.AI.EmbeddingBaseURL
+		}
+		embeddingConfig.HTTPClient = resilience.Client("embedding", &http.Client{}, resilience.RetryNonIdempotent())
+		embeddingClient = openai.NewClientWithConfig(embeddingConfig)
+	}
 
 	return &AIService{
-		client: client,
-		model:  cfg.AI.Model,
+		providers:       providers,
+		model:           cfg.AI.Model,
+		embeddingClient: embeddingClient,
+		embeddingModel:  cfg.AI.EmbeddingModel,
+		firstToken:      time.Duration(max(cfg.Timeouts.FirstToken, 0)) * time.Second,
+		config:          &cfg.AI,
 	}
 }
 
-// GenerateResponse 生成 AI 回复
-func (ai *AIService) GenerateResponse(systemPrompt, userQuery, knowledgeContext string) (string, error) {
-	// 构建消息
+// Embed 调用 OpenAI 兼容的 Embedding 接口批量向量化文本
+func (ai *AIService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+
+		resp, err := ai.embeddingClient.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input: texts[start:end],
+			Model: openai.EmbeddingModel(ai.embeddingModel),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("文本向量化失败: %v", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("文本向量化结果数量不匹配: 期望 %d，实际 %d", end-start, len(resp.Data))
+		}
+
+		// 按 index 排序，保证与输入顺序一致
+		batch := make([][]float32, end-start)
+		for _, item := range resp.Data {
+			if item.Index < 0 || item.Index >= len(batch) {
+				return nil, fmt.Errorf("文本向量化结果索引越界: %d", item.Index)
+			}
+			batch[item.Index] = item.Embedding
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	return vectors, nil
+}
+
+// buildMessages 构建发送给 AI 的消息列表：系统提示词、历史对话、当前用户消息
+func (ai *AIService) buildMessages(systemPrompt, userMessage string, history []model.ChatMessage) []openai.ChatCompletionMessage {
 	messages := []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
@@ -54,190 +131,257 @@ func (ai *AIService) GenerateResponse(systemPrompt, userQuery, knowledgeContext
 		},
 	}
 
-	// 如果有知识库上下文，添加到消息中
-	if knowledgeContext != "" {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: fmt.Sprintf("参考知识库内容：\n%s\n\n用户问题：%s", knowledgeContext, userQuery),
-		})
-	} else {
+	// 历史对话按原顺序放在当前问题之前
+	for _, msg := range history {
 		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: userQuery,
+			Role:    msg.Role,
+			Content: msg.Content,
 		})
 	}
 
+	// 当前用户消息（已按模板填入知识库内容）
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: userMessage,
+	})
+
+	return messages
+}
+
+// GenerateResponse 生成 AI 回复，同时返回实际提供服务的提供方名称和实际请求的模型
+func (ai *AIService) GenerateResponse(ctx context.Context, systemPrompt, userMessage string, history []model.ChatMessage, opts ChatOptions) (answer, provider, modelName string, err error) {
+	// 构建消息
+	messages := ai.buildMessages(systemPrompt, userMessage, history)
+
 	// 创建聊天完成请求
 	req := openai.ChatCompletionRequest{
-		Model:       ai.model,
-		Messages:    messages,
-		MaxTokens:   2000,
-		Temperature: 0.7,
+		Model:    opts.Model,
+		Messages: messages,
 	}
+	applyGeneration(&req, opts.Generation)
 
 	// 调用 AI API
-	resp, err := ai.client.CreateChatCompletion(context.Background(), req)
+	resp, p, err := ai.createChatCompletion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("AI 生成回复失败: %v", err)
+		return "", "", "", fmt.Errorf("AI 生成回复失败: %w", err)
 	}
+	modelName, _ = p.modelFor(opts.Model, ai.model)
 
 	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("AI 未返回任何回复")
+		return "", p.name, modelName, fmt.Errorf("AI 未返回任何回复")
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	logger.Info("AI 回复由 %s 生成，模型: %s", p.name, resp.Model)
+	return resp.Choices[0].Message.Content, p.name, modelName, nil
 }
 
-// GenerateStreamResponse 生成流式 AI 回复
-func (ai *AIService) GenerateStreamResponse(systemPrompt, userQuery, knowledgeContext string) (*openai.ChatCompletionStream, error) {
-	logger.Info("开始创建 AI 流式请求")
-	
-	// 构建消息
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: systemPrompt,
+// RewriteQuery 结合历史对话将后续问题改写为独立的检索问题
+func (ai *AIService) RewriteQuery(ctx context.Context, prompt, userQuery string, history []model.ChatMessage) (string, error) {
+	var b strings.Builder
+	b.WriteString("对话历史：\n")
+	for _, msg := range history {
+		role := "用户"
+		if msg.Role == openai.ChatMessageRoleAssistant {
+			role = "助手"
+		}
+		// 历史回答可能很长，改写只需要主题信息
+		content := msg.Content
+		if runes := []rune(content); len(runes) > 300 {
+			content = string(runes[:300]) + "..."
+		}
+		b.WriteString(fmt.Sprintf("%s：%s\n", role, content))
+	}
+	b.WriteString(fmt.Sprintf("\n后续问题：%s\n独立问题：", userQuery))
+
+	req := openai.ChatCompletionRequest{
+		Model: ai.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prompt},
+			{Role: openai.ChatMessageRoleUser, Content: b.String()},
 		},
+		MaxTokens: 200,
+		// 温度为 0 会被 go-openai 省略，经 nonZero 转换为 zeroSampling 后发送
+		Temperature: nonZero(0),
 	}
 
-	// 如果有知识库上下文，添加到消息中
-	if knowledgeContext != "" {
+	resp, _, err := ai.createChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("AI 改写问题失败: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("AI 未返回改写结果")
+	}
 
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: fmt.Sprintf("参考知识库内容：\n%s\n\n用户问题：%s", knowledgeContext, userQuery),
-		})
-		logger.Info("已添加知识库上下文，总消息数: %d，上下文长度: %d", len(messages), len(knowledgeContext))
-	} else {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: userQuery,
-		})
-		logger.Info("无知识库上下文，总消息数: %d", len(messages))
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// Complete 使用对话模型生成一次性回复（温度为 0），用于重排序等辅助任务
+func (ai *AIService) Complete(ctx context.Context, systemPrompt, userPrompt string, maxTokens int) (string, error) {
+	req := openai.ChatCompletionRequest{
+		Model: ai.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: openai.ChatMessageRoleUser, Content: userPrompt},
+		},
+		MaxTokens: maxTokens,
+		// 温度为 0 会被 go-openai 省略，经 nonZero 转换为 zeroSampling 后发送
+		Temperature: nonZero(0),
 	}
 
+	resp, _, err := ai.createChatCompletion(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("AI API 调用失败: %v", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("AI 未返回有效回复")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// GenerateStreamResponse 生成流式 AI 回复
+func (ai *AIService) GenerateStreamResponse(ctx context.Context, systemPrompt, userMessage string, history []model.ChatMessage, opts ChatOptions) (*chatStream, error) {
+	logger.Info("开始创建 AI 流式请求")
+
+	// 构建消息
+	messages := ai.buildMessages(systemPrompt, userMessage, history)
+	logger.Info("总消息数: %d，历史消息数: %d，用户消息长度: %d", len(messages), len(history), len(userMessage))
+
 	// 创建流式聊天完成请求
 	req := openai.ChatCompletionRequest{
-		Model:       ai.model,
-		Messages:    messages,
-		MaxTokens:   2000,
-		Temperature: 0.7,
-		Stream:      true, // 启用流式输出
+		Model:    opts.Model,
+		Messages: messages,
+		Stream:   true, // 启用流式输出
+	}
+	if ai.config.StreamUsage {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
 	}
+	applyGeneration(&req, opts.Generation)
+
+	logger.Info("准备调用 AI API，模型: %s", opts.Model)
 
-	logger.Info("准备调用 AI API，模型: %s", ai.model)
-	
-	// 调用流式 AI API - 不使用超时上下文，让流式响应立即开始
-	stream, err := ai.client.CreateChatCompletionStream(context.Background(), req)
+	// 调用流式 AI API，首个数据块超时由 createChatCompletionStream 控制
+	stream, err := ai.createChatCompletionStream(ctx, req)
 	if err != nil {
 		logger.Error("AI 流式生成回复失败: %v", err)
 		return nil, fmt.Errorf("AI 流式生成回复失败: %v", err)
 	}
 
-	logger.Info("AI 流式请求创建成功")
+	logger.Info("AI 流式请求创建成功，提供方: %s，模型: %s", stream.Provider, stream.Model)
+	for _, message := range messages {
+		stream.promptTokens += countMessageTokens(message.Content)
+	}
 	return stream, nil
 }
 
-// ProcessStreamResponse 处理流式响应并通过通道发送
-func (ai *AIService) ProcessStreamResponse(stream *openai.ChatCompletionStream, responseChan chan<- model.StreamContent, errorChan chan<- error, userQuery, knowledgeContext string) {
+// ProcessStreamResponse 处理流式响应并通过通道发送：思考内容和回答内容分别放在 ReasoningContent 和 Content 中，
+// answerPrefix 在回答开始时先发送，流结束时发送 token 用量；返回完整的回答内容（含 answerPrefix，不含思考内容）。
+// ctx 取消后不再阻塞发送，上游流随之中断并返回错误
+func (ai *AIService) ProcessStreamResponse(ctx context.Context, stream *chatStream, responseChan chan<- model.StreamContent, userQuery, knowledgeContext, answerPrefix string) (string, error) {
 	defer stream.Close()
 
 	// 使用统一日志系统记录流式处理信息
 	logger.Info("ProcessStreamResponse 开始处理")
 	logger.Info("用户问题: %s", userQuery)
-	
-	if knowledgeContext != "" {
-		logger.Info("知识库上下文长度: %d", len(knowledgeContext))
-	} else {
-		logger.Info("知识库上下文长度: 0")
-	}
-
-	// 立即开始处理流式响应
-	logger.Info("开始接收流式数据")
+	logger.Info("知识库上下文长度: %d", len(knowledgeContext))
 
-	var reasoningStarted bool
-	var reasoningEnded bool
 	var answerStarted bool
 	var hasReasoningContent bool
+	var answer strings.Builder
+	var generated strings.Builder // 模型生成的全部内容（含思考内容），用于估算 token 用量
+	var usage *openai.Usage
+
+	// send 发送数据，客户端断开后丢弃
+	send := func(content model.StreamContent) {
+		select {
+		case responseChan <- content:
+		case <-ctx.Done():
+		}
+	}
+
+	// startAnswer 发送附加在回答前的内容
+	startAnswer := func() {
+		answerStarted = true
+		if answerPrefix != "" {
+			send(model.StreamContent{Content: answerPrefix})
+			answer.WriteString(answerPrefix)
+		}
+	}
+
+	// emit 发送思考内容和回答内容
+	emit := func(reasoning, content string) {
+		if reasoning != "" {
+			logger.Debug("收到思考内容: %s", reasoning[:min(50, len(reasoning))])
+			hasReasoningContent = true
+			generated.WriteString(reasoning)
+			send(model.StreamContent{ReasoningContent: reasoning})
+		}
+		if content != "" {
+			logger.Debug("收到普通内容: %s", content[:min(20, len(content))])
+			if !answerStarted {
+				logger.Info("内容回答开始")
+				startAnswer()
+			}
+			answer.WriteString(content)
+			generated.WriteString(content)
+			send(model.StreamContent{Content: content})
+		}
+	}
+
+	// 不使用 reasoning_content 字段的提供方会把思考过程以 <think>...</think> 内联在 content 开头
+	var think thinkParser
 
 	for {
 		response, err := stream.Recv()
 		if err != nil {
 			if err == io.EOF {
-				// 记录是否有思考内容和回答结束
+				emit(think.Flush())
 				logger.Info("是否有思考内容: %v", hasReasoningContent)
 				logger.Info("内容回答结束")
-				
-				// 流结束，如果还在思考阶段，发送结束标记
-				if reasoningStarted && !reasoningEnded {
-					responseChan <- model.StreamContent{Content: "</think>"}
-					reasoningEnded = true
-				}
-				// 如果还没开始答案，发送答案开始标记
+				// 只有思考内容、没有回答内容时也发送附加内容
 				if !answerStarted {
-					responseChan <- model.StreamContent{Content: "<answer>"}
+					startAnswer()
 				}
-				return
+				send(model.StreamContent{Usage: streamUsage(usage, stream.promptTokens, generated.String())})
+				return answer.String(), nil
 			}
-			logger.Error("接收流式响应失败: %v", err)
-			errorChan <- fmt.Errorf("接收流式响应失败: %v", err)
-			return
-		}
-
-		if len(response.Choices) > 0 {
-			choice := response.Choices[0]
-			streamContent := model.StreamContent{}
-
-			// 使用新版本 go-openai 的 reasoning_content 字段
-			if choice.Delta.ReasoningContent != "" {
-				logger.Debug("收到思考内容: %s", choice.Delta.ReasoningContent[:min(50, len(choice.Delta.ReasoningContent))])
-				
-				// 第一次收到 reasoning_content 时发送开始标记
-				if !reasoningStarted {
-					logger.Info("发送思考开始标记")
-					responseChan <- model.StreamContent{Content: "<think>"}
-					reasoningStarted = true
-				}
-				
-				// 标记找到了思考内容
-				hasReasoningContent = true
-				
-				// 发送 reasoning_content 内容
-				streamContent.ReasoningContent = choice.Delta.ReasoningContent
-				
-				// 立即发送思考内容
-				responseChan <- streamContent
+			if ctx.Err() != nil {
+				// 客户端断开连接或超过总超时时间
+				logger.Warn("流式回答中断: %v", context.Cause(ctx))
+				return "", fmt.Errorf("回答已中断: %w", context.Cause(ctx))
 			}
+			logger.Error("接收流式响应失败: %v", err)
+			return "", fmt.Errorf("接收流式响应失败: %v", err)
+		}
 
-			// 处理普通内容
-			if choice.Delta.Content != "" {
-				logger.Debug("收到普通内容: %s", choice.Delta.Content[:min(20, len(choice.Delta.Content))])
-				
-				// 如果之前有 reasoning_content 但现在开始有普通内容，说明思考结束
-				if reasoningStarted && !reasoningEnded && choice.Delta.ReasoningContent == "" {
-					logger.Info("思考阶段结束，发送结束标记")
-					responseChan <- model.StreamContent{Content: "</think>"}
-					reasoningEnded = true
-				}
-				
-				// 如果思考已结束且还没开始答案，发送答案开始标记
-				if reasoningEnded && !answerStarted {
-					logger.Info("内容回答开始")
-					responseChan <- model.StreamContent{Content: "<answer>"}
-					answerStarted = true
-				} else if !reasoningStarted && !answerStarted {
-					// 如果没有思考阶段，直接开始答案
-					logger.Info("内容回答开始（无思考阶段）")
-					responseChan <- model.StreamContent{Content: "<answer>"}
-					answerStarted = true
-				}
-				
-				streamContent.Content = choice.Delta.Content
-				
-				// 发送普通内容
-				responseChan <- streamContent
-			}
+		// 开启 ai.stream_usage 时，用量在最后一个不含 choices 的数据块中返回
+		if response.Usage != nil {
+			usage = response.Usage
+		}
+		if len(response.Choices) == 0 {
+			continue
 		}
+		delta := response.Choices[0].Delta
+		reasoning, content := think.Feed(delta.Content)
+		emit(delta.ReasoningContent+reasoning, content)
+	}
+}
+
+// streamUsage 转换服务提供方返回的 token 用量；未返回时按提示词和生成内容估算
+func streamUsage(usage *openai.Usage, promptTokens int, generated string) *model.Usage {
+	if usage != nil {
+		return &model.Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+	completionTokens := tokenizer.Count(generated)
+	return &model.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+		Estimated:        true,
 	}
 }
 
@@ -247,4 +391,4 @@ func min(a, b int) int {
 		return a
 	}
 	return b
-}
\ No newline at end of file
+}
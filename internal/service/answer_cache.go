@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service/retriever"
+	"knowledge-maker/internal/vectorstore"
+)
+
+// answerCacheEntry 缓存的回答
+type answerCacheEntry struct {
+	partition        string
+	query            string    // 规范化后的问题
+	vector           []float32 // 问题的归一化向量
+	answer           string
+	knowledgeContext string
+	sources          []model.Source
+	bases            []string // 生成回答时检索的知识库，知识库内容变更时据此失效
+	expiresAt        time.Time
+	lastUsed         time.Time
+}
+
+// cachedAnswer 命中的缓存回答
+type cachedAnswer struct {
+	Answer           string
+	KnowledgeContext string
+	Sources          []model.Source
+	Similarity       float64
+}
+
+// answerCache 语义答案缓存：按问题向量的余弦相似度查找之前生成的回答；
+// 不同的模型和知识库指定方式（partition）之间互不共享
+type answerCache struct {
+	mu        sync.Mutex
+	entries   []*answerCacheEntry
+	embedder  retriever.Embedder
+	config    *config.AnswerCacheConfig
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// newAnswerCache 创建语义答案缓存
+func newAnswerCache(cfg *config.AnswerCacheConfig, embedder retriever.Embedder) *answerCache {
+	return &answerCache{
+		embedder: embedder,
+		config:   cfg,
+	}
+}
+
+// answerCachePartition 根据使用的模型、用户语言和请求指定的知识库确定缓存分区，未指定知识库（自动路由）时只按模型和语言分区
+func answerCachePartition(modelName, language string, requested []string) string {
+	bases := slices.Clone(requested)
+	sort.Strings(bases)
+	return modelName + "\x00" + language + "\x00" + strings.Join(slices.Compact(bases), ",")
+}
+
+// Lookup 查找相似问题的回答；未命中时返回问题向量供 Store 复用，向量化失败时向量为 nil
+func (ac *answerCache) Lookup(ctx context.Context, partition, query string) (*cachedAnswer, []float32) {
+	normalized := normalizeCacheQuery(query)
+
+	// 完全相同的问题无需向量化
+	if hit := ac.find(partition, func(e *answerCacheEntry) float64 {
+		if e.query == normalized {
+			return 1
+		}
+		return -1
+	}); hit != nil {
+		return hit, nil
+	}
+
+	vector, err := ac.embed(ctx, normalized)
+	if err != nil {
+		logger.Warn("答案缓存查询向量化失败，跳过缓存: %v", err)
+		ac.recordMiss()
+		return nil, nil
+	}
+
+	hit := ac.find(partition, func(e *answerCacheEntry) float64 {
+		if len(e.vector) != len(vector) {
+			return -1
+		}
+		return vectorstore.Dot(e.vector, vector)
+	})
+	if hit == nil {
+		ac.recordMiss()
+	}
+	return hit, vector
+}
+
+// find 在分区内查找相似度最高且不低于阈值的回答，命中时更新使用时间和命中计数
+func (ac *answerCache) find(partition string, similarity func(e *answerCacheEntry) float64) *cachedAnswer {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.removeExpired(time.Now())
+
+	var best *answerCacheEntry
+	bestScore := ac.config.Threshold
+	for _, e := range ac.entries {
+		if e.partition != partition {
+			continue
+		}
+		if score := similarity(e); score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	best.lastUsed = time.Now()
+	ac.hits++
+	return &cachedAnswer{
+		Answer:           best.answer,
+		KnowledgeContext: best.knowledgeContext,
+		Sources:          best.sources,
+		Similarity:       bestScore,
+	}
+}
+
+// Store 缓存回答，vector 为 nil 时重新向量化问题；相同问题的旧回答被替换
+func (ac *answerCache) Store(ctx context.Context, partition, query string, vector []float32, answer, knowledgeContext string, sources []model.Source, bases []string) {
+	if strings.TrimSpace(answer) == "" {
+		return
+	}
+
+	normalized := normalizeCacheQuery(query)
+	if vector == nil {
+		var err error
+		vector, err = ac.embed(ctx, normalized)
+		if err != nil {
+			logger.Warn("答案缓存写入向量化失败，跳过缓存: %v", err)
+			return
+		}
+	}
+
+	now := time.Now()
+	entry := &answerCacheEntry{
+		partition:        partition,
+		query:            normalized,
+		vector:           vector,
+		answer:           answer,
+		knowledgeContext: knowledgeContext,
+		sources:          sources,
+		bases:            bases,
+		expiresAt:        now.Add(time.Duration(ac.config.TTL) * time.Second),
+		lastUsed:         now,
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.entries = slices.DeleteFunc(ac.entries, func(e *answerCacheEntry) bool {
+		return e.partition == partition && e.query == normalized
+	})
+	ac.entries = append(ac.entries, entry)
+
+	// 超出容量时淘汰最久未使用的回答
+	for ac.config.Size > 0 && len(ac.entries) > ac.config.Size {
+		oldest := 0
+		for i, e := range ac.entries {
+			if e.lastUsed.Before(ac.entries[oldest].lastUsed) {
+				oldest = i
+			}
+		}
+		ac.entries = slices.Delete(ac.entries, oldest, oldest+1)
+		ac.evictions++
+	}
+}
+
+// PurgeBase 清除检索过指定知识库的回答，返回清除数量
+func (ac *answerCache) PurgeBase(base string) int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	before := len(ac.entries)
+	ac.entries = slices.DeleteFunc(ac.entries, func(e *answerCacheEntry) bool {
+		return slices.Contains(e.bases, base)
+	})
+	return before - len(ac.entries)
+}
+
+// Purge 清除全部回答，返回清除数量
+func (ac *answerCache) Purge() int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	n := len(ac.entries)
+	ac.entries = nil
+	return n
+}
+
+// Stats 获取缓存统计信息
+func (ac *answerCache) Stats() model.CacheStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	stats := model.CacheStats{
+		Enabled:   true,
+		Size:      len(ac.entries),
+		Capacity:  ac.config.Size,
+		TTL:       ac.config.TTL,
+		Hits:      ac.hits,
+		Misses:    ac.misses,
+		Evictions: ac.evictions,
+	}
+	if total := ac.hits + ac.misses; total > 0 {
+		stats.HitRate = float64(ac.hits) / float64(total)
+	}
+	return stats
+}
+
+// embed 向量化问题并归一化
+func (ac *answerCache) embed(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := ac.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	return vectorstore.Normalize(vectors[0]), nil
+}
+
+// recordMiss 记录一次未命中
+func (ac *answerCache) recordMiss() {
+	ac.mu.Lock()
+	ac.misses++
+	ac.mu.Unlock()
+}
+
+// removeExpired 删除过期的回答，调用方需持有锁
+func (ac *answerCache) removeExpired(now time.Time) {
+	ac.entries = slices.DeleteFunc(ac.entries, func(e *answerCacheEntry) bool {
+		return now.After(e.expiresAt)
+	})
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"knowledge-maker/internal/config"
+)
+
+// fakeEmbedder 按预设向量化文本并记录调用次数，未预设的文本返回错误
+type fakeEmbedder struct {
+	vectors map[string][]float32
+	calls   int
+}
+
+func (f *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.calls++
+	result := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, ok := f.vectors[text]
+		if !ok {
+			return nil, errors.New("未预设的文本: " + text)
+		}
+		result[i] = vector
+	}
+	return result, nil
+}
+
+func newTestAnswerCache(size int) (*answerCache, *fakeEmbedder) {
+	embedder := &fakeEmbedder{vectors: map[string][]float32{
+		"如何配置输入法":  {1, 0, 0},
+		"怎样配置输入法":  {0.99, 0.1, 0},
+		"如何删除词库":   {0, 1, 0},
+		"如何切换简繁体":  {0, 0, 1},
+		"如何切换到繁体字": {0, 0.1, 0.99},
+	}}
+	cfg := &config.AnswerCacheConfig{Threshold: 0.95, Size: size, TTL: 60}
+	return newAnswerCache(cfg, embedder), embedder
+}
+
+func TestAnswerCacheLookup(t *testing.T) {
+	const partition = "model\x00zh\x00"
+	tests := []struct {
+		name      string
+		partition string
+		query     string
+		wantHit   bool
+		wantEmbed int // Lookup 中向量化的次数
+	}{
+		{"完全相同的问题不需要向量化", partition, "如何配置输入法？", true, 0},
+		{"相似的问题", partition, "怎样配置输入法", true, 1},
+		{"不相似的问题", partition, "如何删除词库", false, 1},
+		{"不同分区不共享", "other\x00zh\x00", "如何配置输入法", false, 1},
+		{"向量化失败视为未命中", partition, "未知问题", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac, embedder := newTestAnswerCache(10)
+			ac.Store(context.Background(), partition, "如何配置输入法", nil, "回答", "知识", nil, []string{"docs"})
+			embedder.calls = 0
+
+			hit, _ := ac.Lookup(context.Background(), tt.partition, tt.query)
+			if (hit != nil) != tt.wantHit {
+				t.Fatalf("Lookup() hit = %v, want %v", hit != nil, tt.wantHit)
+			}
+			if hit != nil && (hit.Answer != "回答" || hit.KnowledgeContext != "知识") {
+				t.Errorf("Lookup() = %+v", hit)
+			}
+			if embedder.calls != tt.wantEmbed {
+				t.Errorf("embed calls = %d, want %d", embedder.calls, tt.wantEmbed)
+			}
+
+			stats := ac.Stats()
+			if tt.wantHit && stats.Hits != 1 || !tt.wantHit && stats.Misses != 1 {
+				t.Errorf("Stats() = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestAnswerCacheLookupReturnsVector(t *testing.T) {
+	ac, embedder := newTestAnswerCache(10)
+
+	hit, vector := ac.Lookup(context.Background(), "p", "如何删除词库")
+	if hit != nil || vector == nil {
+		t.Fatalf("Lookup() = %v, %v, want miss with vector", hit, vector)
+	}
+
+	// 复用 Lookup 得到的向量，不再向量化
+	embedder.calls = 0
+	ac.Store(context.Background(), "p", "如何删除词库", vector, "回答", "", nil, nil)
+	if embedder.calls != 0 {
+		t.Errorf("embed calls = %d, want 0", embedder.calls)
+	}
+	if hit, _ := ac.Lookup(context.Background(), "p", "如何删除词库"); hit == nil {
+		t.Error("Lookup() after Store = miss")
+	}
+}
+
+func TestAnswerCacheStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("相同问题替换旧回答", func(t *testing.T) {
+		ac, _ := newTestAnswerCache(10)
+		ac.Store(ctx, "p", "如何配置输入法", nil, "旧回答", "", nil, nil)
+		ac.Store(ctx, "p", "如何配置输入法？", nil, "新回答", "", nil, nil)
+		if size := ac.Stats().Size; size != 1 {
+			t.Fatalf("size = %d, want 1", size)
+		}
+		if hit, _ := ac.Lookup(ctx, "p", "如何配置输入法"); hit == nil || hit.Answer != "新回答" {
+			t.Errorf("Lookup() = %+v, want 新回答", hit)
+		}
+	})
+
+	t.Run("空回答不缓存", func(t *testing.T) {
+		ac, _ := newTestAnswerCache(10)
+		ac.Store(ctx, "p", "如何配置输入法", nil, " \n", "", nil, nil)
+		if size := ac.Stats().Size; size != 0 {
+			t.Errorf("size = %d, want 0", size)
+		}
+	})
+
+	t.Run("超出容量时淘汰最久未使用的回答", func(t *testing.T) {
+		ac, _ := newTestAnswerCache(2)
+		ac.Store(ctx, "p", "如何配置输入法", nil, "回答一", "", nil, nil)
+		time.Sleep(time.Millisecond)
+		ac.Store(ctx, "p", "如何删除词库", nil, "回答二", "", nil, nil)
+		time.Sleep(time.Millisecond)
+		ac.Lookup(ctx, "p", "如何配置输入法")
+		time.Sleep(time.Millisecond)
+		ac.Store(ctx, "p", "如何切换简繁体", nil, "回答三", "", nil, nil)
+
+		if stats := ac.Stats(); stats.Size != 2 || stats.Evictions != 1 {
+			t.Fatalf("Stats() = %+v, want size 2 and 1 eviction", stats)
+		}
+		if hit, _ := ac.Lookup(ctx, "p", "如何删除词库"); hit != nil {
+			t.Error("least recently used answer not evicted")
+		}
+		if hit, _ := ac.Lookup(ctx, "p", "如何配置输入法"); hit == nil {
+			t.Error("recently used answer evicted")
+		}
+	})
+
+	t.Run("过期的回答不再命中", func(t *testing.T) {
+		ac, _ := newTestAnswerCache(10)
+		ac.Store(ctx, "p", "如何配置输入法", nil, "回答", "", nil, nil)
+		ac.entries[0].expiresAt = time.Now().Add(-time.Second)
+
+		if hit, _ := ac.Lookup(ctx, "p", "如何配置输入法"); hit != nil {
+			t.Error("Lookup() hit expired answer")
+		}
+		if size := ac.Stats().Size; size != 0 {
+			t.Errorf("size = %d, want 0", size)
+		}
+	})
+}
+
+func TestAnswerCachePurgeBase(t *testing.T) {
+	ctx := context.Background()
+	ac, _ := newTestAnswerCache(10)
+	ac.Store(ctx, "p", "如何配置输入法", nil, "回答一", "", nil, []string{"docs", "faq"})
+	ac.Store(ctx, "p", "如何删除词库", nil, "回答二", "", nil, []string{"faq"})
+	ac.Store(ctx, "p", "如何切换简繁体", nil, "回答三", "", nil, nil)
+
+	if n := ac.PurgeBase("docs"); n != 1 {
+		t.Errorf("PurgeBase(docs) = %d, want 1", n)
+	}
+	if n := ac.PurgeBase("faq"); n != 1 {
+		t.Errorf("PurgeBase(faq) = %d, want 1", n)
+	}
+	if n := ac.Purge(); n != 1 {
+		t.Errorf("Purge() = %d, want 1", n)
+	}
+}
+
+func TestAnswerCachePartition(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   []string
+		sameAs bool
+	}{
+		{"知识库顺序无关", []string{"docs", "faq"}, []string{"faq", "docs"}, true},
+		{"重复的知识库合并", []string{"docs", "docs"}, []string{"docs"}, true},
+		{"不同的知识库", []string{"docs"}, []string{"faq"}, false},
+		{"自动路由与指定知识库不同", nil, []string{"docs"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := answerCachePartition("model", "zh", tt.a)
+			b := answerCachePartition("model", "zh", tt.b)
+			if (a == b) != tt.sameAs {
+				t.Errorf("partition(%v) == partition(%v) is %v, want %v", tt.a, tt.b, a == b, tt.sameAs)
+			}
+		})
+	}
+
+	if answerCachePartition("a", "zh", nil) == answerCachePartition("b", "zh", nil) {
+		t.Error("different models share a partition")
+	}
+	if answerCachePartition("a", "zh", nil) == answerCachePartition("a", "en", nil) {
+		t.Error("different languages share a partition")
+	}
+}
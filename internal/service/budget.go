@@ -0,0 +1,206 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/tokenizer"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// messageTokenOverhead 每条消息的格式开销（角色标记、分隔符）
+	messageTokenOverhead = 4
+	// replyTokenOverhead 回复引导的固定开销
+	replyTokenOverhead = 3
+	// minChunkTokens 截断后的知识片段至少保留的 token 数，空间不足时直接丢弃该片段
+	minChunkTokens = 64
+	// defaultContextWindow 无法推断模型上下文窗口时使用的默认值
+	defaultContextWindow = 8192
+	// truncatedSuffix 截断的知识片段末尾追加的省略号
+	truncatedSuffix = "……"
+)
+
+// builtinContextWindows 常见模型的上下文窗口，按模型名称前缀匹配，较长的前缀优先
+var builtinContextWindows = map[string]int{
+	"gpt-3.5-turbo":     16385,
+	"gpt-4":             8192,
+	"gpt-4-32k":         32768,
+	"gpt-4-turbo":       128000,
+	"gpt-4o":            128000,
+	"gpt-4.1":           1047576,
+	"o1":                200000,
+	"o3":                200000,
+	"o4-mini":           200000,
+	"deepseek-chat":     65536,
+	"deepseek-reasoner": 65536,
+	"qwen-turbo":        131072,
+	"qwen-plus":         131072,
+	"qwen-max":          32768,
+	"qwen-long":         1000000,
+	"glm-4":             128000,
+	"moonshot-v1-8k":    8192,
+	"moonshot-v1-32k":   32768,
+	"moonshot-v1-128k":  131072,
+	"hunyuan":           32768,
+}
+
+// promptBudget 提示词预算管理：估算提示词 token 数，超出模型上下文窗口时从最早的历史对话开始裁剪，
+// 并丢弃或截断排名靠后的知识片段，保证为回复预留足够空间
+type promptBudget struct {
+	config *config.Config
+}
+
+// newPromptBudget 创建提示词预算管理器
+func newPromptBudget(cfg *config.Config) *promptBudget {
+	return &promptBudget{config: cfg}
+}
+
+// ContextWindow 获取模型的上下文窗口
+func (pb *promptBudget) ContextWindow(modelName string) int {
+	return contextWindow(&pb.config.AI, modelName)
+}
+
+// contextWindow 获取模型的上下文窗口：优先使用 context_windows 中的前缀匹配，其次 context_window，最后使用内置值
+func contextWindow(cfg *config.AIConfig, modelName string) int {
+	if window := matchContextWindow(cfg.ContextWindows, modelName); window > 0 {
+		return window
+	}
+	if cfg.ContextWindow > 0 {
+		return cfg.ContextWindow
+	}
+	if window := matchContextWindow(builtinContextWindows, modelName); window > 0 {
+		return window
+	}
+	return defaultContextWindow
+}
+
+// matchContextWindow 按最长前缀匹配模型名称
+func matchContextWindow(windows map[string]int, modelName string) int {
+	modelName = strings.ToLower(modelName)
+	prefixes := make([]string, 0, len(windows))
+	for prefix := range windows {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(modelName, strings.ToLower(prefix)) {
+			return windows[prefix]
+		}
+	}
+	return 0
+}
+
+// Fit 按预算裁剪知识片段和历史对话，completion 为回复预留的 token 数，userFrame 为不含知识库内容的用户消息，
+// 返回可以放入提示词的片段和历史
+func (pb *promptBudget) Fit(modelName string, completion int, systemPrompt, userFrame string, chunks []model.KnowledgeChunk, history []model.ChatMessage) ([]model.KnowledgeChunk, []model.ChatMessage) {
+	window := pb.ContextWindow(modelName)
+
+	// 系统提示词和当前问题必须保留
+	fixed := countMessageTokens(systemPrompt) + countMessageTokens(userFrame) + replyTokenOverhead
+	// 不限制回复长度（completion 为负数）时不预留空间
+	completion = max(completion, 0)
+	remaining := window - completion - fixed
+
+	chunkTokens := make([]int, len(chunks))
+	knowledgeTotal := 0
+	for i, chunk := range chunks {
+		// 片段之间以空行分隔，计 2 个 token
+		chunkTokens[i] = tokenizer.Count(formatKnowledgeContext([]model.KnowledgeChunk{chunk})) + 2
+		knowledgeTotal += chunkTokens[i]
+	}
+	historyTokens := make([]int, len(history))
+	historyTotal := 0
+	for i, msg := range history {
+		historyTokens[i] = countMessageTokens(msg.Content)
+		historyTotal += historyTokens[i]
+	}
+
+	if knowledgeTotal+historyTotal <= remaining {
+		logger.Debug("提示词预计 token: %d，模型上下文窗口: %d，预留回复: %d", fixed+knowledgeTotal+historyTotal, window, completion)
+		return chunks, history
+	}
+	if remaining <= 0 {
+		logger.Warn("系统提示词和问题已超出模型上下文窗口（窗口 %d，预留回复 %d，固定部分 %d），丢弃全部知识片段和历史对话", window, completion, fixed)
+		return nil, nil
+	}
+
+	// 知识库内容至少可以使用 knowledge_budget_ratio 比例的空间，历史对话较短时可以使用更多
+	knowledgeBudget := max(remaining-historyTotal, int(float64(remaining)*pb.config.RAG.KnowledgeBudgetRatio))
+	keptChunks, knowledgeUsed := fitChunks(chunks, chunkTokens, min(knowledgeBudget, knowledgeTotal))
+	keptHistory, historyUsed := fitHistory(history, historyTokens, remaining-knowledgeUsed)
+
+	logger.Info("提示词超出预算（模型 %s，上下文窗口 %d，预留回复 %d）：知识片段保留 %d/%d，历史消息保留 %d/%d，预计提示词 token: %d",
+		modelName, window, completion, len(keptChunks), len(chunks), len(keptHistory), len(history), fixed+knowledgeUsed+historyUsed)
+	return keptChunks, keptHistory
+}
+
+// fitChunks 按排名顺序保留知识片段，第一个放不下的片段在剩余空间足够时截断保留，其余丢弃
+func fitChunks(chunks []model.KnowledgeChunk, tokens []int, budget int) ([]model.KnowledgeChunk, int) {
+	used := 0
+	kept := make([]model.KnowledgeChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		if used+tokens[i] <= budget {
+			kept = append(kept, chunk)
+			used += tokens[i]
+			continue
+		}
+
+		dropFrom := i
+		// 扣除标题、来源等格式开销后截断正文
+		overhead := tokens[i] - tokenizer.Count(chunk.Content)
+		if contentBudget := budget - used - overhead - tokenizer.Count(truncatedSuffix); contentBudget >= minChunkTokens {
+			chunk.Content = tokenizer.Truncate(chunk.Content, contentBudget) + truncatedSuffix
+			truncated := overhead + tokenizer.Count(chunk.Content)
+			kept = append(kept, chunk)
+			used += truncated
+			dropFrom++
+			logger.Info("截断知识片段 [%d] %s（知识库: %s，分数: %.4f），约 %d → %d token", i+1, chunk.Title, chunk.KnowledgeBase, chunk.Score, tokens[i], truncated)
+		}
+		for j := dropFrom; j < len(chunks); j++ {
+			logger.Info("丢弃知识片段 [%d] %s（知识库: %s，分数: %.4f），约 %d token", j+1, chunks[j].Title, chunks[j].KnowledgeBase, chunks[j].Score, tokens[j])
+		}
+		break
+	}
+	return kept, used
+}
+
+// fitHistory 从最新的消息开始保留历史对话，保证历史以 user 消息开头
+func fitHistory(history []model.ChatMessage, tokens []int, budget int) ([]model.ChatMessage, int) {
+	used := 0
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		if used+tokens[i] > budget {
+			break
+		}
+		used += tokens[i]
+		start = i
+	}
+	for start < len(history) && history[start].Role != openai.ChatMessageRoleUser {
+		used -= tokens[start]
+		start++
+	}
+	if start > 0 {
+		logger.Info("丢弃最早的 %d 条历史消息，约 %d token", start, sumTokens(tokens[:start]))
+	}
+	return history[start:], used
+}
+
+// countMessageTokens 估算单条消息的 token 数（包含格式开销）
+func countMessageTokens(content string) int {
+	return tokenizer.Count(content) + messageTokenOverhead
+}
+
+// sumTokens 求和
+func sumTokens(tokens []int) int {
+	total := 0
+	for _, t := range tokens {
+		total += t
+	}
+	return total
+}
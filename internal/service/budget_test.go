@@ -0,0 +1,218 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/tokenizer"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestFitChunks(t *testing.T) {
+	const overhead = 10 // 标题、来源等格式开销
+	suffix := tokenizer.Count(truncatedSuffix)
+	chunk := func(n int) model.KnowledgeChunk {
+		return model.KnowledgeChunk{Content: strings.Repeat("字", n)}
+	}
+
+	tests := []struct {
+		name     string
+		sizes    []int // 各片段正文的 token 数
+		budget   int
+		wantKept int
+		wantLast int // 最后一个保留片段截断后的正文 token 数，0 表示未截断
+		wantUsed int
+	}{
+		{
+			name:     "全部放得下",
+			sizes:    []int{100, 100},
+			budget:   220,
+			wantKept: 2,
+			wantUsed: 220,
+		},
+		{
+			name:     "第一个放不下的片段截断保留，之后的丢弃",
+			sizes:    []int{100, 100, 100},
+			budget:   110 + 100,
+			wantKept: 2,
+			wantLast: 100 - overhead - suffix,
+			wantUsed: 110 + 100,
+		},
+		{
+			name:     "剩余空间不足以截断时丢弃",
+			sizes:    []int{100, 100},
+			budget:   110 + overhead + suffix + minChunkTokens - 1,
+			wantKept: 1,
+			wantUsed: 110,
+		},
+		{
+			name:     "排名靠后的小片段同样丢弃",
+			sizes:    []int{100, 300, 5},
+			budget:   150,
+			wantKept: 1,
+			wantUsed: 110,
+		},
+		{
+			name:     "预算为 0",
+			sizes:    []int{100},
+			budget:   0,
+			wantKept: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := make([]model.KnowledgeChunk, len(tt.sizes))
+			tokens := make([]int, len(tt.sizes))
+			for i, size := range tt.sizes {
+				chunks[i] = chunk(size)
+				tokens[i] = size + overhead
+			}
+
+			kept, used := fitChunks(chunks, tokens, tt.budget)
+			if len(kept) != tt.wantKept {
+				t.Fatalf("kept %d chunks, want %d", len(kept), tt.wantKept)
+			}
+			if used != tt.wantUsed {
+				t.Errorf("used = %d, want %d", used, tt.wantUsed)
+			}
+			if used > tt.budget {
+				t.Errorf("used %d exceeds budget %d", used, tt.budget)
+			}
+			if tt.wantLast > 0 {
+				last := kept[len(kept)-1].Content
+				if !strings.HasSuffix(last, truncatedSuffix) {
+					t.Errorf("truncated chunk %q has no suffix", last)
+				}
+				if got := tokenizer.Count(strings.TrimSuffix(last, truncatedSuffix)); got != tt.wantLast {
+					t.Errorf("truncated content = %d tokens, want %d", got, tt.wantLast)
+				}
+				// 不修改传入的片段
+				if chunks[len(kept)-1].Content != chunk(tt.sizes[len(kept)-1]).Content {
+					t.Error("input chunk modified")
+				}
+			}
+		})
+	}
+}
+
+func TestFitHistory(t *testing.T) {
+	user := func(content string) model.ChatMessage {
+		return model.ChatMessage{Role: openai.ChatMessageRoleUser, Content: content}
+	}
+	assistant := func(content string) model.ChatMessage {
+		return model.ChatMessage{Role: openai.ChatMessageRoleAssistant, Content: content}
+	}
+	history := []model.ChatMessage{user("u1"), assistant("a1"), user("u2"), assistant("a2")}
+	tokens := []int{10, 10, 10, 10}
+
+	tests := []struct {
+		name     string
+		history  []model.ChatMessage
+		budget   int
+		want     []string
+		wantUsed int
+	}{
+		{"全部放得下", history, 40, []string{"u1", "a1", "u2", "a2"}, 40},
+		{"从最早的消息开始丢弃", history, 25, []string{"u2", "a2"}, 20},
+		{"保留的历史以用户消息开头", history, 30, []string{"u2", "a2"}, 20},
+		{"放不下任何消息", history, 5, nil, 0},
+		{"开头的助手消息被丢弃", history[1:], 30, []string{"u2", "a2"}, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, used := fitHistory(tt.history, tokens[:len(tt.history)], tt.budget)
+			var got []string
+			for _, msg := range kept {
+				got = append(got, msg.Content)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("kept = %v, want %v", got, tt.want)
+			}
+			if used != tt.wantUsed {
+				t.Errorf("used = %d, want %d", used, tt.wantUsed)
+			}
+		})
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.AIConfig
+		model string
+		want  int
+	}{
+		{"内置窗口按最长前缀匹配", config.AIConfig{}, "gpt-4o-mini", 128000},
+		{"内置窗口较短的前缀", config.AIConfig{}, "gpt-4-0613", 8192},
+		{"忽略大小写", config.AIConfig{}, "DeepSeek-Chat", 65536},
+		{"context_windows 优先", config.AIConfig{ContextWindows: map[string]int{"gpt-4o": 32000}, ContextWindow: 16000}, "gpt-4o", 32000},
+		{"context_window 优先于内置窗口", config.AIConfig{ContextWindow: 16000}, "gpt-4o", 16000},
+		{"无法确定时使用默认值", config.AIConfig{}, "unknown-model", defaultContextWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextWindow(&tt.cfg, tt.model); got != tt.want {
+				t.Errorf("contextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptBudgetFit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AI.ContextWindow = 1000
+	cfg.RAG.KnowledgeBudgetRatio = 0.5
+	pb := newPromptBudget(cfg)
+
+	chunks := []model.KnowledgeChunk{{Content: strings.Repeat("字", 300)}, {Content: strings.Repeat("字", 300)}}
+	history := []model.ChatMessage{
+		{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("问", 200)},
+		{Role: openai.ChatMessageRoleAssistant, Content: strings.Repeat("答", 200)},
+	}
+
+	t.Run("预算足够时原样返回", func(t *testing.T) {
+		keptChunks, keptHistory := pb.Fit("unknown", 0, "系统", "问题", chunks[:1], history[:1])
+		if len(keptChunks) != 1 || len(keptHistory) != 1 {
+			t.Errorf("Fit() kept %d chunks and %d messages, want 1 and 1", len(keptChunks), len(keptHistory))
+		}
+	})
+
+	t.Run("超出预算时裁剪", func(t *testing.T) {
+		keptChunks, keptHistory := pb.Fit("unknown", 200, "系统", "问题", chunks, history)
+		if len(keptChunks) == 0 || len(keptChunks) == len(chunks) && len(keptHistory) == len(history) {
+			t.Fatalf("Fit() kept %d chunks and %d messages", len(keptChunks), len(keptHistory))
+		}
+		total := 0
+		for _, chunk := range keptChunks {
+			total += tokenizer.Count(formatKnowledgeContext([]model.KnowledgeChunk{chunk})) + 2
+		}
+		for _, msg := range keptHistory {
+			total += countMessageTokens(msg.Content)
+		}
+		fixed := countMessageTokens("系统") + countMessageTokens("问题") + replyTokenOverhead
+		if total+fixed+200 > 1000 {
+			t.Errorf("prompt %d tokens + 200 reserved exceeds window 1000", total+fixed)
+		}
+	})
+
+	t.Run("回复预留空间已占满窗口", func(t *testing.T) {
+		keptChunks, keptHistory := pb.Fit("unknown", 1000, "系统", "问题", chunks, history)
+		if keptChunks != nil || keptHistory != nil {
+			t.Errorf("Fit() = %d chunks, %d messages, want none", len(keptChunks), len(keptHistory))
+		}
+	})
+
+	t.Run("不限制回复长度时不预留空间", func(t *testing.T) {
+		// 窗口只够放下一个片段，负数不能被当作额外空间
+		long := []model.KnowledgeChunk{{Content: strings.Repeat("字", 600)}, {Content: strings.Repeat("字", 600)}}
+		keptChunks, _ := pb.Fit("unknown", -1000, "系统", "问题", long, nil)
+		if len(keptChunks) != 2 || !strings.HasSuffix(keptChunks[1].Content, truncatedSuffix) {
+			t.Errorf("Fit() kept %d chunks, want the second one truncated", len(keptChunks))
+		}
+	})
+}
@@ -2,10 +2,13 @@ package captcha
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/resilience"
 
 	captcha20230305 "github.com/alibabacloud-go/captcha-20230305/client"
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
@@ -45,6 +48,9 @@ func NewAliyunCaptchaProvider(cfg *config.CaptchaConfig) (*AliyunCaptchaProvider
 		openApiConfig.Endpoint = tea.String("captcha.cn-shanghai.aliyuncs.com")
 	}
 
+	// 请求经过重试与熔断
+	openApiConfig.HttpClient = aliyunHTTPClient{}
+
 	// 创建客户端
 	client, err := captcha20230305.NewClient(openApiConfig)
 	if err != nil {
@@ -57,6 +63,18 @@ func NewAliyunCaptchaProvider(cfg *config.CaptchaConfig) (*AliyunCaptchaProvider
 	}, nil
 }
 
+// aliyunHTTPClient 阿里云 SDK 的 HTTP 客户端，为请求加上验证码上游的重试与熔断
+type aliyunHTTPClient struct{}
+
+// Call 使用 SDK 配置的 transport 发送请求
+func (aliyunHTTPClient) Call(request *http.Request, transport *http.Transport) (*http.Response, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if transport != nil {
+		client.Transport = transport
+	}
+	return resilience.Client(upstreamName, client).Do(request)
+}
+
 // Verify 验证阿里云验证码
 func (p *AliyunCaptchaProvider) Verify(params map[string]string) (bool, error) {
 	captchaParam := params["captcha_param"]
@@ -8,6 +8,9 @@ import (
 	"knowledge-maker/internal/config"
 )
 
+// upstreamName 验证码服务在重试与熔断配置中的上游名称，各类验证码共用
+const upstreamName = "captcha"
+
 // CaptchaService 验证码服务
 type CaptchaService struct {
 	provider CaptchaProvider
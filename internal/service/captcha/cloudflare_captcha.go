@@ -2,6 +2,7 @@ package captcha
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/resilience"
 )
 
 // CloudflareCaptchaProvider Cloudflare Turnstile 验证码提供者
@@ -31,9 +33,9 @@ func NewCloudflareCaptchaProvider(cfg *config.CaptchaConfig) (*CloudflareCaptcha
 		secretKey: cfg.CloudflareSecretKey,
 		verifyURL: cfg.CloudflareURL,
 		enabled:   true,
-		client: &http.Client{
+		client: resilience.Client(upstreamName, &http.Client{
 			Timeout: 10 * time.Second,
-		},
+		}),
 	}, nil
 }
 
@@ -89,7 +91,7 @@ func (p *CloudflareCaptchaProvider) Verify(params map[string]string) (bool, erro
 		if len(result.ErrorCodes) > 0 {
 			errorMsg += fmt.Sprintf(": %v", result.ErrorCodes)
 		}
-		return false, fmt.Errorf(errorMsg)
+		return false, errors.New(errorMsg)
 	}
 
 	return true, nil
@@ -14,6 +14,7 @@ import (
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/resilience"
 )
 
 // GeetestCaptchaProvider 极验验证码提供者
@@ -58,9 +59,9 @@ func (p *GeetestCaptchaProvider) Verify(params map[string]string) (bool, error)
 	requestURL := fmt.Sprintf("%s?captcha_id=%s", p.config.GeetestURL, p.config.GeetestID)
 
 	// 创建HTTP客户端，设置5秒超时
-	client := &http.Client{
+	client := resilience.Client(upstreamName, &http.Client{
 		Timeout: 5 * time.Second,
-	}
+	})
 
 	// 发起POST请求
 	resp, err := client.PostForm(requestURL, formData)
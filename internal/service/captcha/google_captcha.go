@@ -11,6 +11,7 @@ import (
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/resilience"
 )
 
 // GoogleCaptchaProvider Google reCAPTCHA 提供者
@@ -60,9 +61,9 @@ func (p *GoogleCaptchaProvider) Verify(params map[string]string) (bool, error) {
 	verifyURL := p.config.GoogleRecaptchaURL
 
 	// 创建HTTP客户端，设置10秒超时
-	client := &http.Client{
+	client := resilience.Client(upstreamName, &http.Client{
 		Timeout: 10 * time.Second,
-	}
+	})
 
 	// 发起POST请求
 	resp, err := client.PostForm(verifyURL, formData)
@@ -6,6 +6,7 @@ import (
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/resilience"
 
 	captcha "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/captcha/v20190722"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
@@ -37,6 +38,7 @@ func NewTencentCaptchaProvider(cfg *config.CaptchaConfig) (*TencentCaptchaProvid
 	if err != nil {
 		return nil, fmt.Errorf("创建腾讯云验证码客户端失败: %v", err)
 	}
+	client.WithHttpTransport(resilience.Get(upstreamName).Transport(nil))
 
 	return &TencentCaptchaProvider{
 		client: client,
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"knowledge-maker/internal/database"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrConversationNotFound 会话不存在（或不属于当前客户端）
+var ErrConversationNotFound = errors.New("会话不存在")
+
+// ErrClientIDRequired 会话按客户端标识隔离，没有客户端标识时不能创建或访问会话
+var ErrClientIDRequired = errors.New("缺少客户端标识（请求头 X-Client-Id）")
+
+// conversationTitleLength 自动生成会话标题时截取的最大字符数
+const conversationTitleLength = 30
+
+// conversationSchema 会话相关表结构（SQLite 与 PostgreSQL 通用）
+var conversationSchema = []string{
+	`CREATE TABLE IF NOT EXISTS conversations (
+		id VARCHAR(64) PRIMARY KEY,
+		client_id VARCHAR(128) NOT NULL DEFAULT '',
+		title VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_conversations_client ON conversations (client_id, updated_at)`,
+	`CREATE TABLE IF NOT EXISTS conversation_messages (
+		conversation_id VARCHAR(64) NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+		seq INTEGER NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		content TEXT NOT NULL,
+		knowledge_context TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (conversation_id, seq)
+	)`,
+}
+
+// ConversationService 会话服务，负责会话及消息的持久化
+type ConversationService struct {
+	db *database.DB
+}
+
+// NewConversationService 创建会话服务实例，并初始化表结构
+func NewConversationService(db *database.DB) (*ConversationService, error) {
+	for _, stmt := range conversationSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("初始化会话表结构失败: %v", err)
+		}
+	}
+
+	return &ConversationService{db: db}, nil
+}
+
+// CreateConversation 创建会话
+func (cs *ConversationService) CreateConversation(clientID, title string) (*model.Conversation, error) {
+	if clientID == "" {
+		return nil, ErrClientIDRequired
+	}
+
+	now := time.Now()
+	conv := &model.Conversation{
+		ID:        newConversationID(),
+		Title:     truncateRunes(title, conversationTitleLength),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err := cs.db.Exec(cs.db.Rebind(
+		`INSERT INTO conversations (id, client_id, title, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`),
+		conv.ID, clientID, conv.Title, conv.CreatedAt, conv.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("创建会话失败: %v", err)
+	}
+
+	logger.Info("会话已创建: %s", conv.ID)
+	return conv, nil
+}
+
+// ListConversations 按最近更新时间列出客户端的会话
+func (cs *ConversationService) ListConversations(clientID string, limit, offset int) ([]model.Conversation, error) {
+	if clientID == "" {
+		return nil, ErrClientIDRequired
+	}
+
+	rows, err := cs.db.Query(cs.db.Rebind(
+		`SELECT c.id, c.title, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM conversation_messages m WHERE m.conversation_id = c.id)
+		FROM conversations c
+		WHERE c.client_id = ?
+		ORDER BY c.updated_at DESC
+		LIMIT ? OFFSET ?`),
+		clientID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	conversations := []model.Conversation{}
+	for rows.Next() {
+		var conv model.Conversation
+		if err := rows.Scan(&conv.ID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt, &conv.MessageCount); err != nil {
+			return nil, fmt.Errorf("读取会话失败: %v", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	return conversations, rows.Err()
+}
+
+// GetConversation 获取会话详情（包含全部消息）
+func (cs *ConversationService) GetConversation(clientID, id string) (*model.ConversationDetail, error) {
+	conv, err := cs.getConversation(clientID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := cs.db.Query(cs.db.Rebind(
+		`SELECT seq, role, content, knowledge_context, created_at
+		FROM conversation_messages
+		WHERE conversation_id = ?
+		ORDER BY seq`),
+		id)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话消息失败: %v", err)
+	}
+	defer rows.Close()
+
+	detail := &model.ConversationDetail{
+		Conversation: *conv,
+		Messages:     []model.ConversationMessage{},
+	}
+	for rows.Next() {
+		var msg model.ConversationMessage
+		if err := rows.Scan(&msg.Seq, &msg.Role, &msg.Content, &msg.KnowledgeContext, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取会话消息失败: %v", err)
+		}
+		detail.Messages = append(detail.Messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取会话消息失败: %v", err)
+	}
+
+	detail.MessageCount = len(detail.Messages)
+	return detail, nil
+}
+
+// DeleteConversation 删除会话及其消息
+func (cs *ConversationService) DeleteConversation(clientID, id string) error {
+	if _, err := cs.getConversation(clientID, id); err != nil {
+		return err
+	}
+
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("删除会话失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(cs.db.Rebind(`DELETE FROM conversation_messages WHERE conversation_id = ?`), id); err != nil {
+		return fmt.Errorf("删除会话消息失败: %v", err)
+	}
+	if _, err := tx.Exec(cs.db.Rebind(`DELETE FROM conversations WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("删除会话失败: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("删除会话失败: %v", err)
+	}
+
+	logger.Info("会话已删除: %s", id)
+	return nil
+}
+
+// GetHistory 获取会话历史，转换为聊天消息格式
+func (cs *ConversationService) GetHistory(clientID, id string) ([]model.ChatMessage, error) {
+	detail, err := cs.GetConversation(clientID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]model.ChatMessage, 0, len(detail.Messages))
+	for _, msg := range detail.Messages {
+		history = append(history, model.ChatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+	return history, nil
+}
+
+// AppendTurn 追加一轮问答（用户问题 + AI 回答及其知识库上下文）
+func (cs *ConversationService) AppendTurn(id, query, answer, knowledgeContext string) error {
+	tx, err := cs.db.Begin()
+	if err != nil {
+		return fmt.Errorf("保存会话消息失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		title  string
+		maxSeq int
+	)
+	err = tx.QueryRow(cs.db.Rebind(
+		`SELECT c.title, COALESCE((SELECT MAX(seq) FROM conversation_messages m WHERE m.conversation_id = c.id), 0)
+		FROM conversations c WHERE c.id = ?`), id).Scan(&title, &maxSeq)
+	if err == sql.ErrNoRows {
+		return ErrConversationNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("查询会话失败: %v", err)
+	}
+
+	now := time.Now()
+	insert := cs.db.Rebind(`INSERT INTO conversation_messages (conversation_id, seq, role, content, knowledge_context, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if _, err := tx.Exec(insert, id, maxSeq+1, openai.ChatMessageRoleUser, query, "", now); err != nil {
+		return fmt.Errorf("保存用户消息失败: %v", err)
+	}
+	if _, err := tx.Exec(insert, id, maxSeq+2, openai.ChatMessageRoleAssistant, answer, knowledgeContext, now); err != nil {
+		return fmt.Errorf("保存回答消息失败: %v", err)
+	}
+
+	// 未设置标题的会话使用第一个问题作为标题
+	if title == "" {
+		title = truncateRunes(query, conversationTitleLength)
+	}
+	if _, err := tx.Exec(cs.db.Rebind(`UPDATE conversations SET title = ?, updated_at = ? WHERE id = ?`), title, now, id); err != nil {
+		return fmt.Errorf("更新会话失败: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("保存会话消息失败: %v", err)
+	}
+
+	logger.Info("会话 %s 已追加一轮问答，当前消息数: %d", id, maxSeq+2)
+	return nil
+}
+
+// getConversation 查询会话基本信息，并校验归属
+func (cs *ConversationService) getConversation(clientID, id string) (*model.Conversation, error) {
+	if clientID == "" {
+		return nil, ErrClientIDRequired
+	}
+
+	var (
+		conv  model.Conversation
+		owner string
+	)
+	err := cs.db.QueryRow(cs.db.Rebind(
+		`SELECT id, client_id, title, created_at, updated_at FROM conversations WHERE id = ?`), id).
+		Scan(&conv.ID, &owner, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询会话失败: %v", err)
+	}
+
+	// 属于其他客户端的会话视为不存在，避免泄露；没有归属的旧会话不允许任何客户端访问
+	if owner != clientID {
+		return nil, ErrConversationNotFound
+	}
+
+	return &conv, nil
+}
+
+// newConversationID 生成随机会话 ID
+func newConversationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// truncateRunes 按字符数截断字符串
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
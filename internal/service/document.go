@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"knowledge-maker/internal/chunker"
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/database"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service/retriever"
+)
+
+var (
+	// ErrDocumentNotFound 文档不存在
+	ErrDocumentNotFound = errors.New("文档不存在")
+	// ErrInvalidDocument 文档参数不合法
+	ErrInvalidDocument = errors.New("文档参数错误")
+)
+
+// documentIDPattern 文档 ID 允许的字符
+var documentIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// documentSchema 文档表结构（SQLite 与 PostgreSQL 通用）
+var documentSchema = []string{
+	`CREATE TABLE IF NOT EXISTS documents (
+		id VARCHAR(160) PRIMARY KEY,
+		knowledge_base VARCHAR(64) NOT NULL,
+		title VARCHAR(512) NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT '',
+		content_type VARCHAR(16) NOT NULL,
+		content_hash VARCHAR(64) NOT NULL,
+		chunk_count INTEGER NOT NULL DEFAULT 0,
+		content TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_documents_knowledge_base ON documents (knowledge_base, updated_at)`,
+}
+
+// DocumentService 文档服务，负责文档的切分、向量化写入知识库及元数据管理
+type DocumentService struct {
+	db               *database.DB
+	knowledgeService *KnowledgeService
+	config           *config.Config
+	mu               sync.Mutex
+}
+
+// NewDocumentService 创建文档服务实例，并初始化表结构
+func NewDocumentService(db *database.DB, knowledgeService *KnowledgeService, cfg *config.Config) (*DocumentService, error) {
+	for _, stmt := range documentSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("初始化文档表结构失败: %v", err)
+		}
+	}
+
+	return &DocumentService{
+		db:               db,
+		knowledgeService: knowledgeService,
+		config:           cfg,
+	}, nil
+}
+
+// IngestDocument 导入文档：内容未变化时不做任何处理，否则重新切分并写入知识库；
+// ctx 取消后不再写入，已写入知识库的内容保持导入前的版本
+func (ds *DocumentService) IngestDocument(ctx context.Context, req model.IngestDocumentRequest) (*model.Document, string, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, "", fmt.Errorf("%w: 文档内容不能为空", ErrInvalidDocument)
+	}
+
+	contentType, err := normalizeContentType(req.ContentType, req.Source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kbName, indexer, err := ds.knowledgeService.Indexer(req.KnowledgeBase)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := documentID(req, kbName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	// 等待其他导入期间请求可能已取消
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// 标题、链接和类型会写入知识片段，一并计入哈希
+	hash := contentHash(strings.Join([]string{contentType, req.Title, req.URL, req.Content}, "\x00"))
+	existing, err := ds.getDocument(id)
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, "", err
+	}
+	if existing != nil {
+		if existing.KnowledgeBase != kbName {
+			return nil, "", fmt.Errorf("%w: 文档 ID %s 已被知识库 %s 使用", ErrInvalidDocument, id, existing.KnowledgeBase)
+		}
+		if existing.ContentHash == hash {
+			logger.Info("文档 %s 内容未变化，跳过导入", id)
+			return &existing.Document, model.DocumentStatusUnchanged, nil
+		}
+	}
+
+	// 提取正文并切分
+	title, chunks, err := ds.buildChunks(id, contentType, req.Content, req.Title, req.URL, req.Source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 先写入知识库再保存文档信息：中途中断时文档信息仍是旧的内容哈希，重新导入即可恢复；
+	// 保存失败时撤销本次写入
+	if err := indexer.IndexDocument(ctx, id, chunks); err != nil {
+		return nil, "", fmt.Errorf("写入知识库 %s 失败: %v", kbName, err)
+	}
+
+	now := time.Now()
+	doc := &model.Document{
+		ID:            id,
+		KnowledgeBase: kbName,
+		Title:         title,
+		URL:           req.URL,
+		Source:        req.Source,
+		ContentType:   contentType,
+		ContentHash:   hash,
+		ChunkCount:    len(chunks),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	status := model.DocumentStatusCreated
+	if existing != nil {
+		status = model.DocumentStatusUpdated
+		doc.CreatedAt = existing.CreatedAt
+		_, err = ds.db.Exec(ds.db.Rebind(
+			`UPDATE documents SET title = ?, url = ?, source = ?, content_type = ?, content_hash = ?, chunk_count = ?, content = ?, updated_at = ? WHERE id = ?`),
+			doc.Title, doc.URL, doc.Source, doc.ContentType, doc.ContentHash, doc.ChunkCount, req.Content, doc.UpdatedAt, doc.ID)
+	} else {
+		_, err = ds.db.Exec(ds.db.Rebind(
+			`INSERT INTO documents (id, knowledge_base, title, url, source, content_type, content_hash, chunk_count, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			doc.ID, doc.KnowledgeBase, doc.Title, doc.URL, doc.Source, doc.ContentType, doc.ContentHash, doc.ChunkCount, req.Content, doc.CreatedAt, doc.UpdatedAt)
+	}
+	if err != nil {
+		ds.restoreIndex(ctx, indexer, id, existing)
+		return nil, "", fmt.Errorf("保存文档信息失败: %v", err)
+	}
+
+	logger.Info("文档 %s 导入完成，知识库: %s，状态: %s，片段数: %d", id, kbName, status, len(chunks))
+	return doc, status, nil
+}
+
+// ListDocuments 列出文档，knowledgeBase 为空时列出全部知识库的文档
+func (ds *DocumentService) ListDocuments(knowledgeBase string, limit, offset int) ([]model.Document, error) {
+	query := `SELECT id, knowledge_base, title, url, source, content_type, content_hash, chunk_count, created_at, updated_at FROM documents`
+	var args []interface{}
+	if knowledgeBase != "" {
+		query += ` WHERE knowledge_base = ?`
+		args = append(args, knowledgeBase)
+	}
+	query += ` ORDER BY updated_at DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := ds.db.Query(ds.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	documents := []model.Document{}
+	for rows.Next() {
+		var doc model.Document
+		if err := rows.Scan(&doc.ID, &doc.KnowledgeBase, &doc.Title, &doc.URL, &doc.Source, &doc.ContentType,
+			&doc.ContentHash, &doc.ChunkCount, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("读取文档失败: %v", err)
+		}
+		documents = append(documents, doc)
+	}
+
+	return documents, rows.Err()
+}
+
+// GetDocument 获取文档详情
+func (ds *DocumentService) GetDocument(id string) (*model.DocumentDetail, error) {
+	return ds.getDocument(id)
+}
+
+// DeleteDocument 从知识库和文档表中删除文档
+func (ds *DocumentService) DeleteDocument(ctx context.Context, id string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	doc, err := ds.getDocument(id)
+	if err != nil {
+		return err
+	}
+
+	_, indexer, err := ds.knowledgeService.Indexer(doc.KnowledgeBase)
+	if err != nil {
+		return err
+	}
+	if err := indexer.DeleteDocument(ctx, id); err != nil {
+		return fmt.Errorf("从知识库 %s 删除文档失败: %v", doc.KnowledgeBase, err)
+	}
+
+	if _, err := ds.db.Exec(ds.db.Rebind(`DELETE FROM documents WHERE id = ?`), id); err != nil {
+		ds.restoreIndex(ctx, indexer, id, doc)
+		return fmt.Errorf("删除文档信息失败: %v", err)
+	}
+
+	logger.Info("文档 %s 已从知识库 %s 删除", id, doc.KnowledgeBase)
+	return nil
+}
+
+// buildChunks 提取文档正文并切分为知识片段，返回文档标题；title 为空时使用正文中的标题或来源文件名
+func (ds *DocumentService) buildChunks(id, contentType, content, title, url, source string) (string, []model.KnowledgeChunk, error) {
+	extracted, text, err := extractDocumentText(contentType, content)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if title == "" {
+		title = extracted
+	}
+	if title == "" && source != "" {
+		title = strings.TrimSuffix(path.Base(source), path.Ext(source))
+	}
+
+	pieces := splitDocument(contentType, text, ds.config.Knowledge.Chunk.Size, ds.config.Knowledge.Chunk.Overlap)
+	if len(pieces) == 0 {
+		return "", nil, fmt.Errorf("%w: 文档没有可导入的正文内容", ErrInvalidDocument)
+	}
+
+	chunks := make([]model.KnowledgeChunk, len(pieces))
+	for i, piece := range pieces {
+		metadata := map[string]interface{}{
+			"document_id": id,
+			"chunk_index": i,
+		}
+		if piece.Section != "" {
+			metadata["section"] = piece.Section
+		}
+		chunks[i] = model.KnowledgeChunk{
+			Content:  piece.Content,
+			Title:    title,
+			URL:      url,
+			Source:   source,
+			Metadata: metadata,
+		}
+	}
+	return title, chunks, nil
+}
+
+// restoreIndex 保存文档信息失败后撤销知识库中的变更：恢复为数据库中记录的版本，新文档则删除已写入的片段；
+// 撤销不随请求取消，避免知识库与文档表不一致
+func (ds *DocumentService) restoreIndex(ctx context.Context, indexer retriever.Indexer, id string, previous *model.DocumentDetail) {
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if previous == nil {
+		err = indexer.DeleteDocument(ctx, id)
+	} else {
+		var chunks []model.KnowledgeChunk
+		if _, chunks, err = ds.buildChunks(id, previous.ContentType, previous.Content, previous.Title, previous.URL, previous.Source); err == nil {
+			err = indexer.IndexDocument(ctx, id, chunks)
+		}
+	}
+	if err != nil {
+		logger.Error("文档 %s 撤销知识库变更失败，重新导入该文档可以恢复: %v", id, err)
+	}
+}
+
+// getDocument 查询文档
+func (ds *DocumentService) getDocument(id string) (*model.DocumentDetail, error) {
+	var doc model.DocumentDetail
+	err := ds.db.QueryRow(ds.db.Rebind(
+		`SELECT id, knowledge_base, title, url, source, content_type, content_hash, chunk_count, content, created_at, updated_at FROM documents WHERE id = ?`), id).
+		Scan(&doc.ID, &doc.KnowledgeBase, &doc.Title, &doc.URL, &doc.Source, &doc.ContentType,
+			&doc.ContentHash, &doc.ChunkCount, &doc.Content, &doc.CreatedAt, &doc.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询文档失败: %v", err)
+	}
+	return &doc, nil
+}
+
+// normalizeContentType 规范化文档类型，未指定时根据来源扩展名判断
+func normalizeContentType(contentType, source string) (string, error) {
+	if contentType == "" {
+		contentType = strings.TrimPrefix(strings.ToLower(path.Ext(source)), ".")
+	}
+
+	switch strings.ToLower(contentType) {
+	case "markdown", "md", "text/markdown":
+		return "markdown", nil
+	case "text", "txt", "text/plain", "":
+		return "text", nil
+	case "html", "htm", "text/html":
+		return "html", nil
+	default:
+		return "", fmt.Errorf("%w: 不支持的文档类型 %s（支持 markdown、text、html）", ErrInvalidDocument, contentType)
+	}
+}
+
+// extractDocumentText 提取文档标题和正文
+func extractDocumentText(contentType, content string) (string, string, error) {
+	switch contentType {
+	case "html":
+		return chunker.HTMLToText(content)
+	case "markdown":
+		// 使用第一个一级标题作为文档标题
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "# ")), content, nil
+			}
+		}
+		return "", content, nil
+	default:
+		return "", content, nil
+	}
+}
+
+// splitDocument 切分文档正文：Markdown 和 HTML（已转换为 Markdown 结构）按标题层级切分，纯文本按段落切分
+func splitDocument(contentType, text string, size, overlap int) []chunker.Chunk {
+	if contentType == "markdown" || contentType == "html" {
+		return chunker.SplitMarkdown(text, size, overlap)
+	}
+
+	var pieces []chunker.Chunk
+	for _, content := range chunker.SplitText(text, size, overlap) {
+		pieces = append(pieces, chunker.Chunk{Content: content})
+	}
+	return pieces
+}
+
+// documentID 确定文档 ID：优先使用请求指定的 ID，其次根据知识库和来源生成，最后根据内容生成
+func documentID(req model.IngestDocumentRequest, knowledgeBase string) (string, error) {
+	if req.ID != "" {
+		if !documentIDPattern.MatchString(req.ID) {
+			return "", fmt.Errorf("%w: 文档 ID 只能包含字母、数字、点、下划线和短横线，且不超过 128 个字符", ErrInvalidDocument)
+		}
+		return req.ID, nil
+	}
+
+	key := req.Source
+	if key == "" {
+		key = req.Content
+	}
+	return "doc-" + contentHash(knowledgeBase + "\x00" + key)[:24], nil
+}
+
+// contentHash 计算内容哈希
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
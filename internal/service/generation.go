@@ -0,0 +1,171 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrInvalidGeneration 请求的生成参数不允许覆盖或超出允许范围
+var ErrInvalidGeneration = errors.New("生成参数无效")
+
+// resolveGeneration 确定生成参数：默认参数 < 模型配置 < 请求覆盖（需在 request_limits 范围内），profile 为 nil 时不使用模型配置
+func resolveGeneration(cfg *config.AIConfig, profile *config.ModelProfile, overrides *model.GenerationParams) (config.GenerationConfig, error) {
+	params := cfg.GenerationConfig
+	if profile != nil {
+		params = mergeGeneration(params, profile.GenerationConfig)
+	}
+	if overrides.IsZero() {
+		return params, nil
+	}
+
+	limits := cfg.RequestLimits
+	if overrides.MaxTokens != nil {
+		if err := checkParamRange("max_tokens", *overrides.MaxTokens, limits.MaxTokens); err != nil {
+			return params, err
+		}
+		params.MaxTokens = *overrides.MaxTokens
+	}
+	if overrides.Temperature != nil {
+		if err := checkParamRange("temperature", *overrides.Temperature, limits.Temperature); err != nil {
+			return params, err
+		}
+		params.Temperature = overrides.Temperature
+	}
+	if overrides.TopP != nil {
+		if err := checkParamRange("top_p", *overrides.TopP, limits.TopP); err != nil {
+			return params, err
+		}
+		params.TopP = overrides.TopP
+	}
+	if overrides.PresencePenalty != nil {
+		if err := checkParamRange("presence_penalty", *overrides.PresencePenalty, limits.PresencePenalty); err != nil {
+			return params, err
+		}
+		params.PresencePenalty = overrides.PresencePenalty
+	}
+	if overrides.FrequencyPenalty != nil {
+		if err := checkParamRange("frequency_penalty", *overrides.FrequencyPenalty, limits.FrequencyPenalty); err != nil {
+			return params, err
+		}
+		params.FrequencyPenalty = overrides.FrequencyPenalty
+	}
+	if len(overrides.Stop) > 0 {
+		if len(overrides.Stop) > limits.MaxStop {
+			if limits.MaxStop == 0 {
+				return params, fmt.Errorf("%w: 不允许指定 stop", ErrInvalidGeneration)
+			}
+			return params, fmt.Errorf("%w: stop 最多 %d 个", ErrInvalidGeneration, limits.MaxStop)
+		}
+		params.Stop = overrides.Stop
+	}
+	if overrides.Seed != nil {
+		if !limits.Seed {
+			return params, fmt.Errorf("%w: 不允许指定 seed", ErrInvalidGeneration)
+		}
+		params.Seed = overrides.Seed
+	}
+
+	return params, nil
+}
+
+// findModelProfile 查找模型对应的配置，配置名称或 model 与模型名称相同即匹配，返回配置名称
+func findModelProfile(profiles map[string]config.ModelProfile, modelName string) (string, bool) {
+	if profile, ok := profiles[modelName]; ok && (profile.Model == "" || profile.Model == modelName) {
+		return modelName, true
+	}
+	// 按名称排序，多个配置指向同一模型时结果稳定
+	for _, name := range profileNames(profiles) {
+		if profiles[name].Model == modelName {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// profileNames 按名称排序的模型配置名称
+func profileNames(profiles map[string]config.ModelProfile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// mergeGeneration 用 override 中已设置的参数覆盖 base
+func mergeGeneration(base, override config.GenerationConfig) config.GenerationConfig {
+	if override.MaxTokens != 0 {
+		base.MaxTokens = override.MaxTokens
+	}
+	if override.Temperature != nil {
+		base.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		base.TopP = override.TopP
+	}
+	if len(override.Stop) > 0 {
+		base.Stop = override.Stop
+	}
+	if override.PresencePenalty != nil {
+		base.PresencePenalty = override.PresencePenalty
+	}
+	if override.FrequencyPenalty != nil {
+		base.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.Seed != nil {
+		base.Seed = override.Seed
+	}
+	return base
+}
+
+// checkParamRange 检查请求参数是否在允许范围内，limit 为 nil 表示不允许覆盖。
+// 范围按参数自身的类型比较，否则 float32 的 1.2 转换为 float64 后会略大于配置的上限 1.2
+func checkParamRange[T int | float32](name string, value T, limit *config.ParamRange) error {
+	if limit == nil {
+		return fmt.Errorf("%w: 不允许指定 %s", ErrInvalidGeneration, name)
+	}
+	if value < T(limit.Min) || value > T(limit.Max) {
+		return fmt.Errorf("%w: %s 超出允许范围 [%g, %g]", ErrInvalidGeneration, name, limit.Min, limit.Max)
+	}
+	return nil
+}
+
+// applyGeneration 将生成参数写入聊天请求
+func applyGeneration(req *openai.ChatCompletionRequest, params config.GenerationConfig) {
+	// 负数表示不限制，不发送 max_tokens
+	req.MaxTokens = max(params.MaxTokens, 0)
+	req.Stop = params.Stop
+	req.Seed = params.Seed
+	if params.Temperature != nil {
+		req.Temperature = nonZero(*params.Temperature)
+	}
+	if params.TopP != nil {
+		req.TopP = nonZero(*params.TopP)
+	}
+	if params.PresencePenalty != nil {
+		req.PresencePenalty = *params.PresencePenalty
+	}
+	if params.FrequencyPenalty != nil {
+		req.FrequencyPenalty = *params.FrequencyPenalty
+	}
+}
+
+// zeroSampling 代替 0 发送的 temperature / top_p。go-openai 的请求字段带 omitempty，
+// 值为 0 时不会出现在请求中，模型服务会改用自己的默认值（通常为 1）。
+// 这里使用足够小的正规数：效果等同于贪心解码，又不会像非规格化数那样被序列化成
+// 1e-45 之类的值后被部分服务拒绝或下溢为 0
+const zeroSampling float32 = 1e-6
+
+// nonZero 将为 0 的 temperature / top_p 替换为 zeroSampling，确保参数被发送
+func nonZero(v float32) float32 {
+	if v == 0 {
+		return zeroSampling
+	}
+	return v
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestResolveGeneration(t *testing.T) {
+	cfg := &config.AIConfig{
+		GenerationConfig: config.GenerationConfig{MaxTokens: 2000, Temperature: ptr[float32](0.7)},
+		RequestLimits: config.GenerationLimits{
+			MaxTokens:   &config.ParamRange{Min: 1, Max: 4000},
+			Temperature: &config.ParamRange{Min: 0, Max: 1.2},
+			MaxStop:     2,
+		},
+	}
+	reasoning := &config.ModelProfile{GenerationConfig: config.GenerationConfig{MaxTokens: 8000, TopP: ptr[float32](0.9)}}
+
+	tests := []struct {
+		name      string
+		profile   *config.ModelProfile
+		overrides *model.GenerationParams
+		want      config.GenerationConfig
+		wantErr   bool
+	}{
+		{
+			name: "默认参数",
+			want: config.GenerationConfig{MaxTokens: 2000, Temperature: ptr[float32](0.7)},
+		},
+		{
+			name:    "模型配置覆盖默认参数",
+			profile: reasoning,
+			want:    config.GenerationConfig{MaxTokens: 8000, Temperature: ptr[float32](0.7), TopP: ptr[float32](0.9)},
+		},
+		{
+			name:      "请求覆盖模型配置",
+			profile:   reasoning,
+			overrides: &model.GenerationParams{MaxTokens: ptr(3000), Temperature: ptr[float32](0), Stop: []string{"END"}},
+			want:      config.GenerationConfig{MaxTokens: 3000, Temperature: ptr[float32](0), TopP: ptr[float32](0.9), Stop: []string{"END"}},
+		},
+		{
+			name:      "允许取边界值",
+			overrides: &model.GenerationParams{Temperature: ptr[float32](1.2)},
+			want:      config.GenerationConfig{MaxTokens: 2000, Temperature: ptr[float32](1.2)},
+		},
+		{
+			name:      "空的覆盖参数",
+			overrides: &model.GenerationParams{},
+			want:      config.GenerationConfig{MaxTokens: 2000, Temperature: ptr[float32](0.7)},
+		},
+		{
+			name:      "超出范围",
+			overrides: &model.GenerationParams{Temperature: ptr[float32](1.5)},
+			wantErr:   true,
+		},
+		{
+			name:      "负数的 max_tokens 超出范围",
+			overrides: &model.GenerationParams{MaxTokens: ptr(-1)},
+			wantErr:   true,
+		},
+		{
+			name:      "未配置范围的参数不允许覆盖",
+			overrides: &model.GenerationParams{TopP: ptr[float32](0.5)},
+			wantErr:   true,
+		},
+		{
+			name:      "stop 数量超出限制",
+			overrides: &model.GenerationParams{Stop: []string{"a", "b", "c"}},
+			wantErr:   true,
+		},
+		{
+			name:      "不允许指定 seed",
+			overrides: &model.GenerationParams{Seed: ptr(42)},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveGeneration(cfg, tt.profile, tt.overrides)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidGeneration) {
+					t.Fatalf("resolveGeneration() error = %v, want %v", err, ErrInvalidGeneration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveGeneration() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveGenerationDoesNotModifyConfig(t *testing.T) {
+	cfg := &config.AIConfig{
+		GenerationConfig: config.GenerationConfig{Temperature: ptr[float32](0.7)},
+		RequestLimits:    config.GenerationLimits{Temperature: &config.ParamRange{Min: 0, Max: 1}},
+	}
+	if _, err := resolveGeneration(cfg, nil, &model.GenerationParams{Temperature: ptr[float32](0.2)}); err != nil {
+		t.Fatal(err)
+	}
+	if *cfg.Temperature != 0.7 {
+		t.Errorf("config temperature = %v, want 0.7", *cfg.Temperature)
+	}
+}
+
+func TestFindModelProfile(t *testing.T) {
+	profiles := map[string]config.ModelProfile{
+		"fast":          {Model: "gpt-4o-mini"},
+		"mini":          {Model: "gpt-4o-mini"},
+		"deepseek-chat": {},
+		"reasoner":      {Model: "deepseek-reasoner"},
+		"gpt-4o":        {Model: "gpt-4.1"},
+	}
+	tests := []struct {
+		model string
+		want  string
+		found bool
+	}{
+		{"deepseek-chat", "deepseek-chat", true},
+		{"deepseek-reasoner", "reasoner", true},
+		{"gpt-4o-mini", "fast", true}, // 多个配置指向同一模型时取名称最小的
+		{"gpt-4o", "", false},         // 配置名称相同但指向其他模型
+		{"gpt-4.1", "gpt-4o", true},
+		{"unknown", "", false},
+	}
+	for _, tt := range tests {
+		name, found := findModelProfile(profiles, tt.model)
+		if name != tt.want || found != tt.found {
+			t.Errorf("findModelProfile(%q) = %q, %v, want %q, %v", tt.model, name, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestApplyGeneration(t *testing.T) {
+	tests := []struct {
+		name   string
+		params config.GenerationConfig
+		want   openai.ChatCompletionRequest
+	}{
+		{
+			name:   "未设置的参数不发送",
+			params: config.GenerationConfig{MaxTokens: 2000},
+			want:   openai.ChatCompletionRequest{MaxTokens: 2000},
+		},
+		{
+			name:   "max_tokens 为负数时不发送",
+			params: config.GenerationConfig{MaxTokens: -1},
+			want:   openai.ChatCompletionRequest{},
+		},
+		{
+			name:   "为 0 的 temperature 和 top_p 以极小值发送",
+			params: config.GenerationConfig{Temperature: ptr[float32](0), TopP: ptr[float32](0)},
+			want:   openai.ChatCompletionRequest{Temperature: zeroSampling, TopP: zeroSampling},
+		},
+		{
+			name: "其余参数",
+			params: config.GenerationConfig{
+				Temperature: ptr[float32](0.5), Stop: []string{"END"}, Seed: ptr(7),
+				PresencePenalty: ptr[float32](0.1), FrequencyPenalty: ptr[float32](-0.2),
+			},
+			want: openai.ChatCompletionRequest{
+				Temperature: 0.5, Stop: []string{"END"}, Seed: ptr(7),
+				PresencePenalty: 0.1, FrequencyPenalty: -0.2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req openai.ChatCompletionRequest
+			applyGeneration(&req, tt.params)
+			if !reflect.DeepEqual(req, tt.want) {
+				t.Errorf("applyGeneration() = %+v, want %+v", req, tt.want)
+			}
+		})
+	}
+}
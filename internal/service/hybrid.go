@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/search"
+	"knowledge-maker/internal/service/retriever"
+	"knowledge-maker/internal/vectorstore"
+)
+
+// openKeywordIndexes 为每个知识库打开本地关键词索引；本地向量知识库的索引为空时从向量存储初始化。
+// 失败时关闭已打开的索引
+func openKeywordIndexes(cfg *config.HybridConfig, registry *retriever.Registry) (_ map[string]*search.Index, err error) {
+	indexes := make(map[string]*search.Index)
+	defer func() {
+		if err != nil {
+			closeKeywordIndexes(indexes)
+		}
+	}()
+
+	for _, name := range registry.Names() {
+		idx, err := search.Open(filepath.Join(cfg.Dir, name+".gob"))
+		if err != nil {
+			return nil, fmt.Errorf("知识库 %s 打开关键词索引失败: %w", name, err)
+		}
+
+		r, _ := registry.Get(name)
+		if local, ok := r.(interface{ Store() *vectorstore.Store }); ok && idx.Count() == 0 {
+			records := local.Store().Records()
+			if len(records) > 0 {
+				entries := make([]search.Entry, len(records))
+				for i, record := range records {
+					entries[i] = search.Entry{ID: record.ID, DocumentID: record.DocumentID, Chunk: record.Chunk}
+				}
+				if err := idx.Load(entries); err != nil {
+					idx.Close()
+					return nil, fmt.Errorf("知识库 %s 初始化关键词索引失败: %v", name, err)
+				}
+				logger.Info("知识库 %s 已从向量存储初始化关键词索引，片段数: %d", name, len(entries))
+			}
+		}
+
+		indexes[name] = idx
+	}
+	return indexes, nil
+}
+
+// closeKeywordIndexes 关闭关键词索引，释放文件锁
+func closeKeywordIndexes(indexes map[string]*search.Index) error {
+	var errs []error
+	for _, idx := range indexes {
+		errs = append(errs, idx.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// hybridRetrieve 混合检索：分别进行向量/远程检索和关键词检索，再通过倒数排名融合合并结果
+func (ks *KnowledgeService) hybridRetrieve(ctx context.Context, name string, r retriever.Retriever, idx *search.Index, query string, topK int) ([]model.KnowledgeChunk, error) {
+	hybrid := ks.config.Knowledge.Hybrid
+
+	vectorChunks, vectorErr := r.Retrieve(ctx, query, max(hybrid.VectorTopK, topK))
+	if vectorErr != nil {
+		logger.Warn("知识库 %s 检索失败，仅使用关键词检索结果: %v", name, vectorErr)
+	}
+
+	var keywordChunks []model.KnowledgeChunk
+	for _, result := range idx.Search(query, hybrid.KeywordTopK) {
+		keywordChunks = append(keywordChunks, result.Chunk)
+	}
+
+	if vectorErr != nil && len(keywordChunks) == 0 {
+		return nil, vectorErr
+	}
+
+	logger.Info("知识库 %s 混合检索，向量/远程结果: %d，关键词结果: %d", name, len(vectorChunks), len(keywordChunks))
+	return fuseRankings(
+		[][]model.KnowledgeChunk{vectorChunks, keywordChunks},
+		[]float64{hybrid.VectorWeight, hybrid.KeywordWeight},
+		[]string{"vector_score", "keyword_score"},
+		hybrid.RRFK,
+		topK,
+	), nil
+}
+
+// fuseRankings 倒数排名融合（RRF）：片段得分为各结果列表中 weight / (k + 排名) 之和，内容相同的片段视为同一片段。
+// 各结果列表的分数含义不同（余弦相似度、BM25 等）无法直接比较，Score 统一替换为融合得分，
+// 原始分数按 scoreKeys 中对应的名称写入元数据，名称为空时不保留
+func fuseRankings(rankings [][]model.KnowledgeChunk, weights []float64, scoreKeys []string, k, topK int) []model.KnowledgeChunk {
+	type fused struct {
+		chunk model.KnowledgeChunk
+		score float64
+		order int
+	}
+
+	byKey := make(map[string]*fused)
+	var merged []*fused
+	for i, ranking := range rankings {
+		for rank, chunk := range ranking {
+			key := strings.TrimSpace(chunk.Content)
+			item, ok := byKey[key]
+			if !ok {
+				// 复制元数据后再写入，避免修改向量存储或缓存中的片段
+				item = &fused{chunk: chunk, order: len(merged)}
+				item.chunk.Metadata = make(map[string]interface{}, len(chunk.Metadata)+len(scoreKeys))
+				for name, value := range chunk.Metadata {
+					item.chunk.Metadata[name] = value
+				}
+				byKey[key] = item
+				merged = append(merged, item)
+			}
+			if i < len(scoreKeys) && scoreKeys[i] != "" {
+				if _, exists := item.chunk.Metadata[scoreKeys[i]]; !exists {
+					item.chunk.Metadata[scoreKeys[i]] = chunk.Score
+				}
+			}
+			item.score += weights[i] / float64(k+rank+1)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].score != merged[j].score {
+			return merged[i].score > merged[j].score
+		}
+		return merged[i].order < merged[j].order
+	})
+	if topK > 0 && len(merged) > topK {
+		merged = merged[:topK]
+	}
+
+	chunks := make([]model.KnowledgeChunk, len(merged))
+	for i, item := range merged {
+		chunks[i] = item.chunk
+		chunks[i].Score = item.score
+		if len(chunks[i].Metadata) == 0 {
+			chunks[i].Metadata = nil
+		}
+	}
+	return chunks
+}
+
+// hybridIndexer 同时写入知识库后端和本地关键词索引；后端不支持写入时仅写入关键词索引
+type hybridIndexer struct {
+	primary retriever.Indexer
+	keyword *search.Index
+}
+
+// IndexDocument 写入文档的全部片段
+func (h *hybridIndexer) IndexDocument(ctx context.Context, documentID string, chunks []model.KnowledgeChunk) error {
+	if h.primary != nil {
+		if err := h.primary.IndexDocument(ctx, documentID, chunks); err != nil {
+			return err
+		}
+	}
+
+	entries := make([]search.Entry, len(chunks))
+	for i, chunk := range chunks {
+		entries[i] = search.Entry{ID: fmt.Sprintf("%s#%d", documentID, i), Chunk: chunk}
+	}
+	return h.keyword.ReplaceDocument(documentID, entries)
+}
+
+// DeleteDocument 删除文档的全部片段
+func (h *hybridIndexer) DeleteDocument(ctx context.Context, documentID string) error {
+	if h.primary != nil {
+		if err := h.primary.DeleteDocument(ctx, documentID); err != nil {
+			return err
+		}
+	}
+	return h.keyword.DeleteDocument(documentID)
+}
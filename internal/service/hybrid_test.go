@@ -0,0 +1,145 @@
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"knowledge-maker/internal/model"
+)
+
+func TestFuseRankings(t *testing.T) {
+	const k = 60
+	rrf := func(ranks ...int) float64 {
+		var score float64
+		for _, rank := range ranks {
+			score += 1 / float64(k+rank)
+		}
+		return score
+	}
+	chunk := func(content string, score float64) model.KnowledgeChunk {
+		return model.KnowledgeChunk{Content: content, Score: score}
+	}
+
+	tests := []struct {
+		name      string
+		rankings  [][]model.KnowledgeChunk
+		weights   []float64
+		scoreKeys []string
+		topK      int
+		want      []string
+		wantScore []float64
+	}{
+		{
+			name: "两个列表都出现的片段排在前面",
+			rankings: [][]model.KnowledgeChunk{
+				{chunk("A", 0.9), chunk("B", 0.8)},
+				{chunk("B", 12), chunk("C", 7)},
+			},
+			weights:   []float64{1, 1},
+			want:      []string{"B", "A", "C"},
+			wantScore: []float64{rrf(2, 1), rrf(1), rrf(2)},
+		},
+		{
+			name: "内容相同（忽略首尾空白）的片段合并",
+			rankings: [][]model.KnowledgeChunk{
+				{chunk("A", 0.9)},
+				{chunk(" A\n", 3)},
+			},
+			weights:   []float64{1, 1},
+			want:      []string{"A"},
+			wantScore: []float64{rrf(1, 1)},
+		},
+		{
+			name: "权重影响排名",
+			rankings: [][]model.KnowledgeChunk{
+				{chunk("A", 0.9)},
+				{chunk("B", 3)},
+			},
+			weights:   []float64{1, 2},
+			want:      []string{"B", "A"},
+			wantScore: []float64{2 * rrf(1), rrf(1)},
+		},
+		{
+			name: "得分相同时保持首次出现的顺序",
+			rankings: [][]model.KnowledgeChunk{
+				{chunk("A", 0.9)},
+				{chunk("B", 3)},
+			},
+			weights:   []float64{1, 1},
+			want:      []string{"A", "B"},
+			wantScore: []float64{rrf(1), rrf(1)},
+		},
+		{
+			name: "按 topK 截断",
+			rankings: [][]model.KnowledgeChunk{
+				{chunk("A", 0.9), chunk("B", 0.8), chunk("C", 0.7)},
+			},
+			weights:   []float64{1},
+			topK:      2,
+			want:      []string{"A", "B"},
+			wantScore: []float64{rrf(1), rrf(2)},
+		},
+		{
+			name:     "没有结果",
+			rankings: [][]model.KnowledgeChunk{nil, nil},
+			weights:  []float64{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fuseRankings(tt.rankings, tt.weights, tt.scoreKeys, k, tt.topK)
+			var contents []string
+			var scores []float64
+			for _, c := range got {
+				contents = append(contents, c.Content)
+				scores = append(scores, c.Score)
+			}
+			if !reflect.DeepEqual(contents, tt.want) {
+				t.Fatalf("contents = %q, want %q", contents, tt.want)
+			}
+			for i := range scores {
+				if math.Abs(scores[i]-tt.wantScore[i]) > 1e-12 {
+					t.Errorf("score[%d] = %v, want %v", i, scores[i], tt.wantScore[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFuseRankingsScoreMetadata(t *testing.T) {
+	vector := []model.KnowledgeChunk{
+		{Content: "A", Score: 0.9, Metadata: map[string]interface{}{"document_id": "doc"}},
+		{Content: "B", Score: 0.8},
+	}
+	keyword := []model.KnowledgeChunk{
+		{Content: "A", Score: 12.5},
+	}
+
+	got := fuseRankings([][]model.KnowledgeChunk{vector, keyword}, []float64{1, 1}, []string{"vector_score", "keyword_score"}, 60, 0)
+
+	want := []map[string]interface{}{
+		{"document_id": "doc", "vector_score": 0.9, "keyword_score": 12.5},
+		{"vector_score": 0.8},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i].Metadata, want[i]) {
+			t.Errorf("chunk %s metadata = %v, want %v", got[i].Content, got[i].Metadata, want[i])
+		}
+	}
+
+	// 不修改输入片段的元数据
+	if !reflect.DeepEqual(vector[0].Metadata, map[string]interface{}{"document_id": "doc"}) {
+		t.Errorf("input metadata modified: %v", vector[0].Metadata)
+	}
+
+	// 不保留原始分数且没有元数据时为 nil
+	plain := fuseRankings([][]model.KnowledgeChunk{{{Content: "A", Score: 0.9}}}, []float64{1}, nil, 60, 0)
+	if plain[0].Metadata != nil {
+		t.Errorf("metadata = %v, want nil", plain[0].Metadata)
+	}
+}
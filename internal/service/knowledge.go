@@ -1,74 +1,381 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"strings"
+	"sync"
 
 	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
 	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/search"
+	"knowledge-maker/internal/service/reranker"
+	"knowledge-maker/internal/service/retriever"
 )
 
-// KnowledgeService 知识库服务
+var (
+	// ErrKnowledgeBaseNotFound 请求的知识库不存在
+	ErrKnowledgeBaseNotFound = errors.New("知识库不存在")
+	// ErrIndexNotSupported 知识库类型不支持写入文档（如 http 类型）
+	ErrIndexNotSupported = errors.New("知识库不支持写入文档")
+)
+
+// KnowledgeRetriever 知识检索接口，RAG 与 MCP 服务通过它获取知识片段
+type KnowledgeRetriever interface {
+	// ResolveKnowledgeBases 确定要检索的知识库：requested 非空时校验名称，否则由路由器根据问题选择
+	ResolveKnowledgeBases(ctx context.Context, query string, requested []string) ([]string, error)
+	// QueryKnowledgeBases 在一个或多个知识库中检索并合并结果，topK 为 0 时使用配置的检索数量
+	QueryKnowledgeBases(ctx context.Context, names []string, query string, topK int) ([]model.KnowledgeChunk, error)
+	// ListKnowledgeBases 列出已配置的知识库
+	ListKnowledgeBases() []model.KnowledgeBaseInfo
+}
+
+// KnowledgeService 知识库服务，管理所有已配置的知识库检索器
 type KnowledgeService struct {
-	baseURL string
-	token   string
-	config  *config.Config
+	registry       *retriever.Registry
+	router         *knowledgeRouter
+	keywordIndexes map[string]*search.Index // 混合检索启用时每个知识库的关键词索引
+	cache          *retrievalCache          // 检索结果缓存，未启用时为 nil
+	listeners      []func(name string)      // 知识库内容变更回调
+	config         *config.Config
 }
 
-// NewKnowledgeService 创建知识库服务实例
-func NewKnowledgeService(cfg *config.Config) *KnowledgeService {
-	return &KnowledgeService{
-		baseURL: cfg.Knowledge.BaseURL,
-		token:   cfg.Knowledge.Token,
-		config:  cfg,
+// NewKnowledgeService 创建知识库服务实例，embedder 用于本地向量知识库，completer 用于 LLM 知识库路由
+func NewKnowledgeService(cfg *config.Config, embedder retriever.Embedder, completer reranker.Completer) (_ *KnowledgeService, err error) {
+	registry, err := retriever.NewRegistry(&cfg.Knowledge, &retriever.Dependencies{Embedder: embedder})
+	if err != nil {
+		return nil, err
+	}
+	// 后续初始化失败时释放已持有的文件锁，否则在进程退出前无法再次打开这些知识库
+	var keywordIndexes map[string]*search.Index
+	defer func() {
+		if err != nil {
+			registry.Close()
+			closeKeywordIndexes(keywordIndexes)
+		}
+	}()
+
+	router, err := newKnowledgeRouter(&cfg.Knowledge.Router, registry, completer)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range registry.Names() {
+		r, _ := registry.Get(name)
+		logger.Info("知识库 %s 已加载，类型: %s", name, r.GetType())
+	}
+	logger.Info("默认知识库: %s", registry.DefaultName())
+	if router.config.Type != "" && router.config.Type != "none" {
+		logger.Info("知识库自动路由已启用，类型: %s，最多检索知识库数: %d", router.config.Type, router.config.MaxBases)
 	}
+
+	ks := &KnowledgeService{
+		registry: registry,
+		router:   router,
+		config:   cfg,
+	}
+
+	if cfg.Knowledge.Hybrid.Enabled {
+		keywordIndexes, err = openKeywordIndexes(&cfg.Knowledge.Hybrid, registry)
+		if err != nil {
+			return nil, err
+		}
+		ks.keywordIndexes = keywordIndexes
+		logger.Info("混合检索已启用，关键词候选数: %d，向量/远程候选数: %d", cfg.Knowledge.Hybrid.KeywordTopK, cfg.Knowledge.Hybrid.VectorTopK)
+	}
+
+	if cfg.Knowledge.Cache.Enabled {
+		ks.cache, err = newRetrievalCache(&cfg.Knowledge.Cache)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("检索结果缓存已启用，容量: %d，有效期: %d 秒，已加载: %d", cfg.Knowledge.Cache.Size, cfg.Knowledge.Cache.TTL, ks.cache.Stats().Size)
+	}
+
+	return ks, nil
 }
 
-// QueryKnowledge 查询知识库
-func (ks *KnowledgeService) QueryKnowledge(query string) (string, error) {
-	// 构建请求体
-	requestBody := model.KnowledgeQuery{
-		Query: query,
-		TopK:  ks.config.Knowledge.TopK,
+// Close 关闭知识库服务：保存检索结果缓存，释放本地向量存储和关键词索引的文件锁
+func (ks *KnowledgeService) Close() error {
+	var errs []error
+	if ks.cache != nil {
+		errs = append(errs, ks.cache.Save())
 	}
+	errs = append(errs, ks.registry.Close(), closeKeywordIndexes(ks.keywordIndexes))
+	return errors.Join(errs...)
+}
 
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", fmt.Errorf("序列化请求数据失败: %v", err)
+// OnChange 注册知识库内容变更回调，通过 Indexer 写入或删除文档后以知识库名称调用，需在启动时注册
+func (ks *KnowledgeService) OnChange(listener func(name string)) {
+	ks.listeners = append(ks.listeners, listener)
+}
+
+// notifyChange 知识库内容变更后清除该知识库的检索缓存并通知回调
+func (ks *KnowledgeService) notifyChange(name string) {
+	if ks.cache != nil {
+		ks.cache.PurgeBase(name)
+	}
+	for _, listener := range ks.listeners {
+		listener(name)
 	}
+}
 
-	// 创建 HTTP 请求
-	req, err := http.NewRequest("POST", ks.baseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %v", err)
+// CacheStats 获取检索结果缓存统计
+func (ks *KnowledgeService) CacheStats() model.CacheStats {
+	if ks.cache == nil {
+		return model.CacheStats{}
 	}
+	return ks.cache.Stats()
+}
 
-	// 设置请求头
-	req.Header.Set("Authorization", ks.token)
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+// PurgeCache 清除检索结果缓存，name 为空时清除全部知识库的缓存，返回清除数量
+func (ks *KnowledgeService) PurgeCache(name string) (int, error) {
+	if name != "" {
+		if _, ok := ks.registry.Get(name); !ok {
+			return 0, fmt.Errorf("%w: %s", ErrKnowledgeBaseNotFound, name)
+		}
+	}
+	if ks.cache == nil {
+		return 0, nil
+	}
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+	var purged int
+	if name == "" {
+		purged = ks.cache.Purge()
+	} else {
+		purged = ks.cache.PurgeBase(name)
+	}
+	logger.Info("检索结果缓存已清除，知识库: %s，清除条目数: %d", name, purged)
+	return purged, nil
+}
+
+// ResolveKnowledgeBases 确定要检索的知识库：requested 非空时校验名称并去重，否则由路由器根据问题选择
+func (ks *KnowledgeService) ResolveKnowledgeBases(ctx context.Context, query string, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return ks.router.Route(ctx, query), nil
 	}
-	defer resp.Body.Close()
 
-	// 读取响应
-	body, err := io.ReadAll(resp.Body)
+	names := make([]string, 0, len(requested))
+	seen := make(map[string]bool)
+	for _, name := range requested {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := ks.registry.Get(name); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrKnowledgeBaseNotFound, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return ks.router.Route(ctx, query), nil
+	}
+	return names, nil
+}
+
+// QueryKnowledgeBases 在一个或多个知识库中检索；多个知识库并行检索，结果按倒数排名融合后取前 topK 个，
+// 部分知识库检索失败时仅使用其余知识库的结果
+func (ks *KnowledgeService) QueryKnowledgeBases(ctx context.Context, names []string, query string, topK int) ([]model.KnowledgeChunk, error) {
+	if len(names) == 0 {
+		names = []string{ks.registry.DefaultName()}
+	}
+	if len(names) == 1 {
+		if topK <= 0 {
+			topK = ks.topK(names[0])
+		}
+		return ks.queryKnowledgeBase(ctx, names[0], query, topK)
+	}
+
+	rankings := make([][]model.KnowledgeChunk, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			baseTopK := topK
+			if baseTopK <= 0 {
+				baseTopK = ks.topK(name)
+			}
+			rankings[i], errs[i] = ks.queryKnowledgeBase(ctx, name, query, baseTopK)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var failed []string
+	weights := make([]float64, len(names))
+	for i, err := range errs {
+		weights[i] = 1
+		if err != nil {
+			logger.Error("知识库 %s 检索失败: %v", names[i], err)
+			failed = append(failed, names[i])
+		}
+	}
+	if len(failed) == len(names) {
+		return nil, fmt.Errorf("所有知识库检索失败: %v", errs[0])
+	}
+
+	if topK <= 0 {
+		topK = ks.config.Knowledge.TopK
+	}
+	// 各知识库的分数含义可能不同，原始分数保留在元数据的 retrieval_score 中
+	scoreKeys := make([]string, len(names))
+	for i := range scoreKeys {
+		scoreKeys[i] = "retrieval_score"
+	}
+	return fuseRankings(rankings, weights, scoreKeys, ks.config.Knowledge.Hybrid.RRFK, topK), nil
+}
+
+// ListKnowledgeBases 列出已配置的知识库
+func (ks *KnowledgeService) ListKnowledgeBases() []model.KnowledgeBaseInfo {
+	infos := make([]model.KnowledgeBaseInfo, 0, len(ks.registry.Names()))
+	for _, name := range ks.registry.Names() {
+		r, _ := ks.registry.Get(name)
+		infos = append(infos, model.KnowledgeBaseInfo{
+			Name:        name,
+			Type:        r.GetType(),
+			Description: ks.registry.Config(name).Description,
+			Default:     name == ks.registry.DefaultName(),
+		})
+	}
+	return infos
+}
+
+// queryKnowledgeBase 在指定名称的知识库中检索 topK 个片段，启用混合检索时融合关键词检索结果，
+// 返回的片段标记所属知识库；启用缓存时优先返回缓存结果
+func (ks *KnowledgeService) queryKnowledgeBase(ctx context.Context, name, query string, topK int) ([]model.KnowledgeChunk, error) {
+	r, ok := ks.registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKnowledgeBaseNotFound, name)
+	}
+
+	if ks.cache != nil {
+		if chunks, ok := ks.cache.Get(name, topK, query); ok {
+			logger.Info("知识库 %s 命中检索缓存，片段数: %d", name, len(chunks))
+			return chunks, nil
+		}
+	}
+
+	var (
+		chunks []model.KnowledgeChunk
+		err    error
+	)
+	if idx := ks.keywordIndexes[name]; idx != nil && idx.Count() > 0 {
+		chunks, err = ks.hybridRetrieve(ctx, name, r, idx, query, topK)
+	} else {
+		chunks, err = r.Retrieve(ctx, query, topK)
+	}
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %v", err)
+		return nil, err
+	}
+	for i := range chunks {
+		chunks[i].KnowledgeBase = name
+	}
+
+	if ks.cache != nil {
+		ks.cache.Set(name, topK, query, chunks)
 	}
+	return chunks, nil
+}
 
-	// 检查状态码
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("知识库查询失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+// Indexer 获取支持写入的知识库，name 为空时使用默认知识库
+func (ks *KnowledgeService) Indexer(name string) (string, retriever.Indexer, error) {
+	if name == "" {
+		name = ks.registry.DefaultName()
 	}
 
-	// 解析响应（这里假设直接返回文本内容，您可能需要根据实际 API 响应格式调整）
-	return string(body), nil
-}
\ No newline at end of file
+	r, ok := ks.registry.Get(name)
+	if !ok {
+		return name, nil, fmt.Errorf("%w: %s", ErrKnowledgeBaseNotFound, name)
+	}
+	indexer, _ := r.(retriever.Indexer)
+	if idx := ks.keywordIndexes[name]; idx != nil {
+		indexer = &hybridIndexer{primary: indexer, keyword: idx}
+	}
+	if indexer == nil {
+		return name, nil, fmt.Errorf("%w: %s（类型 %s）", ErrIndexNotSupported, name, r.GetType())
+	}
+	return name, &notifyingIndexer{Indexer: indexer, notify: func() { ks.notifyChange(name) }}, nil
+}
+
+// notifyingIndexer 写入或删除文档后通知知识库内容变更，避免缓存返回过期结果
+type notifyingIndexer struct {
+	retriever.Indexer
+	notify func()
+}
+
+// IndexDocument 写入文档的全部片段
+func (n *notifyingIndexer) IndexDocument(ctx context.Context, documentID string, chunks []model.KnowledgeChunk) error {
+	err := n.Indexer.IndexDocument(ctx, documentID, chunks)
+	n.notify()
+	return err
+}
+
+// DeleteDocument 删除文档的全部片段
+func (n *notifyingIndexer) DeleteDocument(ctx context.Context, documentID string) error {
+	err := n.Indexer.DeleteDocument(ctx, documentID)
+	n.notify()
+	return err
+}
+
+// topK 获取知识库的检索数量，未单独配置时使用全局配置
+func (ks *KnowledgeService) topK(name string) int {
+	if baseCfg := ks.registry.Config(name); baseCfg != nil && baseCfg.TopK > 0 {
+		return baseCfg.TopK
+	}
+	return ks.config.Knowledge.TopK
+}
+
+// formatKnowledgeContext 将知识片段格式化为带编号的提示词上下文，编号与参考来源一一对应；
+// 片段来自多个知识库时标注所属知识库
+func formatKnowledgeContext(chunks []model.KnowledgeChunk) string {
+	multiBase := false
+	for _, chunk := range chunks {
+		if chunk.KnowledgeBase != chunks[0].KnowledgeBase {
+			multiBase = true
+			break
+		}
+	}
+
+	var b strings.Builder
+	for i, chunk := range chunks {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(fmt.Sprintf("[%d]", i+1))
+		if chunk.Title != "" {
+			b.WriteString(" " + chunk.Title)
+		}
+		if multiBase && chunk.KnowledgeBase != "" {
+			b.WriteString("\n知识库: " + chunk.KnowledgeBase)
+		}
+		if chunk.URL != "" {
+			b.WriteString("\n来源: " + chunk.URL)
+		}
+		b.WriteString("\n" + chunk.Content)
+	}
+	return b.String()
+}
+
+// buildSources 根据知识片段生成参考来源列表
+func buildSources(chunks []model.KnowledgeChunk) []model.Source {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	sources := make([]model.Source, 0, len(chunks))
+	for i, chunk := range chunks {
+		sources = append(sources, model.Source{
+			Index:         i + 1,
+			Title:         chunk.Title,
+			URL:           chunk.URL,
+			Source:        chunk.Source,
+			Score:         chunk.Score,
+			KnowledgeBase: chunk.KnowledgeBase,
+		})
+	}
+	return sources
+}
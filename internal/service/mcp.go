@@ -5,32 +5,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
 	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/prompt"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 // MCPService MCP 服务，提供知识库工具和 LLM 聊天接口
 type MCPService struct {
-	knowledgeService *KnowledgeService
+	knowledgeService KnowledgeRetriever
 	aiService        *AIService
+	templates        *prompt.Templates
 	config           *config.Config
 }
 
 // NewMCPService 创建 MCP 服务实例
-func NewMCPService(knowledgeService *KnowledgeService, aiService *AIService, cfg *config.Config) *MCPService {
+func NewMCPService(knowledgeService KnowledgeRetriever, aiService *AIService, templates *prompt.Templates, cfg *config.Config) *MCPService {
 	return &MCPService{
 		knowledgeService: knowledgeService,
 		aiService:        aiService,
+		templates:        templates,
 		config:           cfg,
 	}
 }
 
 // ListTools 返回可用的 MCP 工具列表
 func (ms *MCPService) ListTools() []model.MCPTool {
+	// 知识库参数的可选值和说明来自配置
+	var names []string
+	var descriptions []string
+	for _, base := range ms.knowledgeService.ListKnowledgeBases() {
+		names = append(names, base.Name)
+		if base.Description != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s（%s）", base.Name, base.Description))
+		} else {
+			descriptions = append(descriptions, base.Name)
+		}
+	}
+
 	return []model.MCPTool{
 		{
 			Name:        "query_knowledge_base",
@@ -42,6 +58,14 @@ func (ms *MCPService) ListTools() []model.MCPTool {
 						"type":        "string",
 						"description": "需要在知识库中查询的问题或关键词",
 					},
+					"knowledge_bases": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type": "string",
+							"enum": names,
+						},
+						"description": "要检索的知识库，不指定时根据问题自动选择。可选: " + strings.Join(descriptions, "、"),
+					},
 				},
 				"required": []string{"query"},
 			},
@@ -50,31 +74,43 @@ func (ms *MCPService) ListTools() []model.MCPTool {
 }
 
 // CallTool 调用指定的 MCP 工具
-func (ms *MCPService) CallTool(toolName string, arguments map[string]interface{}) (interface{}, error) {
+func (ms *MCPService) CallTool(ctx context.Context, toolName string, arguments map[string]interface{}) (interface{}, error) {
 	switch toolName {
 	case "query_knowledge_base":
-		return ms.callQueryKnowledgeBase(arguments)
+		return ms.callQueryKnowledgeBase(ctx, arguments)
 	default:
 		return nil, fmt.Errorf("未知的工具: %s", toolName)
 	}
 }
 
-// callQueryKnowledgeBase 调用知识库查询工具
-func (ms *MCPService) callQueryKnowledgeBase(arguments map[string]interface{}) (interface{}, error) {
+// callQueryKnowledgeBase 调用知识库查询工具，检索时限为 timeouts.retrieval
+func (ms *MCPService) callQueryKnowledgeBase(ctx context.Context, arguments map[string]interface{}) (interface{}, error) {
 	query, ok := arguments["query"].(string)
 	if !ok || query == "" {
 		return nil, fmt.Errorf("缺少必要参数: query")
 	}
 
-	logger.Info("[MCP] 知识库查询工具被调用，查询: %s", query)
+	ctx, cancel := withTimeout(ctx, ms.config.Timeouts.Retrieval, "知识检索")
+	defer cancel()
 
-	result, err := ms.knowledgeService.QueryKnowledge(query)
+	bases, err := ms.knowledgeService.ResolveKnowledgeBases(ctx, query, stringList(arguments["knowledge_bases"]))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("[MCP] 知识库查询工具被调用，查询: %s，知识库: %s", query, strings.Join(bases, ", "))
+
+	chunks, err := ms.knowledgeService.QueryKnowledgeBases(ctx, bases, query, 0)
+	if cause := contextError(ctx); cause != nil {
+		logger.Warn("[MCP] 知识库查询已中止: %v", cause)
+		return nil, cause
+	}
 	if err != nil {
 		logger.Error("[MCP] 知识库查询失败: %v", err)
 		return nil, fmt.Errorf("知识库查询失败: %v", err)
 	}
 
-	if result == "" {
+	if len(chunks) == 0 {
 		logger.Info("[MCP] 知识库查询结果为空")
 		return map[string]interface{}{
 			"found":   false,
@@ -83,32 +119,64 @@ func (ms *MCPService) callQueryKnowledgeBase(arguments map[string]interface{}) (
 	}
 
 	// 记录查询结果预览
-	preview := result
+	result := formatKnowledgeContext(chunks)
+	preview := []rune(result)
 	if len(preview) > 200 {
-		preview = preview[:200] + "..."
+		preview = append(preview[:200], []rune("...")...)
 	}
-	logger.Info("[MCP] 知识库查询成功，结果预览: %s", preview)
+	logger.Info("[MCP] 知识库查询成功，片段数: %d，结果预览: %s", len(chunks), string(preview))
 
 	return map[string]interface{}{
 		"found":   true,
 		"content": result,
+		"sources": buildSources(chunks),
 	}, nil
 }
 
-// LLMChat LLM 非流式聊天（支持 Function Calling）
-func (ms *MCPService) LLMChat(req model.LLMChatRequest) (*model.LLMChatResponse, error) {
+// stringList 将工具参数转换为字符串列表，兼容数组和逗号分隔的字符串
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case []string:
+		return v
+	case string:
+		return strings.Split(v, ",")
+	default:
+		return nil
+	}
+}
+
+// LLMChat LLM 非流式聊天（支持 Function Calling），ctx 取消或超过 timeouts.total 时中止
+func (ms *MCPService) LLMChat(ctx context.Context, req model.LLMChatRequest) (*model.LLMChatResponse, error) {
+	ctx, cancel := withTimeout(ctx, ms.config.Timeouts.Total, "请求总耗时")
+	defer cancel()
+
 	logger.Info("[MCP] LLM 非流式聊天请求，消息数: %d，工具数: %d", len(req.Messages), len(req.Tools))
 
+	opts, err := ms.aiService.ResolveChat(req.Model, &req.GenerationParams)
+	if err != nil {
+		return &model.LLMChatResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, err
+	}
+
 	// 构建 OpenAI 消息
 	messages := ms.buildOpenAIMessages(req.Messages)
 
 	// 构建请求
 	chatReq := openai.ChatCompletionRequest{
-		Model:       ms.aiService.model,
-		Messages:    messages,
-		MaxTokens:   2000,
-		Temperature: 0.7,
+		Model:    opts.Model,
+		Messages: messages,
 	}
+	applyGeneration(&chatReq, opts.Generation)
 
 	// 如果有工具定义，添加到请求中
 	if len(req.Tools) > 0 {
@@ -116,7 +184,14 @@ func (ms *MCPService) LLMChat(req model.LLMChatRequest) (*model.LLMChatResponse,
 	}
 
 	// 调用 AI API
-	resp, err := ms.aiService.client.CreateChatCompletion(context.Background(), chatReq)
+	resp, provider, err := ms.aiService.createChatCompletion(ctx, chatReq)
+	if cause := contextError(ctx); cause != nil {
+		logger.Warn("[MCP] LLM 聊天已中止: %v", cause)
+		return &model.LLMChatResponse{
+			Success: false,
+			Error:   cause.Error(),
+		}, cause
+	}
 	if err != nil {
 		logger.Error("[MCP] LLM 聊天失败: %v", err)
 		return &model.LLMChatResponse{
@@ -155,27 +230,36 @@ func (ms *MCPService) LLMChat(req model.LLMChatRequest) (*model.LLMChatResponse,
 		logger.Info("[MCP] LLM 请求工具调用，工具数: %d", len(toolCalls))
 	}
 
+	logger.Info("[MCP] LLM 回复由 %s 生成，模型: %s", provider.name, resp.Model)
 	return &model.LLMChatResponse{
-		Success: true,
-		Message: responseMsg,
+		Success:  true,
+		Message:  responseMsg,
+		Provider: provider.name,
 	}, nil
 }
 
-// LLMStreamChat LLM 流式聊天（支持 Function Calling）
-func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStreamChunk, chan error, error) {
+// LLMStreamChat LLM 流式聊天（支持 Function Calling），ctx 取消或超过 timeouts.total 时中止并关闭上游的流式响应
+func (ms *MCPService) LLMStreamChat(ctx context.Context, req model.LLMChatRequest) (chan model.LLMStreamChunk, chan error, error) {
 	logger.Info("[MCP] LLM 流式聊天请求，消息数: %d，工具数: %d", len(req.Messages), len(req.Tools))
 
+	opts, err := ms.aiService.ResolveChat(req.Model, &req.GenerationParams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 总超时的计时器由转发协程在结束时释放
+	ctx, cancel := withTimeout(ctx, ms.config.Timeouts.Total, "请求总耗时")
+
 	// 构建 OpenAI 消息
 	messages := ms.buildOpenAIMessages(req.Messages)
 
 	// 构建请求
 	chatReq := openai.ChatCompletionRequest{
-		Model:       ms.aiService.model,
-		Messages:    messages,
-		MaxTokens:   2000,
-		Temperature: 0.7,
-		Stream:      true,
+		Model:    opts.Model,
+		Messages: messages,
+		Stream:   true,
 	}
+	applyGeneration(&chatReq, opts.Generation)
 
 	// 如果有工具定义，添加到请求中
 	if len(req.Tools) > 0 {
@@ -183,28 +267,59 @@ func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStr
 	}
 
 	// 调用流式 AI API
-	stream, err := ms.aiService.client.CreateChatCompletionStream(context.Background(), chatReq)
+	stream, err := ms.aiService.createChatCompletionStream(ctx, chatReq)
+	if cause := contextError(ctx); cause != nil {
+		cancel()
+		logger.Warn("[MCP] LLM 流式聊天已中止: %v", cause)
+		return nil, nil, cause
+	}
 	if err != nil {
+		cancel()
 		logger.Error("[MCP] LLM 流式聊天失败: %v", err)
 		return nil, nil, fmt.Errorf("LLM 流式调用失败: %v", err)
 	}
+	logger.Info("[MCP] LLM 流式回复由 %s 生成，模型: %s", stream.Provider, stream.Model)
 
 	chunkChan := make(chan model.LLMStreamChunk, 10)
 	errorChan := make(chan error, 1)
 
 	go func() {
+		defer cancel()
 		defer close(chunkChan)
 		defer close(errorChan)
 		defer stream.Close()
 
+		// 客户端断开连接或超时后不再阻塞在发送上
+		send := func(chunk model.LLMStreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// 内联在 content 开头的 <think>...</think> 拆分为思考内容
+		var think thinkParser
+		providerSent := false
 		for {
 			response, err := stream.Recv()
 			if err != nil {
 				if err == io.EOF {
-					// 发送结束信号
-					chunkChan <- model.LLMStreamChunk{
-						FinishReason: "stop",
+					// 发送暂存的内容和结束信号
+					if reasoning, content := think.Flush(); reasoning != "" || content != "" {
+						if !send(model.LLMStreamChunk{Delta: &model.LLMChatMessageDelta{Content: content, ReasoningContent: reasoning}}) {
+							return
+						}
 					}
+					send(model.LLMStreamChunk{
+						FinishReason: "stop",
+					})
+					return
+				}
+				if cause := contextError(ctx); cause != nil {
+					logger.Warn("[MCP] 流式回复已中断: %v", cause)
+					errorChan <- fmt.Errorf("流式回复已中断: %w", cause)
 					return
 				}
 				logger.Error("[MCP] 流式接收失败: %v", err)
@@ -214,7 +329,6 @@ func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStr
 
 			if len(response.Choices) > 0 {
 				choice := response.Choices[0]
-				chunk := model.LLMStreamChunk{}
 
 				delta := &model.LLMChatMessageDelta{}
 				hasContent := false
@@ -225,16 +339,19 @@ func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStr
 					hasContent = true
 				}
 
-				// 处理普通内容
-				if choice.Delta.Content != "" {
-					delta.Content = choice.Delta.Content
+				// 处理普通内容和思考内容（reasoning_content 或内联的思考块），结束时发送暂存的内容
+				reasoning, content := think.Feed(choice.Delta.Content)
+				if choice.FinishReason != "" {
+					r, c := think.Flush()
+					reasoning, content = reasoning+r, content+c
+				}
+				if content != "" {
+					delta.Content = content
 					hasContent = true
 				}
-
-				// 处理思考内容（reasoning_content）
-				if choice.Delta.ReasoningContent != "" {
-					logger.Debug("[MCP] 收到思考内容: %s", choice.Delta.ReasoningContent[:min(50, len(choice.Delta.ReasoningContent))])
-					delta.ReasoningContent = choice.Delta.ReasoningContent
+				if reasoning = choice.Delta.ReasoningContent + reasoning; reasoning != "" {
+					logger.Debug("[MCP] 收到思考内容: %s", reasoning[:min(50, len(reasoning))])
+					delta.ReasoningContent = reasoning
 					hasContent = true
 				}
 
@@ -263,15 +380,24 @@ func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStr
 					hasContent = true
 				}
 
-				// 处理 finish_reason
+				// 内容和 finish_reason 分开发送：客户端收到结束事件（stop、tool_calls）后不再处理 delta，
+				// 同一数据块中的最后一段内容需要先发送
+				var chunks []model.LLMStreamChunk
+				if hasContent {
+					chunks = append(chunks, model.LLMStreamChunk{Delta: delta})
+				}
 				if choice.FinishReason != "" {
-					chunk.FinishReason = string(choice.FinishReason)
-					hasContent = true
+					chunks = append(chunks, model.LLMStreamChunk{FinishReason: string(choice.FinishReason)})
 				}
-
-				if hasContent {
-					chunk.Delta = delta
-					chunkChan <- chunk
+				for _, chunk := range chunks {
+					if !providerSent {
+						chunk.Provider = stream.Provider
+						providerSent = true
+					}
+					if !send(chunk) {
+						logger.Warn("[MCP] 流式回复已中断: %v", context.Cause(ctx))
+						return
+					}
 				}
 			}
 		}
@@ -280,6 +406,26 @@ func (ms *MCPService) LLMStreamChat(req model.LLMChatRequest) (chan model.LLMStr
 	return chunkChan, errorChan, nil
 }
 
+// renderSystemPrompt 按模板渲染系统提示词，以最后一条用户消息作为问题，模板执行失败时使用 rag.system_prompt
+func (ms *MCPService) renderSystemPrompt(messages []model.LLMChatMessage) string {
+	var query string
+	for _, msg := range messages {
+		if msg.Role == openai.ChatMessageRoleUser {
+			query = msg.Content
+		}
+	}
+
+	systemPrompt, err := ms.templates.System(prompt.Data{
+		Query:    query,
+		Language: prompt.DetectLanguage(query),
+	})
+	if err != nil {
+		logger.Error("渲染系统提示词模板失败，使用默认系统提示词: %v", err)
+		return ms.config.RAG.SystemPrompt
+	}
+	return systemPrompt
+}
+
 // buildOpenAIMessages 将 LLMChatMessage 转换为 OpenAI 消息格式
 func (ms *MCPService) buildOpenAIMessages(messages []model.LLMChatMessage) []openai.ChatCompletionMessage {
 	var openaiMessages []openai.ChatCompletionMessage
@@ -293,11 +439,13 @@ func (ms *MCPService) buildOpenAIMessages(messages []model.LLMChatMessage) []ope
 		}
 	}
 
-	if !hasSystemPrompt && ms.config.RAG.SystemPrompt != "" {
-		openaiMessages = append(openaiMessages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: ms.config.RAG.SystemPrompt,
-		})
+	if !hasSystemPrompt {
+		if systemPrompt := ms.renderSystemPrompt(messages); systemPrompt != "" {
+			openaiMessages = append(openaiMessages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			})
+		}
 	}
 
 	for _, msg := range messages {
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+)
+
+// ErrModelNotAllowed 请求的模型不在 ai.profiles 允许的列表中
+var ErrModelNotAllowed = errors.New("模型不可用")
+
+// ChatOptions 一次对话调用使用的模型和生成参数
+type ChatOptions struct {
+	Profile    string // 模型配置名称，默认模型没有对应配置时为空
+	Model      string // 实际请求的模型名称
+	Generation config.GenerationConfig
+}
+
+// ResolveChat 根据请求选择的模型配置名称和覆盖的生成参数确定调用选项；
+// name 为空时使用默认模型，不在允许列表中时返回 ErrModelNotAllowed，参数无效时返回 ErrInvalidGeneration
+func (ai *AIService) ResolveChat(name string, overrides *model.GenerationParams) (ChatOptions, error) {
+	opts := ChatOptions{Model: ai.model}
+	if profile, ok := ai.config.Profiles[name]; ok {
+		opts.Profile = name
+		if profile.Model != "" {
+			opts.Model = profile.Model
+		} else {
+			opts.Model = name
+		}
+	} else if name != "" && name != ai.model {
+		return opts, fmt.Errorf("%w: %s", ErrModelNotAllowed, name)
+	} else if profileName, ok := findModelProfile(ai.config.Profiles, ai.model); ok {
+		opts.Profile = profileName
+	}
+
+	if _, ok := ai.servingModel(opts.Model); !ok {
+		return opts, errNoProviderForModel(opts.Model)
+	}
+
+	var profile *config.ModelProfile
+	if opts.Profile != "" {
+		p := ai.config.Profiles[opts.Profile]
+		profile = &p
+	}
+	generation, err := resolveGeneration(ai.config, profile, overrides)
+	if err != nil {
+		return opts, err
+	}
+	opts.Generation = generation
+	return opts, nil
+}
+
+// Models 列出可供请求选择的模型，默认模型排在最前
+func (ai *AIService) Models() []model.ModelInfo {
+	defaultProfile, hasDefault := findModelProfile(ai.config.Profiles, ai.model)
+
+	models := make([]model.ModelInfo, 0, len(ai.config.Profiles)+1)
+	if !hasDefault {
+		models = append(models, model.ModelInfo{
+			Name:          ai.model,
+			DisplayName:   ai.model,
+			Capabilities:  []string{},
+			ContextWindow: contextWindow(ai.config, ai.model),
+			MaxTokens:     max(ai.config.MaxTokens, 0),
+			Default:       true,
+		})
+	}
+	for _, name := range profileNames(ai.config.Profiles) {
+		profile := ai.config.Profiles[name]
+		modelName := profile.Model
+		if modelName == "" {
+			modelName = name
+		}
+		info := model.ModelInfo{
+			Name:          name,
+			DisplayName:   profile.DisplayName,
+			Description:   profile.Description,
+			Capabilities:  profile.Capabilities,
+			ContextWindow: contextWindow(ai.config, modelName),
+			MaxTokens:     max(mergeGeneration(ai.config.GenerationConfig, profile.GenerationConfig).MaxTokens, 0),
+			Default:       hasDefault && name == defaultProfile,
+		}
+		if info.DisplayName == "" {
+			info.DisplayName = name
+		}
+		if info.Capabilities == nil {
+			info.Capabilities = []string{}
+		}
+		if info.Default {
+			models = append([]model.ModelInfo{info}, models...)
+		} else {
+			models = append(models, info)
+		}
+	}
+	return models
+}
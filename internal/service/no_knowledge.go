@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+)
+
+// 未检索到相关知识时的处理策略
+const (
+	noKnowledgeAnswer     = "answer"     // 由 AI 直接回答
+	noKnowledgeDisclaimer = "disclaimer" // 回答前附加提示
+	noKnowledgeRefuse     = "refuse"     // 返回固定回复，不调用 AI
+	noKnowledgeFallback   = "fallback"   // 检索备用知识库
+)
+
+// validateNoKnowledgePolicy 校验未检索到知识时的处理策略配置
+func (rs *RAGService) validateNoKnowledgePolicy() error {
+	cfg := rs.config.RAG.NoKnowledge
+	switch cfg.Policy {
+	case noKnowledgeAnswer, noKnowledgeDisclaimer, noKnowledgeRefuse:
+		return nil
+	case noKnowledgeFallback:
+	default:
+		return fmt.Errorf("不支持的无知识处理策略: %s", cfg.Policy)
+	}
+
+	switch cfg.FallbackPolicy {
+	case noKnowledgeAnswer, noKnowledgeDisclaimer, noKnowledgeRefuse:
+	default:
+		return fmt.Errorf("不支持的备用知识库无结果处理策略: %s", cfg.FallbackPolicy)
+	}
+	if len(cfg.FallbackBases) == 0 {
+		return fmt.Errorf("fallback 策略需要配置 rag.no_knowledge.fallback_bases")
+	}
+	if _, err := rs.knowledgeService.ResolveKnowledgeBases(context.Background(), "", cfg.FallbackBases); err != nil {
+		return fmt.Errorf("备用知识库配置无效: %w", err)
+	}
+	return nil
+}
+
+// handleNoKnowledge 检索失败或结果为空时按配置的策略处理；fallback 策略检索备用知识库，
+// 返回备用知识库的片段和名称，以及最终采取的策略。ctx 为请求上下文，主检索超时后备用知识库仍有
+// 完整的 timeouts.retrieval 时限
+func (rs *RAGService) handleNoKnowledge(ctx context.Context, query string, bases []string, queryErr error) ([]model.KnowledgeChunk, []string, string) {
+	cfg := rs.config.RAG.NoKnowledge
+	reason := "知识库中没有相关内容"
+	if queryErr != nil {
+		reason = "知识库检索失败"
+	}
+
+	if cfg.Policy != noKnowledgeFallback {
+		logger.Warn("%s（知识库: %s，问题: %s），按 %s 策略处理", reason, strings.Join(bases, ", "), query, cfg.Policy)
+		return nil, bases, cfg.Policy
+	}
+
+	fallbackCtx, cancel := withTimeout(ctx, rs.config.Timeouts.Retrieval, "备用知识库检索")
+	defer cancel()
+
+	fallbackBases, _ := rs.knowledgeService.ResolveKnowledgeBases(fallbackCtx, query, cfg.FallbackBases)
+	logger.Warn("%s（知识库: %s，问题: %s），检索备用知识库: %s", reason, strings.Join(bases, ", "), query, strings.Join(fallbackBases, ", "))
+	chunks, err := rs.queryKnowledgeWithDetailedLogging(fallbackCtx, query, fallbackBases)
+	if err == nil && len(chunks) > 0 {
+		logger.Info("备用知识库检索到 %d 个片段", len(chunks))
+		return chunks, fallbackBases, noKnowledgeFallback
+	}
+
+	logger.Warn("备用知识库同样没有相关内容，按 %s 策略处理", cfg.FallbackPolicy)
+	return nil, bases, cfg.FallbackPolicy
+}
+
+// answerPrefix 按处理策略确定附加在回答前的内容
+func (rs *RAGService) answerPrefix(policy string) string {
+	if policy == noKnowledgeDisclaimer {
+		return rs.config.RAG.NoKnowledge.Disclaimer + "\n\n"
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/resilience"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// errFirstTokenTimeout 流式回复在 timeouts.first_token 时间内没有返回首个数据块
+var errFirstTokenTimeout = errors.New("等待 AI 首个数据块超时")
+
+// aiProvider 对话模型服务提供方
+type aiProvider struct {
+	name   string
+	client *openai.Client
+	model  string // 为空时使用请求中的模型
+}
+
+// chatStream 流式回复：故障切换时为确认服务可用预读的数据块会在 Recv 时先返回
+type chatStream struct {
+	*openai.ChatCompletionStream
+	Provider string // 实际提供服务的提供方名称
+	Model    string // 实际使用的模型
+	buffered []openai.ChatCompletionStreamResponse
+	err      error // 预读时遇到的流结束或错误，缓冲的数据块返回完后返回
+	cancel   context.CancelCauseFunc
+	// 估算的提示词 token 数，服务提供方未返回用量时使用
+	promptTokens int
+}
+
+// Close 关闭流并释放请求上下文
+func (s *chatStream) Close() error {
+	err := s.ChatCompletionStream.Close()
+	s.cancel(nil)
+	return err
+}
+
+// Recv 接收下一个数据块
+func (s *chatStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(s.buffered) > 0 {
+		response := s.buffered[0]
+		s.buffered = s.buffered[1:]
+		return response, nil
+	}
+	if s.err != nil {
+		return openai.ChatCompletionStreamResponse{}, s.err
+	}
+	return s.ChatCompletionStream.Recv()
+}
+
+// isRetryableAIError 判断是否应切换到下一个提供方：连接错误、5xx、429、熔断和首个数据块超时；请求取消或超时时不再切换
+func isRetryableAIError(err error) bool {
+	if errors.Is(err, errFirstTokenTimeout) || errors.Is(err, resilience.ErrCircuitOpen) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode >= 500 || apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode >= 500 || reqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// createChatCompletion 依次尝试各提供方生成回复，返回回复和实际提供服务的提供方
+func (ai *AIService) createChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, *aiProvider, error) {
+	requested := req.Model
+	lastErr := errNoProviderForModel(requested)
+	for i, p := range ai.providers {
+		modelName, ok := p.modelFor(requested, ai.model)
+		if !ok {
+			continue
+		}
+		req.Model = modelName
+		resp, err := p.client.CreateChatCompletion(ctx, req)
+		if err == nil {
+			if i > 0 {
+				logger.Info("AI 服务已切换到 %s（模型 %s）", p.name, req.Model)
+			}
+			return resp, p, nil
+		}
+		lastErr = err
+		// 请求已取消或超时时，后续提供方同样无法完成
+		if ctx.Err() != nil || !isRetryableAIError(err) {
+			break
+		}
+		ai.logFailover(i, err)
+	}
+	return openai.ChatCompletionResponse{}, nil, lastErr
+}
+
+// createChatCompletionStream 依次尝试各提供方创建流式回复；收到第一个有效数据块前失败或超时也会切换到下一个提供方
+func (ai *AIService) createChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (*chatStream, error) {
+	requested := req.Model
+	lastErr := errNoProviderForModel(requested)
+	for i, p := range ai.providers {
+		modelName, ok := p.modelFor(requested, ai.model)
+		if !ok {
+			continue
+		}
+		req.Model = modelName
+
+		// 每个提供方单独计算首个数据块超时，收到首个数据块后不再限制
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		var timer *time.Timer
+		if ai.firstToken > 0 {
+			timer = time.AfterFunc(ai.firstToken, func() { cancel(errFirstTokenTimeout) })
+		}
+
+		stream, err := p.client.CreateChatCompletionStream(streamCtx, req)
+		if err == nil {
+			cs := &chatStream{ChatCompletionStream: stream, Provider: p.name, Model: req.Model, cancel: cancel}
+			err = cs.prefetch()
+			if timer != nil {
+				timer.Stop()
+			}
+			if err == nil && !errors.Is(context.Cause(streamCtx), errFirstTokenTimeout) {
+				if i > 0 {
+					logger.Info("AI 流式服务已切换到 %s（模型 %s）", p.name, req.Model)
+				}
+				return cs, nil
+			}
+			stream.Close()
+		} else if timer != nil {
+			timer.Stop()
+		}
+		if errors.Is(context.Cause(streamCtx), errFirstTokenTimeout) {
+			err = fmt.Errorf("%w（%s）", errFirstTokenTimeout, ai.firstToken)
+		}
+		cancel(nil)
+		lastErr = err
+		// 请求已取消或超时时，后续提供方同样无法完成
+		if ctx.Err() != nil || !isRetryableAIError(err) {
+			break
+		}
+		ai.logFailover(i, err)
+	}
+	return nil, lastErr
+}
+
+// prefetch 预读数据块直到收到第一个有效内容，期间出错时返回错误以便切换提供方；流正常结束不算错误
+func (s *chatStream) prefetch() error {
+	for {
+		response, err := s.ChatCompletionStream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.err = err
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.buffered = append(s.buffered, response)
+		if len(response.Choices) > 0 {
+			choice := response.Choices[0]
+			if choice.Delta.Content != "" || choice.Delta.ReasoningContent != "" || len(choice.Delta.ToolCalls) > 0 || choice.FinishReason != "" {
+				return nil
+			}
+		}
+	}
+}
+
+// logFailover 记录提供方失败
+func (ai *AIService) logFailover(i int, err error) {
+	if i+1 < len(ai.providers) {
+		logger.Warn("AI 服务 %s 调用失败，切换到 %s: %v", ai.providers[i].name, ai.providers[i+1].name, err)
+	} else {
+		logger.Error("AI 服务 %s 调用失败，没有可用的备用服务: %v", ai.providers[i].name, err)
+	}
+}
+
+// modelFor 确定在该提供方上使用的模型：请求使用默认模型 defaultModel 时替换为提供方配置的模型，
+// 请求选择了其他模型时只有未配置模型或配置了同一模型的提供方可以提供服务，否则返回 false
+func (p *aiProvider) modelFor(requested, defaultModel string) (string, bool) {
+	switch {
+	case p.model == "" || p.model == requested:
+		return requested, true
+	case requested == defaultModel:
+		return p.model, true
+	default:
+		return "", false
+	}
+}
+
+// servingModel 确定请求的模型在第一个可以提供服务的提供方上实际使用的模型，没有提供方可以提供服务时返回 false
+func (ai *AIService) servingModel(requested string) (string, bool) {
+	for _, p := range ai.providers {
+		if modelName, ok := p.modelFor(requested, ai.model); ok {
+			return modelName, true
+		}
+	}
+	return "", false
+}
+
+// errNoProviderForModel 没有提供方可以提供请求的模型
+func errNoProviderForModel(requested string) error {
+	return fmt.Errorf("%w: 没有可以提供模型 %s 的 AI 服务", ErrModelNotAllowed, requested)
+}
@@ -1,79 +1,430 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"knowledge-maker/internal/config"
 	"knowledge-maker/internal/logger"
 	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/prompt"
+	"knowledge-maker/internal/segment"
+
+	"github.com/sashabaranov/go-openai"
 )
 
+// ErrConversationDisabled 未配置数据库时无法使用服务端会话
+var ErrConversationDisabled = errors.New("会话功能未启用")
+
+// cachedAnswerChunkRunes 流式重放缓存回答时每段的字符数
+const cachedAnswerChunkRunes = 16
+
 // RAGService RAG 服务，整合知识库和 AI
 type RAGService struct {
-	knowledgeService *KnowledgeService
-	aiService        *AIService
-	config           *config.Config
+	knowledgeService    KnowledgeRetriever
+	rerankService       *RerankService
+	aiService           *AIService
+	conversationService *ConversationService
+	answerCache         *answerCache // 语义答案缓存，未启用时为 nil
+	budget              *promptBudget
+	templates           *prompt.Templates
+	config              *config.Config
+}
+
+// NewRAGService 创建 RAG 服务实例，conversationService 为 nil 时不支持服务端会话，rerankService 为 nil 时不进行重排序
+func NewRAGService(knowledgeService KnowledgeRetriever, rerankService *RerankService, aiService *AIService, conversationService *ConversationService, templates *prompt.Templates, cfg *config.Config) (*RAGService, error) {
+	rs := &RAGService{
+		knowledgeService:    knowledgeService,
+		rerankService:       rerankService,
+		aiService:           aiService,
+		conversationService: conversationService,
+		budget:              newPromptBudget(cfg),
+		templates:           templates,
+		config:              cfg,
+	}
+	if err := rs.validateNoKnowledgePolicy(); err != nil {
+		return nil, err
+	}
+	if cfg.RAG.NoKnowledge.Policy != noKnowledgeAnswer {
+		logger.Info("未检索到相关知识时的处理策略: %s", cfg.RAG.NoKnowledge.Policy)
+	}
+	if cfg.RAG.AnswerCache.Enabled {
+		rs.answerCache = newAnswerCache(&cfg.RAG.AnswerCache, aiService)
+		logger.Info("语义答案缓存已启用，相似度阈值: %.2f，容量: %d，有效期: %d 秒", cfg.RAG.AnswerCache.Threshold, cfg.RAG.AnswerCache.Size, cfg.RAG.AnswerCache.TTL)
+	}
+	return rs, nil
+}
+
+// InvalidateAnswerCache 知识库内容变更后清除检索过该知识库的缓存回答
+func (rs *RAGService) InvalidateAnswerCache(name string) {
+	if rs.answerCache == nil {
+		return
+	}
+	if n := rs.answerCache.PurgeBase(name); n > 0 {
+		logger.Info("知识库 %s 内容已变更，清除缓存回答: %d", name, n)
+	}
+}
+
+// PurgeAnswerCache 清除缓存回答，name 为空时清除全部，返回清除数量
+func (rs *RAGService) PurgeAnswerCache(name string) int {
+	if rs.answerCache == nil {
+		return 0
+	}
+	if name == "" {
+		return rs.answerCache.Purge()
+	}
+	return rs.answerCache.PurgeBase(name)
+}
+
+// ReloadPrompts 重新加载提示词模板，成功后清除按旧模板生成的缓存回答
+func (rs *RAGService) ReloadPrompts() error {
+	if err := rs.templates.Reload(); err != nil {
+		logger.Error("重新加载提示词模板失败，继续使用原模板: %v", err)
+		return err
+	}
+	logger.Info("提示词模板已重新加载")
+	if n := rs.PurgeAnswerCache(""); n > 0 {
+		logger.Info("提示词模板已变更，清除缓存回答: %d", n)
+	}
+	return nil
 }
 
-// NewRAGService 创建 RAG 服务实例
-func NewRAGService(knowledgeService *KnowledgeService, aiService *AIService, cfg *config.Config) *RAGService {
-	return &RAGService{
-		knowledgeService: knowledgeService,
-		aiService:        aiService,
-		config:           cfg,
+// AnswerCacheStats 获取语义答案缓存统计
+func (rs *RAGService) AnswerCacheStats() model.CacheStats {
+	if rs.answerCache == nil {
+		return model.CacheStats{}
+	}
+	return rs.answerCache.Stats()
+}
+
+// lookupAnswerCache 单轮问答时查找语义答案缓存；返回命中的回答，以及是否可以缓存本次回答和已计算的问题向量
+func (rs *RAGService) lookupAnswerCache(ctx context.Context, req model.ChatRequest, history []model.ChatMessage, modelName string) (hit *cachedAnswer, cacheable bool, vector []float32) {
+	// 多轮对话的回答依赖上下文，指定了生成参数的回答与默认参数不同，都不使用缓存
+	if rs.answerCache == nil || len(history) > 0 || !req.Generation.IsZero() {
+		return nil, false, nil
+	}
+	if req.NoCache {
+		logger.Info("请求跳过语义答案缓存")
+		return nil, true, nil
+	}
+
+	hit, vector = rs.answerCache.Lookup(ctx, answerCachePartition(modelName, req.Language, req.KnowledgeBases), req.Query)
+	if hit != nil {
+		logger.Info("命中语义答案缓存，相似度: %.4f，回答长度: %d", hit.Similarity, len(hit.Answer))
+	}
+	return hit, true, vector
+}
+
+// storeAnswerCache 缓存本次生成的回答
+func (rs *RAGService) storeAnswerCache(ctx context.Context, req model.ChatRequest, modelName string, vector []float32, answer, knowledgeContext string, sources []model.Source, bases []string) {
+	rs.answerCache.Store(ctx, answerCachePartition(modelName, req.Language, req.KnowledgeBases), req.Query, vector, answer, knowledgeContext, sources, bases)
+}
+
+// replayCachedAnswer 以流式响应的形式发送缓存的回答
+func (rs *RAGService) replayCachedAnswer(ctx context.Context, req model.ChatRequest, responseChan chan<- model.StreamContent, hit *cachedAnswer) {
+	events := []model.StreamContent{{Cached: true}}
+	if len(hit.Sources) > 0 {
+		events = append(events, model.StreamContent{Sources: hit.Sources})
+	}
+	rs.sendStaticAnswer(ctx, req, responseChan, events, hit.Answer, hit.KnowledgeContext)
+}
+
+// sendStaticAnswer 以流式响应的形式发送无需调用 AI 服务的回答，events 在回答开始前发送；
+// 客户端断开连接时停止发送，不保存会话
+func (rs *RAGService) sendStaticAnswer(ctx context.Context, req model.ChatRequest, responseChan chan<- model.StreamContent, events []model.StreamContent, answer, knowledgeContext string) {
+	// 按固定长度分段发送，与模型流式输出的体验保持一致
+	runes := []rune(answer)
+	for start := 0; start < len(runes); start += cachedAnswerChunkRunes {
+		end := min(start+cachedAnswerChunkRunes, len(runes))
+		events = append(events, model.StreamContent{Content: string(runes[start:end])})
+	}
+
+	for _, event := range events {
+		if !sendStream(ctx, responseChan, event) {
+			return
+		}
+	}
+	rs.saveTurn(req, answer, knowledgeContext)
+}
+
+// retrieve 在 retrievalCtx 时限内检索知识库，检索失败或没有结果时按 rag.no_knowledge 策略处理，备用知识库的检索时限
+// 由请求上下文 ctx 重新计算；返回知识片段、实际检索的知识库、采取的策略（检索到知识时为空），
+// 以及回答是否可以缓存（检索失败或回答没有知识依据时不缓存）
+func (rs *RAGService) retrieve(ctx, retrievalCtx context.Context, query string, bases []string) ([]model.KnowledgeChunk, []string, string, bool) {
+	chunks, err := rs.queryKnowledgeWithDetailedLogging(retrievalCtx, query, bases)
+	if err == nil && len(chunks) > 0 {
+		return chunks, bases, "", true
+	}
+	chunks, bases, policy := rs.handleNoKnowledge(ctx, query, bases, err)
+	// 只有备用知识库检索到片段时回答才有知识依据；answer 等策略下模型凭自身知识生成的回答不缓存
+	return chunks, bases, policy, err == nil && len(chunks) > 0
+}
+
+// retrieveForChat 在 timeouts.retrieval 时限内改写问题、确定知识库并检索；返回检索问题、知识库、知识片段、
+// 采取的策略和回答是否可以缓存。检索超时按检索失败处理，请求本身被取消或超时时返回错误
+func (rs *RAGService) retrieveForChat(ctx context.Context, req model.ChatRequest, history []model.ChatMessage) (string, []string, []model.KnowledgeChunk, string, bool, error) {
+	retrievalCtx, cancel := withTimeout(ctx, rs.config.Timeouts.Retrieval, "知识检索")
+	defer cancel()
+
+	searchQuery := rs.rewriteQuery(retrievalCtx, req.Query, history)
+	bases, err := rs.knowledgeService.ResolveKnowledgeBases(retrievalCtx, searchQuery, req.KnowledgeBases)
+	if err != nil {
+		if cause := contextError(ctx); cause != nil {
+			return "", nil, nil, "", false, cause
+		}
+		return "", nil, nil, "", false, err
+	}
+
+	chunks, bases, policy, grounded := rs.retrieve(ctx, retrievalCtx, searchQuery, bases)
+	if cause := contextError(ctx); cause != nil {
+		logger.Warn("知识检索已中止: %v", cause)
+		return "", nil, nil, "", false, cause
+	}
+	return searchQuery, bases, chunks, policy, grounded, nil
+}
+
+// sendStream 发送流式内容，ctx 取消（客户端断开连接或超时）时放弃发送并返回 false
+func sendStream(ctx context.Context, responseChan chan<- model.StreamContent, content model.StreamContent) bool {
+	select {
+	case responseChan <- content:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
 // queryKnowledgeWithDetailedLogging 统一的知识库查询方法，包含详细日志
-func (rs *RAGService) queryKnowledgeWithDetailedLogging(query string) (string, error) {
-	logger.Info("开始查询知识库，查询内容: %s", query)
-	
-	knowledgeContext, err := rs.knowledgeService.QueryKnowledge(query)
+func (rs *RAGService) queryKnowledgeWithDetailedLogging(ctx context.Context, query string, bases []string) ([]model.KnowledgeChunk, error) {
+	query = segment.Normalize(query)
+	logger.Info("开始查询知识库，查询内容: %s，知识库: %s", query, strings.Join(bases, ", "))
+	logger.Info("查询关键词: %s", strings.Join(segment.Keywords(query), " "))
+
+	var (
+		chunks []model.KnowledgeChunk
+		err    error
+	)
+	if rs.rerankService != nil {
+		// 多取候选片段，重排序后过滤低相关片段
+		chunks, err = rs.knowledgeService.QueryKnowledgeBases(ctx, bases, query, rs.rerankService.Candidates())
+		if err == nil {
+			chunks = rs.rerankService.Rerank(ctx, query, chunks)
+		}
+	} else {
+		chunks, err = rs.knowledgeService.QueryKnowledgeBases(ctx, bases, query, 0)
+	}
 	if err != nil {
 		logger.Error("知识库查询失败: %v", err)
-		return "", err
+		return nil, err
 	}
-	
+
 	// 记录详细的知识库内容
-	if knowledgeContext != "" {
-		logger.Info("知识库查询成功，上下文长度: %d", len(knowledgeContext))
-		
-		// 记录知识库内容的前200个字符作为预览
-		preview := knowledgeContext
-		if len(preview) > 200 {
-			preview = preview[:200] + "..."
+	if len(chunks) > 0 {
+		logger.Info("知识库查询成功，片段数: %d", len(chunks))
+
+		for i, chunk := range chunks {
+			// 记录每个片段内容的前100个字符作为预览
+			preview := []rune(chunk.Content)
+			if len(preview) > 100 {
+				preview = append(preview[:100], []rune("...")...)
+			}
+			logger.Info("知识片段 [%d] 知识库: %s，标题: %s，来源: %s，分数: %.4f，预览: %s", i+1, chunk.KnowledgeBase, chunk.Title, chunk.URL, chunk.Score, string(preview))
 		}
-		logger.Info("知识库内容预览: %s", preview)
-		
-		// 记录完整的知识库内容到日志
-		logger.Info("=== 知识库完整内容开始 ===")
-		logger.Info("查询: %s", query)
-		logger.Info(fmt.Sprintf("限制查询最优匹配: %d", rs.config.Knowledge.TopK))
-		logger.Info("=== 知识库完整内容结束 ===")
+
+		logger.Info("查询: %s，限制查询最优匹配: %d", query, rs.config.Knowledge.TopK)
 	} else {
 		logger.Info("知识库查询结果为空")
 	}
-	
-	return knowledgeContext, nil
+
+	return chunks, nil
+}
+
+// limitHistory 按配置裁剪历史对话：只保留 user/assistant 消息，限制轮数和总字符数；
+// rag.max_history_turns 为负数时不携带历史，rag.max_history_chars 为负数时不限制字符数
+func (rs *RAGService) limitHistory(history []model.ChatMessage) []model.ChatMessage {
+	if len(history) == 0 || rs.config.RAG.MaxHistoryTurns < 0 {
+		return nil
+	}
+
+	// 过滤掉非法角色和空消息，避免客户端伪造 system 消息
+	var valid []model.ChatMessage
+	for _, msg := range history {
+		if msg.Content == "" {
+			continue
+		}
+		if msg.Role != openai.ChatMessageRoleUser && msg.Role != openai.ChatMessageRoleAssistant {
+			continue
+		}
+		valid = append(valid, msg)
+	}
+
+	// 从后往前保留，直到超过轮数或字符数限制
+	maxTurns := rs.config.RAG.MaxHistoryTurns
+	maxChars := rs.config.RAG.MaxHistoryChars
+	start := len(valid)
+	turns := 0
+	chars := 0
+	for i := len(valid) - 1; i >= 0; i-- {
+		msgChars := utf8.RuneCountInString(valid[i].Content)
+		if maxChars > 0 && chars+msgChars > maxChars {
+			break
+		}
+		// 每遇到一条 user 消息算作一轮的开始
+		if valid[i].Role == openai.ChatMessageRoleUser {
+			if maxTurns > 0 && turns >= maxTurns {
+				break
+			}
+			turns++
+		}
+		chars += msgChars
+		start = i
+	}
+
+	// 保证历史以 user 消息开头，避免截断后出现孤立的 assistant 回复
+	for start < len(valid) && valid[start].Role != openai.ChatMessageRoleUser {
+		start++
+	}
+
+	if start < len(valid) || len(valid) < len(history) {
+		logger.Info("历史对话已裁剪，原始消息数: %d，保留消息数: %d，字符数: %d", len(history), len(valid)-start, chars)
+	}
+
+	return valid[start:]
 }
 
-// ProcessChat 处理聊天请求的核心逻辑
-func (rs *RAGService) ProcessChat(query string) (*model.ChatResponse, error) {
-	logger.Info("收到用户查询: %s", query)
+// rewriteQuery 多轮对话时将追问改写为独立的检索问题，失败或无需改写时返回原问题
+func (rs *RAGService) rewriteQuery(ctx context.Context, query string, history []model.ChatMessage) string {
+	if !rs.config.RAG.QueryRewrite || len(history) == 0 {
+		return query
+	}
 
-	// 1. 查询知识库
-	knowledgeContext, err := rs.queryKnowledgeWithDetailedLogging(query)
+	rewritten, err := rs.aiService.RewriteQuery(ctx, rs.config.RAG.QueryRewritePrompt, query, history)
 	if err != nil {
-		logger.Error("知识库查询失败: %v", err)
-		// 知识库查询失败时，仍然可以使用 AI 直接回答
-		knowledgeContext = ""
+		logger.Error("检索问题改写失败，使用原问题检索: %v", err)
+		return query
+	}
+	if rewritten == "" {
+		logger.Warn("检索问题改写结果为空，使用原问题检索")
+		return query
 	}
 
-	// 2. 构建系统提示词
-	systemPrompt := rs.getSystemPrompt()
+	logger.Info("检索问题改写完成，原问题: %s，改写后: %s", query, rewritten)
+	return rewritten
+}
+
+// resolveHistory 确定本次请求使用的历史对话：优先使用服务端会话中保存的历史
+func (rs *RAGService) resolveHistory(req model.ChatRequest) ([]model.ChatMessage, error) {
+	if req.ConversationID == "" {
+		return rs.limitHistory(req.History), nil
+	}
 
-	// 3. 调用 AI 生成回复
-	answer, err := rs.aiService.GenerateResponse(systemPrompt, query, knowledgeContext)
+	if rs.conversationService == nil {
+		return nil, ErrConversationDisabled
+	}
+
+	history, err := rs.conversationService.GetHistory(req.ClientID, req.ConversationID)
+	if err != nil {
+		logger.Error("加载会话历史失败: %v", err)
+		return nil, err
+	}
+	if len(req.History) > 0 {
+		logger.Info("请求指定了会话 %s，忽略客户端传入的 %d 条历史消息", req.ConversationID, len(req.History))
+	}
+
+	return rs.limitHistory(history), nil
+}
+
+// saveTurn 将本轮问答追加到服务端会话
+func (rs *RAGService) saveTurn(req model.ChatRequest, answer, knowledgeContext string) {
+	if req.ConversationID == "" || rs.conversationService == nil {
+		return
+	}
+	if err := rs.conversationService.AppendTurn(req.ConversationID, req.Query, answer, knowledgeContext); err != nil {
+		logger.Error("保存会话 %s 失败: %v", req.ConversationID, err)
+	}
+}
+
+// ProcessChat 处理聊天请求的核心逻辑，ctx 取消（客户端断开连接）或超过 timeouts.total 时中止
+func (rs *RAGService) ProcessChat(ctx context.Context, req model.ChatRequest) (*model.ChatResponse, error) {
+	ctx, cancel := withTimeout(ctx, rs.config.Timeouts.Total, "请求总耗时")
+	defer cancel()
+
+	query := req.Query
+	logger.Info("收到用户查询: %s，会话: %s，历史消息数: %d", query, req.ConversationID, len(req.History))
+	if req.Language == "" {
+		req.Language = prompt.DetectLanguage(query)
+	}
+
+	history, err := rs.resolveHistory(req)
+	if err != nil {
+		return &model.ChatResponse{
+			Success: false,
+			Message: err.Error(),
+		}, err
+	}
+	opts, err := rs.aiService.ResolveChat(req.Model, req.Generation)
+	if err != nil {
+		return &model.ChatResponse{
+			Success: false,
+			Message: err.Error(),
+		}, err
+	}
+
+	// 1. 单轮问答先查找语义答案缓存，缓存按预期实际使用的模型区分
+	servingModel, _ := rs.aiService.servingModel(opts.Model)
+	hit, cacheable, queryVector := rs.lookupAnswerCache(ctx, req, history, servingModel)
+	if hit != nil {
+		rs.saveTurn(req, hit.Answer, hit.KnowledgeContext)
+		return &model.ChatResponse{
+			Success:          true,
+			Answer:           hit.Answer,
+			KnowledgeContext: hit.KnowledgeContext,
+			ConversationID:   req.ConversationID,
+			Sources:          hit.Sources,
+			Cached:           true,
+		}, nil
+	}
+
+	// 2. 改写问题、确定知识库并检索，检索失败、超时或没有结果时按 rag.no_knowledge 策略处理
+	searchQuery, bases, chunks, policy, grounded, err := rs.retrieveForChat(ctx, req, history)
+	if err != nil {
+		return &model.ChatResponse{
+			Success: false,
+			Message: err.Error(),
+		}, err
+	}
+	cacheable = cacheable && grounded
+	if policy == noKnowledgeRefuse {
+		answer := rs.config.RAG.NoKnowledge.Message
+		rs.saveTurn(req, answer, "")
+		response := &model.ChatResponse{
+			Success:        true,
+			Answer:         answer,
+			ConversationID: req.ConversationID,
+			NoKnowledge:    policy,
+		}
+		if searchQuery != query {
+			response.RewrittenQuery = searchQuery
+		}
+		return response, nil
+	}
+
+	// 3. 按模板渲染提示词，按模型上下文窗口裁剪知识片段和历史对话
+	p := rs.buildPrompt(req, bases, chunks, history, opts)
+	knowledgeContext, sources := p.Data.Context, p.Data.Sources
+
+	// 4. 调用 AI 生成回复
+	answer, provider, modelName, err := rs.aiService.GenerateResponse(ctx, p.System, p.User, p.Data.History, opts)
+	if cause := contextError(ctx); cause != nil {
+		logger.Warn("AI 生成回复已中止: %v", cause)
+		return &model.ChatResponse{
+			Success: false,
+			Message: cause.Error(),
+		}, cause
+	}
 	if err != nil {
 		logger.Error("AI 生成回复失败: %v", err)
 		return &model.ChatResponse{
@@ -83,54 +434,215 @@ func (rs *RAGService) ProcessChat(query string) (*model.ChatResponse, error) {
 	}
 
 	logger.Info("AI 回复生成成功，长度: %d", len(answer))
+	answer = rs.answerPrefix(policy) + answer
+
+	// 5. 保存到会话和答案缓存
+	rs.saveTurn(req, answer, knowledgeContext)
+	if cacheable {
+		rs.storeAnswerCache(ctx, req, modelName, queryVector, answer, knowledgeContext, sources, bases)
+	}
 
-	// 4. 返回结果
+	// 6. 返回结果
 	response := &model.ChatResponse{
-		Success: true,
-		Answer:  answer,
+		Success:          true,
+		Answer:           answer,
+		KnowledgeContext: knowledgeContext,
+		ConversationID:   req.ConversationID,
+		Sources:          sources,
+		Provider:         provider,
+		NoKnowledge:      policy,
+	}
+	if searchQuery != query {
+		response.RewrittenQuery = searchQuery
 	}
 
 	return response, nil
 }
 
-// ProcessStreamChat 处理流式聊天请求的核心逻辑
-func (rs *RAGService) ProcessStreamChat(query string) (chan model.StreamContent, chan error, error) {
-	logger.Info("收到流式查询: %s", query)
+// ProcessStreamChat 处理流式聊天请求的核心逻辑：参数校验失败时直接返回错误，其余步骤在协程中执行，
+// 各阶段开始和结束时发送进度事件；ctx 取消（客户端断开连接）或超过 timeouts.total 时中止生成并关闭上游的流式响应
+func (rs *RAGService) ProcessStreamChat(ctx context.Context, req model.ChatRequest) (chan model.StreamContent, chan error, error) {
+	query := req.Query
+	logger.Info("收到流式查询: %s，会话: %s，历史消息数: %d", query, req.ConversationID, len(req.History))
+	if req.Language == "" {
+		req.Language = prompt.DetectLanguage(query)
+	}
 
-	// 1. 先同步查询知识库（因为很快，2秒内完成）
-	knowledgeContext, err := rs.queryKnowledgeWithDetailedLogging(query)
+	history, err := rs.resolveHistory(req)
 	if err != nil {
-		logger.Error("知识库查询失败: %v", err)
-		knowledgeContext = ""
+		return nil, nil, err
 	}
-
-	// 2. 构建系统提示词
-	systemPrompt := rs.getSystemPrompt()
-
-	// 3. 立即获取流式响应
-	logger.Info("准备调用 AI 流式服务")
-	stream, err := rs.aiService.GenerateStreamResponse(systemPrompt, query, knowledgeContext)
+	opts, err := rs.aiService.ResolveChat(req.Model, req.Generation)
 	if err != nil {
-		logger.Error("AI 流式生成失败: %v", err)
-		return nil, nil, fmt.Errorf("AI 服务暂时不可用，请稍后重试")
+		return nil, nil, err
 	}
-	logger.Info("AI 流式服务调用成功，开始处理响应")
 
-	// 4. 创建通道
 	responseChan := make(chan model.StreamContent, 1)
 	errorChan := make(chan error, 1)
 
-	// 5. 启动协程处理流式响应
 	go func() {
+		ctx, cancel := withTimeout(ctx, rs.config.Timeouts.Total, "请求总耗时")
+		defer cancel()
 		defer close(responseChan)
 		defer close(errorChan)
-		rs.aiService.ProcessStreamResponse(stream, responseChan, errorChan, query, knowledgeContext)
+
+		if err := rs.streamChat(ctx, req, history, opts, responseChan); err != nil {
+			errorChan <- err
+		}
 	}()
 
 	return responseChan, errorChan, nil
 }
 
-// getSystemPrompt 获取系统提示词
-func (rs *RAGService) getSystemPrompt() string {
-	return rs.config.RAG.SystemPrompt
+// streamChat 依次执行缓存查找、检索和生成，将进度事件和回答发送到 responseChan；完整回答结束后保存到会话
+func (rs *RAGService) streamChat(ctx context.Context, req model.ChatRequest, history []model.ChatMessage, opts ChatOptions, responseChan chan<- model.StreamContent) error {
+	query := req.Query
+
+	// 1. 单轮问答命中语义答案缓存时直接重放缓存的回答，缓存按预期实际使用的模型区分
+	servingModel, _ := rs.aiService.servingModel(opts.Model)
+	hit, cacheable, queryVector := rs.lookupAnswerCache(ctx, req, history, servingModel)
+	if hit != nil {
+		rs.replayCachedAnswer(ctx, req, responseChan, hit)
+		return nil
+	}
+
+	// 2. 改写问题、确定知识库并检索，检索失败、超时或没有结果时按 rag.no_knowledge 策略处理
+	if !sendStream(ctx, responseChan, model.StreamContent{Progress: &model.StreamProgress{Stage: model.StageRetrievalStarted}}) {
+		return nil
+	}
+	started := time.Now()
+	searchQuery, bases, chunks, policy, grounded, err := rs.retrieveForChat(ctx, req, history)
+	if err != nil {
+		return err
+	}
+	cacheable = cacheable && grounded
+
+	// 检索结果和未检索到知识时采取的策略在回答开始前发送
+	events := []model.StreamContent{{Progress: &model.StreamProgress{
+		Stage:          model.StageRetrievalDone,
+		Chunks:         len(chunks),
+		KnowledgeBases: bases,
+		Latency:        time.Since(started),
+	}}}
+	if searchQuery != query {
+		events = append(events, model.StreamContent{RewrittenQuery: searchQuery})
+	}
+	if policy != "" {
+		events = append(events, model.StreamContent{NoKnowledge: policy})
+	}
+	if policy == noKnowledgeRefuse {
+		rs.sendStaticAnswer(ctx, req, responseChan, events, rs.config.RAG.NoKnowledge.Message, "")
+		return nil
+	}
+
+	// 3. 按模板渲染提示词，按模型上下文窗口裁剪知识片段和历史对话，参考来源在生成前发送
+	p := rs.buildPrompt(req, bases, chunks, history, opts)
+	knowledgeContext, sources := p.Data.Context, p.Data.Sources
+	if len(sources) > 0 {
+		events = append(events, model.StreamContent{Sources: sources})
+	}
+	for _, event := range events {
+		if !sendStream(ctx, responseChan, event) {
+			return nil
+		}
+	}
+
+	// 4. 调用 AI 流式服务，等待首个数据块
+	logger.Info("准备调用 AI 流式服务")
+	stream, err := rs.aiService.GenerateStreamResponse(ctx, p.System, p.User, p.Data.History, opts)
+	if cause := contextError(ctx); cause != nil {
+		logger.Warn("AI 流式生成已中止: %v", cause)
+		return cause
+	}
+	if err != nil {
+		logger.Error("AI 流式生成失败: %v", err)
+		return fmt.Errorf("AI 服务暂时不可用，请稍后重试")
+	}
+	logger.Info("AI 流式服务调用成功，开始处理响应")
+
+	// 生成开始事件报告实际使用的模型（故障切换后可能是备用提供方配置的模型）
+	generationStarted := model.StreamContent{Progress: &model.StreamProgress{Stage: model.StageGenerationStarted, Model: stream.Model}}
+	if !sendStream(ctx, responseChan, generationStarted) || !sendStream(ctx, responseChan, model.StreamContent{Provider: stream.Provider}) {
+		stream.Close()
+		return nil
+	}
+
+	// 5. 转发流式回答
+	answer, err := rs.aiService.ProcessStreamResponse(ctx, stream, responseChan, query, knowledgeContext, rs.answerPrefix(policy))
+	if err != nil {
+		return err
+	}
+	rs.saveTurn(req, answer, knowledgeContext)
+	if cacheable {
+		rs.storeAnswerCache(ctx, req, stream.Model, queryVector, answer, knowledgeContext, sources, bases)
+	}
+	return nil
+}
+
+// renderedPrompt 按模板渲染的提示词及使用的模板变量
+type renderedPrompt struct {
+	System string
+	User   string
+	Data   prompt.Data
+}
+
+// buildPrompt 按模板渲染系统提示词和用户消息：先用全部片段和历史估算模板本身的开销，
+// 按模型上下文窗口裁剪后再用保留的片段和历史重新渲染
+func (rs *RAGService) buildPrompt(req model.ChatRequest, bases []string, chunks []model.KnowledgeChunk, history []model.ChatMessage, opts ChatOptions) renderedPrompt {
+	data := promptData(req, bases, chunks, history)
+	systemPrompt := rs.renderSystemPrompt(data)
+	var userFrame string
+	var err error
+	if len(chunks) > 0 {
+		userFrame, err = rs.templates.ContextFrame(data)
+	} else {
+		userFrame, err = rs.templates.User(data)
+	}
+	if err != nil {
+		userFrame = req.Query
+	}
+
+	chunks, history = rs.budget.Fit(opts.Model, opts.Generation.MaxTokens, systemPrompt, userFrame, chunks, history)
+	data = promptData(req, bases, chunks, history)
+	return renderedPrompt{
+		System: rs.renderSystemPrompt(data),
+		User:   rs.renderUserMessage(data),
+		Data:   data,
+	}
+}
+
+// promptData 构建模板变量
+func promptData(req model.ChatRequest, bases []string, chunks []model.KnowledgeChunk, history []model.ChatMessage) prompt.Data {
+	return prompt.Data{
+		Query:          req.Query,
+		Chunks:         chunks,
+		Sources:        buildSources(chunks),
+		Context:        formatKnowledgeContext(chunks),
+		History:        history,
+		KnowledgeBases: bases,
+		Language:       req.Language,
+	}
+}
+
+// renderSystemPrompt 渲染系统提示词，模板执行失败时使用 rag.system_prompt
+func (rs *RAGService) renderSystemPrompt(data prompt.Data) string {
+	systemPrompt, err := rs.templates.System(data)
+	if err != nil {
+		logger.Error("渲染系统提示词模板失败，使用默认系统提示词: %v", err)
+		return rs.config.RAG.SystemPrompt
+	}
+	return systemPrompt
+}
+
+// renderUserMessage 渲染用户消息，模板执行失败时直接拼接知识库内容和问题
+func (rs *RAGService) renderUserMessage(data prompt.Data) string {
+	userMessage, err := rs.templates.User(data)
+	if err != nil {
+		logger.Error("渲染用户消息模板失败，使用原始问题: %v", err)
+		if data.Context == "" {
+			return data.Query
+		}
+		return data.Context + "\n\n" + data.Query
+	}
+	return userMessage
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/service/reranker"
+)
+
+// RerankService 重排序服务，在检索和生成之间对候选片段重新排序并过滤低相关片段
+type RerankService struct {
+	reranker reranker.Reranker
+	config   *config.Config
+}
+
+// NewRerankService 创建重排序服务，未配置重排序类型时返回 nil
+func NewRerankService(cfg *config.Config, completer reranker.Completer) (*RerankService, error) {
+	if cfg.Rerank.Type == "" || cfg.Rerank.Type == "none" {
+		return nil, nil
+	}
+
+	r, err := reranker.New(&cfg.Rerank, &reranker.Dependencies{Completer: completer})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("重排序已启用，类型: %s，候选数: %d，最低相关度: %.2f", r.GetType(), cfg.Rerank.Candidates, cfg.Rerank.MinScore)
+	return &RerankService{
+		reranker: r,
+		config:   cfg,
+	}, nil
+}
+
+// Candidates 重排序前需要检索的候选片段数量
+func (rs *RerankService) Candidates() int {
+	return rs.config.Rerank.Candidates
+}
+
+// Rerank 对候选片段重排序，丢弃低于最低相关度的片段并保留前 top_n 个；
+// 重排序失败时按原顺序保留前 top_n 个片段
+func (rs *RerankService) Rerank(ctx context.Context, query string, chunks []model.KnowledgeChunk) []model.KnowledgeChunk {
+	topN := rs.config.Rerank.TopN
+	if topN <= 0 {
+		topN = rs.config.Knowledge.TopK
+	}
+	if len(chunks) == 0 {
+		return chunks
+	}
+
+	documents := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		documents[i] = chunk.Content
+		if chunk.Title != "" {
+			documents[i] = chunk.Title + "\n" + chunk.Content
+		}
+	}
+
+	scores, err := rs.reranker.Rerank(ctx, query, documents)
+	if err != nil {
+		logger.Warn("重排序失败，使用原始检索顺序: %v", err)
+		if len(chunks) > topN {
+			chunks = chunks[:topN]
+		}
+		return chunks
+	}
+
+	reranked := make([]model.KnowledgeChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		if scores[i] < rs.config.Rerank.MinScore {
+			continue
+		}
+		chunk.Score = scores[i]
+		reranked = append(reranked, chunk)
+	}
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	if len(reranked) > topN {
+		reranked = reranked[:topN]
+	}
+
+	logger.Info("重排序完成，候选片段: %d，低相关丢弃: %d，保留: %d", len(chunks), len(chunks)-countAbove(scores, rs.config.Rerank.MinScore), len(reranked))
+	return reranked
+}
+
+// countAbove 统计不低于阈值的分数个数
+func countAbove(scores []float64, threshold float64) int {
+	n := 0
+	for _, score := range scores {
+		if score >= threshold {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,129 @@
+package reranker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"knowledge-maker/internal/config"
+)
+
+func init() {
+	Register("http", NewHTTPReranker)
+}
+
+// HTTPReranker 通用 HTTP 重排序器，兼容常见的 /rerank 接口格式
+// 请求: {"model": "...", "query": "...", "documents": ["..."], "top_n": 10}
+// 响应: {"results": [{"index": 0, "relevance_score": 0.98}]}
+type HTTPReranker struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// rerankRequest 重排序请求
+type rerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// rerankResult 单个文档的重排序结果
+type rerankResult struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+// rerankResponse 重排序响应，部分服务使用 data 字段返回结果
+type rerankResponse struct {
+	Results []rerankResult `json:"results"`
+	Data    []rerankResult `json:"data"`
+}
+
+// NewHTTPReranker 创建 HTTP 重排序器
+func NewHTTPReranker(cfg *config.RerankConfig, deps *Dependencies) (Reranker, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("重排序服务未配置 base_url")
+	}
+
+	return &HTTPReranker{
+		baseURL: cfg.BaseURL,
+		apiKey:  cfg.APIKey,
+		model:   cfg.Model,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+		},
+	}, nil
+}
+
+// Rerank 调用重排序接口计算相关度
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	jsonData, err := json.Marshal(rerankRequest{
+		Model:     r.model,
+		Query:     query,
+		Documents: documents,
+		TopN:      len(documents),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("重排序服务返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	var result rerankResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	results := result.Results
+	if len(results) == 0 {
+		results = result.Data
+	}
+
+	// 未返回的文档视为不相关
+	scores := make([]float64, len(documents))
+	for _, item := range results {
+		if item.Index < 0 || item.Index >= len(documents) {
+			continue
+		}
+		switch {
+		case item.RelevanceScore != nil:
+			scores[item.Index] = *item.RelevanceScore
+		case item.Score != nil:
+			scores[item.Index] = *item.Score
+		}
+	}
+	return scores, nil
+}
+
+// GetType 获取类型
+func (r *HTTPReranker) GetType() string {
+	return "http"
+}
@@ -0,0 +1,91 @@
+package reranker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"knowledge-maker/internal/config"
+)
+
+// llmMaxDocumentRunes 每个候选片段提交给模型的最大字符数
+const llmMaxDocumentRunes = 600
+
+// llmRerankPrompt LLM 打分提示词
+const llmRerankPrompt = `你是检索结果相关度评估器。根据用户问题，为每个编号的参考片段打分，分数为 0-10 的整数：
+10 表示片段直接回答了问题，5 表示部分相关，0 表示完全无关。
+只输出 JSON 数组，不要输出任何解释，格式如：[{"index": 1, "score": 8}, {"index": 2, "score": 0}]`
+
+func init() {
+	Register("llm", NewLLMReranker)
+}
+
+// LLMReranker 使用对话模型作为评判者为候选片段打分
+type LLMReranker struct {
+	completer Completer
+}
+
+// NewLLMReranker 创建 LLM 重排序器
+func NewLLMReranker(cfg *config.RerankConfig, deps *Dependencies) (Reranker, error) {
+	if deps == nil || deps.Completer == nil {
+		return nil, fmt.Errorf("LLM 重排序需要可用的 AI 服务")
+	}
+	return &LLMReranker{completer: deps.Completer}, nil
+}
+
+// Rerank 一次请求为全部候选片段打分
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("用户问题：%s\n\n", query))
+	for i, doc := range documents {
+		if runes := []rune(doc); len(runes) > llmMaxDocumentRunes {
+			doc = string(runes[:llmMaxDocumentRunes]) + "..."
+		}
+		b.WriteString(fmt.Sprintf("[%d]\n%s\n\n", i+1, doc))
+	}
+
+	reply, err := r.completer.Complete(ctx, llmRerankPrompt, b.String(), 30+len(documents)*20)
+	if err != nil {
+		return nil, fmt.Errorf("LLM 重排序失败: %v", err)
+	}
+
+	return parseLLMScores(reply, len(documents))
+}
+
+// parseLLMScores 解析模型输出的分数数组，未评分的片段视为不相关
+func parseLLMScores(reply string, n int) ([]float64, error) {
+	start := strings.Index(reply, "[")
+	end := strings.LastIndex(reply, "]")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("LLM 重排序结果格式错误: %s", reply)
+	}
+
+	var items []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &items); err != nil {
+		return nil, fmt.Errorf("解析 LLM 重排序结果失败: %v", err)
+	}
+
+	scores := make([]float64, n)
+	for _, item := range items {
+		if item.Index < 1 || item.Index > n {
+			continue
+		}
+		score := item.Score / 10
+		if score > 1 {
+			score = 1
+		} else if score < 0 {
+			score = 0
+		}
+		scores[item.Index-1] = score
+	}
+	return scores, nil
+}
+
+// GetType 获取类型
+func (r *LLMReranker) GetType() string {
+	return "llm"
+}
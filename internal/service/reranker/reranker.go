@@ -0,0 +1,48 @@
+package reranker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"knowledge-maker/internal/config"
+)
+
+// Reranker 重排序器接口：为每个候选文档计算与查询的相关度
+type Reranker interface {
+	// Rerank 返回与 documents 一一对应的相关度分数，范围 0-1
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+	// GetType 获取类型
+	GetType() string
+}
+
+// Completer 文本补全接口，由 AI 服务实现，供 LLM 重排序器使用
+type Completer interface {
+	// Complete 使用对话模型生成回复
+	Complete(ctx context.Context, systemPrompt, userPrompt string, maxTokens int) (string, error)
+}
+
+// Dependencies 重排序器可使用的公共依赖
+type Dependencies struct {
+	Completer Completer
+}
+
+// Factory 重排序器构造函数
+type Factory func(cfg *config.RerankConfig, deps *Dependencies) (Reranker, error)
+
+// factories 已注册的重排序器类型
+var factories = map[string]Factory{}
+
+// Register 注册重排序器类型，由各实现在 init 中调用
+func Register(rerankerType string, factory Factory) {
+	factories[strings.ToLower(rerankerType)] = factory
+}
+
+// New 根据配置创建重排序器
+func New(cfg *config.RerankConfig, deps *Dependencies) (Reranker, error) {
+	factory, ok := factories[strings.ToLower(cfg.Type)]
+	if !ok {
+		return nil, fmt.Errorf("不支持的重排序类型: %s", cfg.Type)
+	}
+	return factory(cfg, deps)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"knowledge-maker/internal/cache"
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/segment"
+)
+
+// retrievalCache 检索结果缓存，键由知识库名称、top_k 和规范化后的查询组成
+type retrievalCache struct {
+	lru    *cache.LRU[[]model.KnowledgeChunk]
+	config *config.CacheConfig
+}
+
+// newRetrievalCache 创建检索结果缓存，配置了持久化文件时加载上次保存的未过期条目
+func newRetrievalCache(cfg *config.CacheConfig) (*retrievalCache, error) {
+	rc := &retrievalCache{
+		lru:    cache.NewLRU[[]model.KnowledgeChunk](cfg.Size, time.Duration(cfg.TTL)*time.Second),
+		config: cfg,
+	}
+	if cfg.Path == "" {
+		return rc, nil
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if os.IsNotExist(err) {
+		return rc, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取检索缓存文件失败: %v", err)
+	}
+	var entries []cache.Entry[[]model.KnowledgeChunk]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析检索缓存文件失败: %v", err)
+	}
+	rc.lru.Restore(entries)
+
+	return rc, nil
+}
+
+// normalizeCacheQuery 规范化缓存查询：全角转半角、合并空白、转小写并去掉末尾标点
+func normalizeCacheQuery(query string) string {
+	query = strings.ToLower(segment.Normalize(query))
+	return strings.TrimRight(query, " ?!.。？！~")
+}
+
+// retrievalCacheKey 生成缓存键
+func retrievalCacheKey(base string, topK int, query string) string {
+	return base + "\x00" + strconv.Itoa(topK) + "\x00" + normalizeCacheQuery(query)
+}
+
+// Get 获取缓存的检索结果，返回副本
+func (rc *retrievalCache) Get(base string, topK int, query string) ([]model.KnowledgeChunk, bool) {
+	chunks, ok := rc.lru.Get(retrievalCacheKey(base, topK, query))
+	if !ok {
+		return nil, false
+	}
+	return append([]model.KnowledgeChunk(nil), chunks...), true
+}
+
+// Set 缓存检索结果
+func (rc *retrievalCache) Set(base string, topK int, query string, chunks []model.KnowledgeChunk) {
+	rc.lru.Set(retrievalCacheKey(base, topK, query), append([]model.KnowledgeChunk(nil), chunks...))
+}
+
+// PurgeBase 清除指定知识库的缓存，返回清除数量
+func (rc *retrievalCache) PurgeBase(base string) int {
+	prefix := base + "\x00"
+	return rc.lru.RemoveFunc(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// Purge 清除全部缓存，返回清除数量
+func (rc *retrievalCache) Purge() int {
+	return rc.lru.Purge()
+}
+
+// Stats 获取缓存统计信息
+func (rc *retrievalCache) Stats() model.CacheStats {
+	stats := rc.lru.Stats()
+	result := model.CacheStats{
+		Enabled:   true,
+		Size:      stats.Size,
+		Capacity:  stats.Capacity,
+		TTL:       rc.config.TTL,
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Evictions: stats.Evictions,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		result.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return result
+}
+
+// Save 将未过期的条目保存到持久化文件，未配置文件路径时不保存
+func (rc *retrievalCache) Save() error {
+	if rc.config.Path == "" {
+		return nil
+	}
+	if dir := filepath.Dir(rc.config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建检索缓存目录失败: %v", err)
+		}
+	}
+
+	data, err := json.Marshal(rc.lru.Entries())
+	if err != nil {
+		return fmt.Errorf("序列化检索缓存失败: %v", err)
+	}
+	tmp := rc.config.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入检索缓存文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, rc.config.Path); err != nil {
+		return fmt.Errorf("写入检索缓存文件失败: %v", err)
+	}
+	return nil
+}
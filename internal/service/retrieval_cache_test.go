@@ -0,0 +1,93 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+)
+
+func TestNormalizeCacheQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"如何配置输入法？", "如何配置输入法"},
+		{"  Rime   配置?! ", "rime 配置"},
+		{"ＲＩＭＥ　输入法。", "rime 输入法"},
+		{"配置 default.yaml", "配置 default.yaml"},
+	}
+	for _, tt := range tests {
+		if got := normalizeCacheQuery(tt.query); got != tt.want {
+			t.Errorf("normalizeCacheQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRetrievalCache(t *testing.T) {
+	rc, err := newRetrievalCache(&config.CacheConfig{Size: 10, TTL: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := []model.KnowledgeChunk{{Content: "片段", Score: 0.9}}
+	rc.Set("docs", 5, "如何配置？", chunks)
+	rc.Set("faq", 5, "如何配置？", chunks)
+
+	tests := []struct {
+		name    string
+		base    string
+		topK    int
+		query   string
+		wantHit bool
+	}{
+		{"规范化后相同的查询命中", "docs", 5, "  如何配置 ", true},
+		{"不同知识库不共享", "manual", 5, "如何配置", false},
+		{"不同 top_k 不共享", "docs", 3, "如何配置", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := rc.Get(tt.base, tt.topK, tt.query); ok != tt.wantHit {
+				t.Errorf("Get() hit = %v, want %v", ok, tt.wantHit)
+			}
+		})
+	}
+
+	// 返回副本，修改不影响缓存
+	got, _ := rc.Get("docs", 5, "如何配置")
+	got[0].Content = "已修改"
+	if again, _ := rc.Get("docs", 5, "如何配置"); again[0].Content != "片段" {
+		t.Errorf("cached chunk modified: %q", again[0].Content)
+	}
+
+	if n := rc.PurgeBase("docs"); n != 1 {
+		t.Errorf("PurgeBase() = %d, want 1", n)
+	}
+	if _, ok := rc.Get("docs", 5, "如何配置"); ok {
+		t.Error("Get() after PurgeBase = true")
+	}
+	if _, ok := rc.Get("faq", 5, "如何配置"); !ok {
+		t.Error("PurgeBase removed entries of another base")
+	}
+}
+
+func TestRetrievalCachePersistence(t *testing.T) {
+	cfg := &config.CacheConfig{Size: 10, TTL: 60, Path: filepath.Join(t.TempDir(), "cache", "retrieval.json")}
+	rc, err := newRetrievalCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Set("docs", 5, "如何配置", []model.KnowledgeChunk{{Content: "片段", Score: 0.9}})
+	if err := rc.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := newRetrievalCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, ok := loaded.Get("docs", 5, "如何配置")
+	if !ok || len(chunks) != 1 || chunks[0].Content != "片段" || chunks[0].Score != 0.9 {
+		t.Errorf("Get() after reload = %+v, %v", chunks, ok)
+	}
+}
@@ -0,0 +1,222 @@
+package retriever
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/resilience"
+)
+
+// 上游知识库响应中可能出现的字段名（不同知识库服务的命名不一致）
+var (
+	chunkListKeys    = []string{"data", "results", "documents", "chunks", "records", "items", "matches"}
+	chunkContentKeys = []string{"content", "text", "page_content", "chunk", "segment_content", "document"}
+	chunkTitleKeys   = []string{"title", "doc_title", "document_name", "name"}
+	chunkURLKeys     = []string{"url", "link", "doc_url", "source_url"}
+	chunkSourceKeys  = []string{"source", "file_name", "file", "doc_id", "document_id"}
+	chunkScoreKeys   = []string{"score", "similarity", "relevance_score"}
+)
+
+func init() {
+	Register("http", NewHTTPRetriever)
+}
+
+// HTTPRetriever 通用 HTTP 知识库检索器，POST {query, top_k} 到指定地址
+type HTTPRetriever struct {
+	name    string
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPRetriever 创建通用 HTTP 知识库检索器
+func NewHTTPRetriever(name string, cfg *config.KnowledgeBaseConfig, deps *Dependencies) (Retriever, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("知识库 %s 未配置 base_url", name)
+	}
+
+	return &HTTPRetriever{
+		name:    name,
+		baseURL: cfg.BaseURL,
+		token:   cfg.Token,
+		// 检索请求只读，POST 失败后可以重试
+		client: resilience.Client("knowledge/"+name, &http.Client{
+			Timeout: 30 * time.Second,
+		}, resilience.RetryNonIdempotent()),
+	}, nil
+}
+
+// Retrieve 查询知识库，返回解析后的知识片段
+func (r *HTTPRetriever) Retrieve(ctx context.Context, query string, topK int) ([]model.KnowledgeChunk, error) {
+	// 构建请求体
+	requestBody := model.KnowledgeQuery{
+		Query: query,
+		TopK:  topK,
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %v", err)
+	}
+
+	// 创建 HTTP 请求
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Authorization", r.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	// 发送请求
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	// 检查状态码
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("知识库查询失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	return parseKnowledgeResponse(body)
+}
+
+// GetType 获取类型
+func (r *HTTPRetriever) GetType() string {
+	return "http"
+}
+
+// parseKnowledgeResponse 解析知识库响应，兼容常见的几种响应格式；无法识别的格式整体作为一个片段
+func parseKnowledgeResponse(body []byte) ([]model.KnowledgeChunk, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		// 非 JSON 响应，直接作为文本内容
+		return []model.KnowledgeChunk{{Content: string(trimmed)}}, nil
+	}
+
+	// 标准响应格式中 success 为 false 时视为查询失败
+	var knowledgeResp model.KnowledgeResponse
+	if err := json.Unmarshal(trimmed, &knowledgeResp); err == nil && knowledgeResp.Success != nil && !*knowledgeResp.Success {
+		return nil, fmt.Errorf("知识库返回失败: %s", knowledgeResp.Message)
+	}
+
+	chunks, ok := extractChunks(raw)
+	if !ok {
+		return []model.KnowledgeChunk{{Content: string(trimmed)}}, nil
+	}
+	return chunks, nil
+}
+
+// extractChunks 从 JSON 值中提取知识片段
+func extractChunks(value interface{}) ([]model.KnowledgeChunk, bool) {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, true
+		}
+		return []model.KnowledgeChunk{{Content: v}}, true
+	case []interface{}:
+		chunks := []model.KnowledgeChunk{}
+		for _, item := range v {
+			switch it := item.(type) {
+			case string:
+				if it != "" {
+					chunks = append(chunks, model.KnowledgeChunk{Content: it})
+				}
+			case map[string]interface{}:
+				if chunk, ok := chunkFromMap(it); ok {
+					chunks = append(chunks, chunk)
+				}
+			case []interface{}:
+				// 部分向量数据库按查询分组返回二维数组
+				if nested, ok := extractChunks(it); ok {
+					chunks = append(chunks, nested...)
+				}
+			}
+		}
+		return chunks, true
+	case map[string]interface{}:
+		for _, key := range chunkListKeys {
+			if nested, exists := v[key]; exists && nested != nil {
+				return extractChunks(nested)
+			}
+		}
+		// 单个片段对象
+		if chunk, ok := chunkFromMap(v); ok {
+			return []model.KnowledgeChunk{chunk}, true
+		}
+	}
+	return nil, false
+}
+
+// chunkFromMap 将单个结果对象转换为知识片段
+func chunkFromMap(m map[string]interface{}) (model.KnowledgeChunk, bool) {
+	chunk := model.KnowledgeChunk{
+		Content: firstString(m, chunkContentKeys),
+		Title:   firstString(m, chunkTitleKeys),
+		URL:     firstString(m, chunkURLKeys),
+		Source:  firstString(m, chunkSourceKeys),
+		Score:   firstNumber(m, chunkScoreKeys),
+	}
+
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		chunk.Metadata = metadata
+		// 标题、链接等信息也可能放在 metadata 中
+		if chunk.Title == "" {
+			chunk.Title = firstString(metadata, chunkTitleKeys)
+		}
+		if chunk.URL == "" {
+			chunk.URL = firstString(metadata, chunkURLKeys)
+		}
+		if chunk.Source == "" {
+			chunk.Source = firstString(metadata, chunkSourceKeys)
+		}
+	}
+
+	if chunk.Content == "" {
+		return chunk, false
+	}
+	return chunk, true
+}
+
+// firstString 返回第一个存在的字符串字段
+func firstString(m map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if s, ok := m[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// firstNumber 返回第一个存在的数值字段
+func firstNumber(m map[string]interface{}, keys []string) float64 {
+	for _, key := range keys {
+		if n, ok := m[key].(float64); ok {
+			return n
+		}
+	}
+	return 0
+}
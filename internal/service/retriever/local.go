@@ -0,0 +1,122 @@
+package retriever
+
+import (
+	"context"
+	"fmt"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/vectorstore"
+)
+
+func init() {
+	Register("local", NewLocalRetriever)
+}
+
+// LocalRetriever 内置本地向量检索器，向量保存在本地磁盘，在进程内进行余弦相似度检索
+type LocalRetriever struct {
+	name     string
+	store    *vectorstore.Store
+	embedder Embedder
+	minScore float64
+}
+
+// NewLocalRetriever 创建本地向量检索器
+func NewLocalRetriever(name string, cfg *config.KnowledgeBaseConfig, deps *Dependencies) (Retriever, error) {
+	if deps == nil || deps.Embedder == nil {
+		return nil, fmt.Errorf("知识库 %s 需要可用的 Embedding 服务", name)
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = fmt.Sprintf("data/vectors/%s.gob", name)
+	}
+
+	store, err := vectorstore.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("知识库 %s 打开向量存储失败: %w", name, err)
+	}
+
+	return &LocalRetriever{
+		name:     name,
+		store:    store,
+		embedder: deps.Embedder,
+		minScore: cfg.MinScore,
+	}, nil
+}
+
+// Retrieve 向量化查询后在本地向量存储中检索
+func (r *LocalRetriever) Retrieve(ctx context.Context, query string, topK int) ([]model.KnowledgeChunk, error) {
+	if r.store.Count() == 0 {
+		return nil, nil
+	}
+
+	vectors, err := r.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("查询向量化失败: %v", err)
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("查询向量化结果为空")
+	}
+
+	var chunks []model.KnowledgeChunk
+	for _, result := range r.store.Search(vectors[0], topK) {
+		if result.Score < r.minScore {
+			continue
+		}
+		chunk := result.Record.Chunk
+		chunk.Score = result.Score
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// IndexDocument 向量化文档片段并写入本地向量存储，替换该文档已有的片段
+func (r *LocalRetriever) IndexDocument(ctx context.Context, documentID string, chunks []model.KnowledgeChunk) error {
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Content
+	}
+
+	vectors, err := r.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("向量化结果数量不一致: 期望 %d，实际 %d", len(chunks), len(vectors))
+	}
+
+	records := make([]vectorstore.Record, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = vectorstore.Record{
+			ID:         fmt.Sprintf("%s#%d", documentID, i),
+			DocumentID: documentID,
+			Chunk:      chunk,
+			Vector:     vectors[i],
+		}
+	}
+
+	// 整体替换旧片段，避免文档变短后残留多余片段；写入失败时旧片段保持不变
+	return r.store.ReplaceDocument(documentID, records)
+}
+
+// DeleteDocument 删除文档的全部片段
+func (r *LocalRetriever) DeleteDocument(ctx context.Context, documentID string) error {
+	_, err := r.store.DeleteDocument(documentID)
+	return err
+}
+
+// Store 获取底层向量存储
+func (r *LocalRetriever) Store() *vectorstore.Store {
+	return r.store
+}
+
+// Close 关闭向量存储，释放文件锁
+func (r *LocalRetriever) Close() error {
+	return r.store.Close()
+}
+
+// GetType 获取类型
+func (r *LocalRetriever) GetType() string {
+	return "local"
+}
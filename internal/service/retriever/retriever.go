@@ -0,0 +1,135 @@
+package retriever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+)
+
+// Retriever 知识检索器接口，每种知识库后端实现一个检索器
+type Retriever interface {
+	// Retrieve 检索与查询相关的知识片段，ctx 取消或超时后应尽快返回
+	Retrieve(ctx context.Context, query string, topK int) ([]model.KnowledgeChunk, error)
+	// GetType 获取类型
+	GetType() string
+}
+
+// Indexer 支持写入的检索器，用于文档导入
+type Indexer interface {
+	// IndexDocument 写入文档的全部片段，替换该文档已有的片段；ctx 取消后应尽快返回，已有片段保持不变
+	IndexDocument(ctx context.Context, documentID string, chunks []model.KnowledgeChunk) error
+	// DeleteDocument 删除文档的全部片段
+	DeleteDocument(ctx context.Context, documentID string) error
+}
+
+// Embedder 文本向量化接口，由 AI 服务实现
+type Embedder interface {
+	// Embed 批量将文本转换为向量
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Dependencies 检索器可使用的公共依赖
+type Dependencies struct {
+	Embedder Embedder
+}
+
+// Factory 检索器构造函数
+type Factory func(name string, cfg *config.KnowledgeBaseConfig, deps *Dependencies) (Retriever, error)
+
+// factories 已注册的检索器类型
+var factories = map[string]Factory{}
+
+// Register 注册检索器类型，由各实现在 init 中调用
+func Register(backendType string, factory Factory) {
+	factories[strings.ToLower(backendType)] = factory
+}
+
+// New 根据配置创建检索器
+func New(name string, cfg *config.KnowledgeBaseConfig, deps *Dependencies) (Retriever, error) {
+	factory, ok := factories[strings.ToLower(cfg.Type)]
+	if !ok {
+		return nil, fmt.Errorf("知识库 %s 使用了不支持的类型: %s", name, cfg.Type)
+	}
+	return factory(name, cfg, deps)
+}
+
+// Registry 已配置的知识库检索器集合
+type Registry struct {
+	retrievers  map[string]Retriever
+	configs     map[string]*config.KnowledgeBaseConfig
+	names       []string
+	defaultName string
+}
+
+// NewRegistry 根据知识库配置创建所有命名检索器
+func NewRegistry(cfg *config.KnowledgeConfig, deps *Dependencies) (*Registry, error) {
+	r := &Registry{
+		retrievers:  make(map[string]Retriever),
+		configs:     make(map[string]*config.KnowledgeBaseConfig),
+		defaultName: cfg.Default,
+	}
+
+	for name, baseCfg := range cfg.Bases {
+		retriever, err := New(name, &baseCfg, deps)
+		if err != nil {
+			// 释放已创建的检索器持有的文件锁
+			r.Close()
+			return nil, err
+		}
+		r.retrievers[name] = retriever
+		r.configs[name] = &baseCfg
+		r.names = append(r.names, name)
+	}
+	sort.Strings(r.names)
+
+	if len(r.names) == 0 {
+		return nil, fmt.Errorf("未配置任何知识库")
+	}
+	if r.defaultName == "" {
+		r.defaultName = r.names[0]
+	}
+	if _, ok := r.retrievers[r.defaultName]; !ok {
+		r.Close()
+		return nil, fmt.Errorf("默认知识库 %s 不存在", r.defaultName)
+	}
+
+	return r, nil
+}
+
+// Close 关闭所有实现了 io.Closer 的检索器，如释放本地向量存储的文件锁
+func (r *Registry) Close() error {
+	var errs []error
+	for _, retriever := range r.retrievers {
+		if closer, ok := retriever.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Get 获取指定名称的检索器
+func (r *Registry) Get(name string) (Retriever, bool) {
+	retriever, ok := r.retrievers[name]
+	return retriever, ok
+}
+
+// Config 获取指定名称知识库的配置
+func (r *Registry) Config(name string) *config.KnowledgeBaseConfig {
+	return r.configs[name]
+}
+
+// DefaultName 获取默认知识库名称
+func (r *Registry) DefaultName() string {
+	return r.defaultName
+}
+
+// Names 获取所有知识库名称（按名称排序）
+func (r *Registry) Names() []string {
+	return r.names
+}
@@ -0,0 +1,55 @@
+package retriever
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/vectorstore"
+)
+
+type stubEmbedder struct{}
+
+func (stubEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return make([][]float32, len(texts)), nil
+}
+
+func TestNewRegistryReleasesLocksOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.gob")
+	cfg := &config.KnowledgeConfig{
+		Bases: map[string]config.KnowledgeBaseConfig{
+			"docs":   {Type: "local", Path: path},
+			"broken": {Type: "unknown"},
+		},
+	}
+
+	if _, err := NewRegistry(cfg, &Dependencies{Embedder: stubEmbedder{}}); err == nil {
+		t.Fatal("NewRegistry() error = nil, want unsupported type error")
+	}
+
+	// 失败后已打开的向量存储应已释放文件锁
+	store, err := vectorstore.Open(path)
+	if err != nil {
+		t.Fatalf("vectorstore.Open() after failed NewRegistry: %v", err)
+	}
+	store.Close()
+}
+
+func TestNewRegistryMissingDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.gob")
+	cfg := &config.KnowledgeConfig{
+		Default: "missing",
+		Bases:   map[string]config.KnowledgeBaseConfig{"docs": {Type: "local", Path: path}},
+	}
+
+	if _, err := NewRegistry(cfg, &Dependencies{Embedder: stubEmbedder{}}); err == nil {
+		t.Fatal("NewRegistry() error = nil, want missing default error")
+	}
+
+	store, err := vectorstore.Open(path)
+	if err != nil {
+		t.Fatalf("vectorstore.Open() after failed NewRegistry: %v", err)
+	}
+	store.Close()
+}
@@ -0,0 +1,220 @@
+package retriever
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/model"
+	"knowledge-maker/internal/resilience"
+)
+
+func init() {
+	Register("tcvectordb", NewTCVectorDBRetriever)
+}
+
+// TCVectorDBRetriever 腾讯云向量数据库检索器，使用集合内置的 Embedding 进行文本检索和文档写入
+type TCVectorDBRetriever struct {
+	name       string
+	baseURL    string
+	username   string
+	apiKey     string
+	database   string
+	collection string
+	client     *http.Client
+}
+
+// tcvectordbSearchRequest 向量数据库文本检索请求
+type tcvectordbSearchRequest struct {
+	Database        string                `json:"database"`
+	Collection      string                `json:"collection"`
+	ReadConsistency string                `json:"readConsistency"`
+	Search          tcvectordbSearchParam `json:"search"`
+}
+
+// tcvectordbSearchParam 检索参数
+type tcvectordbSearchParam struct {
+	EmbeddingItems []string `json:"embeddingItems"`
+	Limit          int      `json:"limit"`
+	RetrieveVector bool     `json:"retrieveVector"`
+}
+
+// tcvectordbResponse 响应状态
+type tcvectordbResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// tcvectordbDocument 写入集合的文档片段
+type tcvectordbDocument struct {
+	ID         string `json:"id"`
+	Text       string `json:"text"`
+	Title      string `json:"title,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Source     string `json:"source,omitempty"`
+	DocumentID string `json:"document_id"`
+	ChunkIndex int    `json:"chunk_index"`
+}
+
+// tcvectordbUpsertRequest 写入文档请求
+type tcvectordbUpsertRequest struct {
+	Database   string               `json:"database"`
+	Collection string               `json:"collection"`
+	BuildIndex bool                 `json:"buildIndex"`
+	Documents  []tcvectordbDocument `json:"documents"`
+}
+
+// tcvectordbDeleteRequest 删除文档请求
+type tcvectordbDeleteRequest struct {
+	Database   string                `json:"database"`
+	Collection string                `json:"collection"`
+	Query      tcvectordbDeleteQuery `json:"query"`
+}
+
+// tcvectordbDeleteQuery 删除条件
+type tcvectordbDeleteQuery struct {
+	Filter string `json:"filter"`
+}
+
+// NewTCVectorDBRetriever 创建腾讯云向量数据库检索器
+func NewTCVectorDBRetriever(name string, cfg *config.KnowledgeBaseConfig, deps *Dependencies) (Retriever, error) {
+	if cfg.BaseURL == "" || cfg.Database == "" || cfg.Collection == "" {
+		return nil, fmt.Errorf("知识库 %s 需要配置 base_url、database 和 collection", name)
+	}
+
+	username := cfg.Username
+	if username == "" {
+		username = "root"
+	}
+
+	return &TCVectorDBRetriever{
+		name:       name,
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		username:   username,
+		apiKey:     cfg.Token,
+		database:   cfg.Database,
+		collection: cfg.Collection,
+		// 检索请求只读，文档写入和删除按固定 ID 和过滤条件执行，POST 失败后可以重试
+		client: resilience.Client("knowledge/"+name, &http.Client{
+			Timeout: 30 * time.Second,
+		}, resilience.RetryNonIdempotent()),
+	}, nil
+}
+
+// Retrieve 在向量数据库集合中检索
+func (r *TCVectorDBRetriever) Retrieve(ctx context.Context, query string, topK int) ([]model.KnowledgeChunk, error) {
+	requestBody := tcvectordbSearchRequest{
+		Database:        r.database,
+		Collection:      r.collection,
+		ReadConsistency: "eventualConsistency",
+		Search: tcvectordbSearchParam{
+			EmbeddingItems: []string{query},
+			Limit:          topK,
+		},
+	}
+
+	body, err := r.post(ctx, "/document/search", requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	// documents 按查询分组，每组为一个文档数组
+	return parseKnowledgeResponse(body)
+}
+
+// IndexDocument 写入文档片段，由集合内置的 Embedding 对 text 字段向量化；
+// 先按固定 ID 覆盖写入新片段，再删除序号超出新片段数的旧片段，写入失败时旧片段保持不变
+func (r *TCVectorDBRetriever) IndexDocument(ctx context.Context, documentID string, chunks []model.KnowledgeChunk) error {
+	documents := make([]tcvectordbDocument, len(chunks))
+	for i, chunk := range chunks {
+		documents[i] = tcvectordbDocument{
+			ID:         fmt.Sprintf("%s#%d", documentID, i),
+			Text:       chunk.Content,
+			Title:      chunk.Title,
+			URL:        chunk.URL,
+			Source:     chunk.Source,
+			DocumentID: documentID,
+			ChunkIndex: i,
+		}
+	}
+
+	if len(documents) > 0 {
+		requestBody := tcvectordbUpsertRequest{
+			Database:   r.database,
+			Collection: r.collection,
+			BuildIndex: true,
+			Documents:  documents,
+		}
+		if _, err := r.post(ctx, "/document/upsert", requestBody); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteWhere(ctx, fmt.Sprintf("document_id=%s and chunk_index>=%d", strconv.Quote(documentID), len(chunks)))
+}
+
+// DeleteDocument 删除文档的全部片段
+func (r *TCVectorDBRetriever) DeleteDocument(ctx context.Context, documentID string) error {
+	return r.deleteWhere(ctx, fmt.Sprintf("document_id=%s", strconv.Quote(documentID)))
+}
+
+// deleteWhere 按过滤条件删除片段，过滤字段需在集合中建立 filter 索引
+func (r *TCVectorDBRetriever) deleteWhere(ctx context.Context, filter string) error {
+	requestBody := tcvectordbDeleteRequest{
+		Database:   r.database,
+		Collection: r.collection,
+		Query:      tcvectordbDeleteQuery{Filter: filter},
+	}
+	_, err := r.post(ctx, "/document/delete", requestBody)
+	return err
+}
+
+// post 发送请求并检查响应状态，返回响应内容
+func (r *TCVectorDBRetriever) post(ctx context.Context, path string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer account=%s&api_key=%s", r.username, r.apiKey))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("向量数据库请求 %s 失败，状态码: %d, 响应: %s", path, resp.StatusCode, string(body))
+	}
+
+	var result tcvectordbResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析向量数据库响应失败: %v", err)
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("向量数据库请求 %s 失败: code=%d, msg=%s", path, result.Code, result.Msg)
+	}
+	return body, nil
+}
+
+// GetType 获取类型
+func (r *TCVectorDBRetriever) GetType() string {
+	return "tcvectordb"
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"knowledge-maker/internal/config"
+	"knowledge-maker/internal/logger"
+	"knowledge-maker/internal/segment"
+	"knowledge-maker/internal/service/reranker"
+	"knowledge-maker/internal/service/retriever"
+)
+
+// llmRouterPrompt LLM 路由提示词
+const llmRouterPrompt = `你是知识库路由助手。根据用户问题，从下面的知识库列表中选出最可能包含答案的知识库，按相关程度从高到低排列。
+只输出知识库名称组成的 JSON 数组，不要输出任何解释，如 ["faq"]；没有相关知识库时输出 []。`
+
+// knowledgeRouter 知识库路由器，请求未指定知识库时根据问题选择要检索的知识库
+type knowledgeRouter struct {
+	registry  *retriever.Registry
+	completer reranker.Completer
+	config    *config.RouterConfig
+}
+
+// newKnowledgeRouter 创建知识库路由器，llm 路由需要可用的 completer
+func newKnowledgeRouter(cfg *config.RouterConfig, registry *retriever.Registry, completer reranker.Completer) (*knowledgeRouter, error) {
+	switch cfg.Type {
+	case "", "none", "keyword":
+	case "llm":
+		if completer == nil {
+			return nil, fmt.Errorf("LLM 知识库路由需要可用的 AI 服务")
+		}
+	default:
+		return nil, fmt.Errorf("不支持的知识库路由类型: %s", cfg.Type)
+	}
+	if cfg.Fallback != "default" && cfg.Fallback != "all" {
+		return nil, fmt.Errorf("不支持的知识库路由兜底方式: %s", cfg.Fallback)
+	}
+
+	return &knowledgeRouter{
+		registry:  registry,
+		completer: completer,
+		config:    cfg,
+	}, nil
+}
+
+// Route 根据问题选择知识库，没有匹配时按 fallback 配置返回默认知识库或全部知识库
+func (kr *knowledgeRouter) Route(ctx context.Context, query string) []string {
+	var names []string
+	switch kr.config.Type {
+	case "keyword":
+		names = kr.routeByKeywords(query)
+	case "llm":
+		var err error
+		names, err = kr.routeByLLM(ctx, query)
+		if err != nil {
+			logger.Warn("LLM 知识库路由失败，改用关键词路由: %v", err)
+			names = kr.routeByKeywords(query)
+		}
+	default:
+		return []string{kr.registry.DefaultName()}
+	}
+
+	if len(names) == 0 {
+		if kr.config.Fallback == "all" {
+			names = kr.registry.Names()
+		} else {
+			names = []string{kr.registry.DefaultName()}
+		}
+		logger.Info("知识库路由未匹配，使用兜底知识库: %s", strings.Join(names, ", "))
+		return names
+	}
+
+	logger.Info("知识库路由结果: %s", strings.Join(names, ", "))
+	return names
+}
+
+// routeByKeywords 按知识库配置的关键词匹配问题，命中关键词越多越靠前
+func (kr *knowledgeRouter) routeByKeywords(query string) []string {
+	query = strings.ToLower(segment.Normalize(query))
+
+	type match struct {
+		name string
+		hits int
+	}
+	var matches []match
+	for _, name := range kr.registry.Names() {
+		hits := 0
+		for _, keyword := range kr.registry.Config(name).Keywords {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword != "" && strings.Contains(query, keyword) {
+				hits++
+			}
+		}
+		if hits > 0 {
+			matches = append(matches, match{name: name, hits: hits})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].hits > matches[j].hits
+	})
+
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m.name)
+	}
+	return kr.limit(names)
+}
+
+// routeByLLM 由对话模型根据知识库说明选择知识库
+func (kr *knowledgeRouter) routeByLLM(ctx context.Context, query string) ([]string, error) {
+	var b strings.Builder
+	b.WriteString("知识库列表：\n")
+	for _, name := range kr.registry.Names() {
+		baseCfg := kr.registry.Config(name)
+		description := baseCfg.Description
+		if description == "" {
+			description = strings.Join(baseCfg.Keywords, "、")
+		}
+		b.WriteString(fmt.Sprintf("- %s: %s\n", name, description))
+	}
+	b.WriteString(fmt.Sprintf("\n用户问题：%s", query))
+
+	reply, err := kr.completer.Complete(ctx, llmRouterPrompt, b.String(), 100)
+	if err != nil {
+		return nil, err
+	}
+
+	start := strings.Index(reply, "[")
+	end := strings.LastIndex(reply, "]")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("路由结果格式错误: %s", reply)
+	}
+	var selected []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &selected); err != nil {
+		return nil, fmt.Errorf("解析路由结果失败: %v", err)
+	}
+
+	// 忽略模型编造的知识库名称和重复项
+	names := make([]string, 0, len(selected))
+	seen := make(map[string]bool)
+	for _, name := range selected {
+		name = strings.TrimSpace(name)
+		if _, ok := kr.registry.Get(name); !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return kr.limit(names), nil
+}
+
+// limit 限制一次检索的知识库数量
+func (kr *knowledgeRouter) limit(names []string) []string {
+	if kr.config.MaxBases > 0 && len(names) > kr.config.MaxBases {
+		return names[:kr.config.MaxBases]
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package service
+
+import "strings"
+
+// 内联思考块的标签
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// 内联思考块的解析状态
+const (
+	thinkDetecting = iota // 内容开头，判断是否以 <think> 开始
+	thinkReasoning        // 在思考块中，等待 </think>
+	thinkAnswering        // 思考块已结束或内容不以 <think> 开始，其余内容都是回答
+)
+
+// thinkParser 流式拆分内联在回答内容中的思考块：部分提供方不使用 reasoning_content 字段，
+// 而是在 content 开头输出 <think>...</think>。标签可能被拆分到相邻的数据块中，
+// 可能是标签一部分的内容会暂存到收到后续数据块再判断。只识别内容开头的思考块，回答中出现的标签原样保留
+type thinkParser struct {
+	state int
+	buf   string // 暂存的内容
+	trim  bool   // 去掉思考内容或回答内容开头的空白（标签后通常跟着换行）
+}
+
+// Feed 处理一段内容，返回其中可以确定的思考内容和回答内容
+func (p *thinkParser) Feed(text string) (reasoning, content string) {
+	switch p.state {
+	case thinkDetecting:
+		p.buf += text
+		head := strings.TrimLeft(p.buf, " \t\r\n")
+		switch {
+		case strings.HasPrefix(head, thinkOpenTag):
+			p.state, p.buf, p.trim = thinkReasoning, "", true
+			return p.Feed(head[len(thinkOpenTag):])
+		case strings.HasPrefix(thinkOpenTag, head):
+			// 只有空白或标签的前一部分，等待后续内容
+			return "", ""
+		default:
+			p.state = thinkAnswering
+			content, p.buf = p.buf, ""
+			return "", content
+		}
+
+	case thinkReasoning:
+		p.buf += p.trimLeading(text)
+		if i := strings.Index(p.buf, thinkCloseTag); i >= 0 {
+			reasoning, text = p.buf[:i], p.buf[i+len(thinkCloseTag):]
+			p.state, p.buf, p.trim = thinkAnswering, "", true
+			_, content = p.Feed(text)
+			return reasoning, content
+		}
+		// 末尾可能是 </think> 的前一部分，暂存到下一个数据块
+		keep := partialSuffix(p.buf, thinkCloseTag)
+		reasoning, p.buf = p.buf[:len(p.buf)-keep], p.buf[len(p.buf)-keep:]
+		return reasoning, ""
+
+	default:
+		return "", p.trimLeading(text)
+	}
+}
+
+// Flush 流结束时返回暂存的内容：未闭合的思考块作为思考内容，未能判断的开头作为回答内容
+func (p *thinkParser) Flush() (reasoning, content string) {
+	buf := p.buf
+	p.buf = ""
+	switch p.state {
+	case thinkReasoning:
+		return buf, ""
+	case thinkDetecting:
+		if strings.TrimSpace(buf) == "" {
+			return "", ""
+		}
+		return "", buf
+	default:
+		return "", ""
+	}
+}
+
+// trimLeading 标签之后第一段非空白内容之前的空白被丢弃
+func (p *thinkParser) trimLeading(text string) string {
+	if !p.trim {
+		return text
+	}
+	text = strings.TrimLeft(text, " \t\r\n")
+	if text != "" {
+		p.trim = false
+	}
+	return text
+}
+
+// partialSuffix 返回 s 末尾与 tag 开头相同的最长部分的长度（不含完整的 tag）
+func partialSuffix(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package service
+
+import "testing"
+
+func TestThinkParser(t *testing.T) {
+	tests := []struct {
+		name          string
+		chunks        []string
+		wantReasoning string
+		wantContent   string
+	}{
+		{
+			name:          "开始标签被拆分",
+			chunks:        []string{"<thi", "nk>推理过程</think>回答"},
+			wantReasoning: "推理过程",
+			wantContent:   "回答",
+		},
+		{
+			name:          "结束标签被拆分",
+			chunks:        []string{"<think>推理过程</th", "ink>回答"},
+			wantReasoning: "推理过程",
+			wantContent:   "回答",
+		},
+		{
+			name:          "标签逐字符到达",
+			chunks:        []string{"<", "t", "h", "i", "n", "k", ">", "推理", "<", "/", "think", ">", "回答"},
+			wantReasoning: "推理",
+			wantContent:   "回答",
+		},
+		{
+			name:          "开头和标签后的空白",
+			chunks:        []string{"\n  ", "<think>\n", "推理过程\n</think>", "\n\n回答"},
+			wantReasoning: "推理过程\n",
+			wantContent:   "回答",
+		},
+		{
+			name:        "以尖括号开头的回答",
+			chunks:      []string{"<", "3 表示喜欢"},
+			wantContent: "<3 表示喜欢",
+		},
+		{
+			name:        "只有一个尖括号的回答",
+			chunks:      []string{"<"},
+			wantContent: "<",
+		},
+		{
+			name:          "思考块未闭合",
+			chunks:        []string{"<think>推理过程", "还没有结束</th"},
+			wantReasoning: "推理过程还没有结束</th",
+		},
+		{
+			name:        "没有思考块",
+			chunks:      []string{"直接", "回答"},
+			wantContent: "直接回答",
+		},
+		{
+			name:        "回答中的标签原样保留",
+			chunks:      []string{"回答 <think>标签</think>"},
+			wantContent: "回答 <think>标签</think>",
+		},
+		{
+			name:          "回答中再次出现标签",
+			chunks:        []string{"<think>推理</think>回答 <think>x</think>"},
+			wantReasoning: "推理",
+			wantContent:   "回答 <think>x</think>",
+		},
+		{
+			name:   "只有空白",
+			chunks: []string{" \n", "\t"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p thinkParser
+			var reasoning, content string
+			for _, chunk := range tt.chunks {
+				r, c := p.Feed(chunk)
+				reasoning += r
+				content += c
+			}
+			r, c := p.Flush()
+			reasoning += r
+			content += c
+
+			if reasoning != tt.wantReasoning {
+				t.Errorf("reasoning = %q, want %q", reasoning, tt.wantReasoning)
+			}
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestThinkParserFlushResets(t *testing.T) {
+	var p thinkParser
+	if r, c := p.Feed("<think>推理</th"); r != "推理" || c != "" {
+		t.Fatalf("Feed() = %q, %q, want %q, %q", r, c, "推理", "")
+	}
+	if r, c := p.Flush(); r != "</th" || c != "" {
+		t.Fatalf("Flush() = %q, %q, want %q, %q", r, c, "</th", "")
+	}
+	if r, c := p.Flush(); r != "" || c != "" {
+		t.Fatalf("second Flush() = %q, %q, want empty", r, c)
+	}
+}
+
+func TestPartialSuffix(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"推理", 0},
+		{"推理<", 1},
+		{"推理</th", 4},
+		{"推理</think", 7},
+		{"</think>", 0},
+		{"<", 1},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := partialSuffix(tt.s, thinkCloseTag); got != tt.want {
+			t.Errorf("partialSuffix(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout 请求在配置的时限内未完成
+var ErrTimeout = errors.New("请求超时")
+
+// withTimeout 为请求的某个阶段设置超时，seconds 不大于 0 时不限时；
+// 超时后 context.Cause 返回包装了 ErrTimeout 的错误，说明超时的阶段
+func withTimeout(ctx context.Context, seconds int, stage string) (context.Context, context.CancelFunc) {
+	if seconds <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, time.Duration(seconds)*time.Second, fmt.Errorf("%w: %s超过 %d 秒", ErrTimeout, stage, seconds))
+}
+
+// contextError 请求被取消或超时时返回原因，否则返回 nil
+func contextError(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
@@ -0,0 +1,100 @@
+// Package tokenizer 估算文本的 token 数，用于在调用模型前控制提示词长度。
+//
+// 不同模型的分词器差异较大，这里不加载具体的词表，而是按字符类别给出偏保守的估算：
+// 中日韩文字每个字按 1 个 token 计，英文单词约每 5 个字母 1 个 token，数字每 3 位 1 个 token，
+// 标点和符号各 1 个 token，单个空格与后面的单词合并计算。
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Count 估算文本的 token 数
+func Count(text string) int {
+	tokens := 0
+	letters := 0 // 当前英文单词的字母数
+	digits := 0  // 当前数字串的位数
+	others := 0  // 当前其他文字（如西里尔字母）的字符数
+	spaces := 0  // 当前连续空格数
+
+	flush := func() {
+		tokens += (letters+4)/5 + (digits+2)/3 + (others+1)/2
+		if spaces > 1 {
+			tokens++
+		}
+		letters, digits, others, spaces = 0, 0, 0, 0
+	}
+
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || r == '_'):
+			if digits > 0 || others > 0 || spaces > 0 {
+				flush()
+			}
+			letters++
+		case r < utf8.RuneSelf && unicode.IsDigit(r):
+			if letters > 0 || others > 0 || spaces > 0 {
+				flush()
+			}
+			digits++
+		case r == ' ' || r == '\t':
+			if letters > 0 || digits > 0 || others > 0 {
+				flush()
+			}
+			spaces++
+		case r == '\n' || r == '\r':
+			flush()
+			tokens++
+		case isCJK(r):
+			flush()
+			tokens++
+		case r >= utf8.RuneSelf && unicode.IsLetter(r):
+			if letters > 0 || digits > 0 || spaces > 0 {
+				flush()
+			}
+			others++
+		case r >= utf8.RuneSelf && !unicode.IsPunct(r):
+			// 表情等符号通常被拆为多个字节级 token
+			flush()
+			tokens += 2
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// Truncate 截断文本使其不超过 maxTokens 个 token，按字符边界截断
+func Truncate(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if Count(text) <= maxTokens {
+		return text
+	}
+
+	// 二分查找满足限制的最长前缀
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if Count(string(runes[:mid])) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo])
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
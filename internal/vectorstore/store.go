@@ -0,0 +1,317 @@
+package vectorstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"knowledge-maker/internal/filelock"
+	"knowledge-maker/internal/model"
+)
+
+// Record 向量记录：一个知识片段及其向量
+type Record struct {
+	ID         string
+	DocumentID string
+	Chunk      model.KnowledgeChunk
+	Vector     []float32
+}
+
+// SearchResult 检索结果
+type SearchResult struct {
+	Record *Record
+	Score  float64 // 余弦相似度
+}
+
+// Store 本地向量存储，全部记录常驻内存，变更后整体写入磁盘文件；打开期间持有文件锁，
+// 其他进程无法同时打开同一个存储，避免各自的内存副本互相覆盖
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	lock    *filelock.Lock
+	records []*Record
+	index   map[string]int
+	dim     int
+}
+
+// storeFile 磁盘文件格式
+type storeFile struct {
+	Dim     int
+	Records []*Record
+}
+
+// Open 打开本地向量存储并锁定，文件不存在时创建空存储；存储已被其他进程打开时返回 filelock.ErrLocked
+func Open(path string) (*Store, error) {
+	lock, err := filelock.Acquire(path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("锁定向量存储失败: %w", err)
+	}
+
+	s, err := load(path)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	s.lock = lock
+	return s, nil
+}
+
+// load 从磁盘文件读取记录
+func load(path string) (*Store, error) {
+	s := &Store{
+		path:  path,
+		index: make(map[string]int),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开向量存储文件失败: %v", err)
+	}
+	defer f.Close()
+
+	var data storeFile
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("读取向量存储文件失败: %v", err)
+	}
+
+	s.dim = data.Dim
+	s.records = data.Records
+	s.index = indexRecords(s.records)
+
+	return s, nil
+}
+
+// Upsert 写入或更新记录，并持久化到磁盘；先校验全部记录并在副本上修改，写入磁盘成功后才替换内存中的数据，
+// 任何一步失败时内存和磁盘都保持原样
+func (s *Store) Upsert(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dim, err := checkVectors(s.dim, records)
+	if err != nil {
+		return err
+	}
+
+	next := make([]*Record, len(s.records), len(s.records)+len(records))
+	copy(next, s.records)
+	index := make(map[string]int, len(s.index)+len(records))
+	for id, i := range s.index {
+		index[id] = i
+	}
+	for i := range records {
+		r := records[i]
+		// 存储归一化后的向量，检索时点积即为余弦相似度
+		r.Vector = Normalize(r.Vector)
+		if idx, ok := index[r.ID]; ok {
+			next[idx] = &r
+		} else {
+			index[r.ID] = len(next)
+			next = append(next, &r)
+		}
+	}
+
+	return s.commit(dim, next, index)
+}
+
+// ReplaceDocument 用 records 替换文档的全部记录并持久化到磁盘，只写入一次；
+// 校验失败或写入磁盘失败时文档原有的记录保持不变
+func (s *Store) ReplaceDocument(documentID string, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := make([]*Record, 0, len(s.records)+len(records))
+	for _, r := range s.records {
+		if r.DocumentID != documentID {
+			next = append(next, r)
+		}
+	}
+
+	dim := s.dim
+	if len(next) == 0 {
+		dim = 0
+	}
+	dim, err := checkVectors(dim, records)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		r := records[i]
+		r.DocumentID = documentID
+		r.Vector = Normalize(r.Vector)
+		next = append(next, &r)
+	}
+	return s.commit(dim, next, indexRecords(next))
+}
+
+// DeleteDocument 删除文档的全部记录，返回删除的记录数
+func (s *Store) DeleteDocument(documentID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		if r.DocumentID != documentID {
+			kept = append(kept, r)
+		}
+	}
+	removed := len(s.records) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	dim := s.dim
+	if len(kept) == 0 {
+		dim = 0
+	}
+	return removed, s.commit(dim, kept, indexRecords(kept))
+}
+
+// checkVectors 检查记录都有向量且维度一致，返回写入后的向量维度；dim 为 0（存储为空）时以第一条记录为准
+func checkVectors(dim int, records []Record) (int, error) {
+	for _, r := range records {
+		if len(r.Vector) == 0 {
+			return 0, fmt.Errorf("记录 %s 缺少向量", r.ID)
+		}
+		if dim == 0 {
+			dim = len(r.Vector)
+		}
+		if len(r.Vector) != dim {
+			return 0, fmt.Errorf("向量维度不一致: 期望 %d，实际 %d（更换 Embedding 模型后需要重建向量存储）", dim, len(r.Vector))
+		}
+	}
+	return dim, nil
+}
+
+// indexRecords 建立记录 ID 到下标的索引
+func indexRecords(records []*Record) map[string]int {
+	index := make(map[string]int, len(records))
+	for i, r := range records {
+		index[r.ID] = i
+	}
+	return index
+}
+
+// commit 将新的记录写入磁盘，成功后替换内存中的数据，调用方需持有写锁
+func (s *Store) commit(dim int, records []*Record, index map[string]int) error {
+	if err := s.save(dim, records); err != nil {
+		return err
+	}
+	s.dim, s.records, s.index = dim, records, index
+	return nil
+}
+
+// Close 释放文件锁，之后不能再写入
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lock.Release()
+}
+
+// Search 余弦相似度 Top-K 检索
+func (s *Store) Search(vector []float32, topK int) []SearchResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.records) == 0 || len(vector) != s.dim || topK <= 0 {
+		return nil
+	}
+
+	query := Normalize(vector)
+	results := make([]SearchResult, 0, len(s.records))
+	for _, r := range s.records {
+		results = append(results, SearchResult{
+			Record: r,
+			Score:  Dot(query, r.Vector),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+// Records 返回全部记录的快照
+func (s *Store) Records() []*Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]*Record, len(s.records))
+	copy(records, s.records)
+	return records
+}
+
+// Count 返回记录数量
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// save 写入磁盘，先写临时文件再重命名，避免写入中断导致文件损坏
+func (s *Store) save(dim int, records []*Record) error {
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建向量存储目录失败: %v", err)
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("创建向量存储文件失败: %v", err)
+	}
+
+	if err := gob.NewEncoder(f).Encode(storeFile{Dim: dim, Records: records}); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("写入向量存储文件失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入向量存储文件失败: %v", err)
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入向量存储文件失败: %v", err)
+	}
+	return nil
+}
+
+// Normalize 向量归一化
+func Normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if sum == 0 {
+		return out
+	}
+	norm := math.Sqrt(sum)
+	for i, x := range v {
+		out[i] = float32(float64(x) / norm)
+	}
+	return out
+}
+
+// Dot 向量点积，两个归一化向量的点积即余弦相似度
+func Dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}